@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// chartMetadata is the part of a chart's Chart.yaml that is reported alongside results
+type chartMetadata struct {
+	AppVersion string `yaml:"appVersion"`
+}
+
+// showChartArgs builds the `helm show chart` arguments for a chart, referencing
+// OCI and vendored charts directly the same way they are rendered
+func showChartArgs(chart ChartRenderParams, auth ociRegistryAuth) []string {
+	if chart.RepoURL == "" {
+		// Vendored charts are read from their directory
+		return []string{"show", "chart", chart.ChartName}
+	}
+	args := []string{"show", "chart", chart.ChartName, "--repo", chart.RepoURL}
+	if ociHost(chart.RepoURL) != "" {
+		args = []string{"show", "chart", strings.TrimSuffix(chart.RepoURL, "/") + "/" + chart.ChartName}
+		if auth.RegistryConfig != "" {
+			args = append(args, "--registry-config", auth.RegistryConfig)
+		}
+	}
+	return append(args, "--version", chart.ChartVersion)
+}
+
+// chartAppVersion reads the appVersion of a chart with `helm show chart`.
+// Charts that don't declare one return an empty string.
+func chartAppVersion(ctx context.Context, executor CommandExecutor, chart ChartRenderParams, auth ociRegistryAuth) (string, error) {
+	cmd := executor.CommandContext(ctx, "helm", showChartArgs(chart, auth)...)
+	output, stderr, err := cmd.SplitOutput()
+	if err != nil {
+		return "", fmt.Errorf("helm show chart failed for %s: %w: %s", chart.ChartName, err, strings.TrimSpace(string(stderr)))
+	}
+
+	var metadata chartMetadata
+	if err := yaml.Unmarshal(output, &metadata); err != nil {
+		return "", fmt.Errorf("failed to parse Chart.yaml of %s: %w", chart.ChartName, err)
+	}
+	return metadata.AppVersion, nil
+}
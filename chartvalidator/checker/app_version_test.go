@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const showChartOutput = `apiVersion: v2
+name: test-chart
+description: A Helm chart for testing
+type: application
+version: 1.0.0
+appVersion: "2.4.1"
+`
+
+func TestChartAppVersion(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(showChartOutput)
+
+	appVersion, err := chartAppVersion(context.Background(), mockExecutor, createTestChart(), ociRegistryAuth{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2.4.1", appVersion)
+	assertCommandExecution(t, mockExecutor, "helm show chart test-chart --repo https://example.com/charts --version 1.0.0")
+}
+
+func TestChartAppVersionMissing(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("apiVersion: v2\nname: library\nversion: 0.1.0\n")
+
+	appVersion, err := chartAppVersion(context.Background(), mockExecutor, createTestChart(), ociRegistryAuth{})
+	assert.NoError(t, err)
+	assert.Empty(t, appVersion)
+}
+
+func TestChartAppVersionHelmFailure(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Error = errors.New("exit status 1")
+	mockExecutor.Stderr = []byte("Error: chart \"test-chart\" version \"1.0.0\" not found\n")
+
+	_, err := chartAppVersion(context.Background(), mockExecutor, createTestChart(), ociRegistryAuth{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestShowChartArgsOCI(t *testing.T) {
+	chart := createTestChart()
+	chart.RepoURL = "oci://registry.example.com/charts/"
+
+	args := showChartArgs(chart, ociRegistryAuth{RegistryConfig: "/tmp/config.json"})
+	assert.Equal(t, []string{"show", "chart", "oci://registry.example.com/charts/test-chart", "--registry-config", "/tmp/config.json", "--version", "1.0.0"}, args)
+}
+
+func TestShowChartArgsVendoredChart(t *testing.T) {
+	chart := createTestChart()
+	chart.ChartName = "charts/web"
+	chart.RepoURL = ""
+
+	assert.Equal(t, []string{"show", "chart", "charts/web"}, showChartArgs(chart, ociRegistryAuth{}))
+}
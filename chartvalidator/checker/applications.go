@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+)
+
+// isApplication reports whether a parsed document is an Argo CD Application
+func isApplication(doc any) bool {
+	m, ok := doc.(map[string]any)
+	return ok && m["kind"] == "Application"
+}
+
+// extractApplicationChart reads the Helm chart of an Argo CD Application from
+// spec.source, rendered into the destination namespace. The first two
+// helm.valueFiles become the base and override values files, prefixed with
+// srcPrefix. ok is false for Applications that don't deploy a Helm chart from
+// a chart repository, e.g. plain manifests from a git path. Multi-source
+// Applications, using spec.sources, are rejected.
+func extractApplicationChart(doc any, env, srcPrefix string) (chart ChartRenderParams, ok bool, err error) {
+	m, _ := doc.(map[string]any)
+	spec, _ := m["spec"].(map[string]any)
+	if _, multiSource := spec["sources"]; multiSource {
+		// The values of a multi-source Application usually come from another
+		// source through a $ref, which can't be resolved to a local file
+		metadata, _ := m["metadata"].(map[string]any)
+		return ChartRenderParams{}, false, fmt.Errorf("application %q uses spec.sources, multi-source applications are not supported", str(metadata["name"]))
+	}
+	source, _ := spec["source"].(map[string]any)
+	destination, _ := spec["destination"].(map[string]any)
+	if str(source["chart"]) == "" {
+		return ChartRenderParams{}, false, nil
+	}
+
+	helm, _ := source["helm"].(map[string]any)
+	valueFiles, _ := helm["valueFiles"].([]any)
+	if len(valueFiles) > 2 {
+		return ChartRenderParams{}, false, fmt.Errorf("application for chart %q has %d helm.valueFiles, at most a base and an override file are supported", str(source["chart"]), len(valueFiles))
+	}
+	var baseValues, overrideValues string
+	if len(valueFiles) > 0 {
+		baseValues = str(valueFiles[0])
+	}
+	if len(valueFiles) > 1 {
+		overrideValues = str(valueFiles[1])
+	}
+
+	return ChartRenderParams{
+		Env:            env,
+		ChartName:      prefixChartPath(srcPrefix, str(source["repoURL"]), str(source["chart"])),
+		RepoURL:        str(source["repoURL"]),
+		ChartVersion:   str(source["targetRevision"]),
+		BaseValuesFile: prefixValuesFile(srcPrefix, baseValues),
+		ValuesOverride: prefixValuesFile(srcPrefix, overrideValues),
+		Namespace:      str(destination["namespace"]),
+		InlineValues:   str(helm["values"]),
+	}, true, nil
+}
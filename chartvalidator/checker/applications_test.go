@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindChartsIncludesApplications(t *testing.T) {
+	envDir := t.TempDir()
+	fixture, err := os.ReadFile("test_data/standalone_application.yaml")
+	assert.NoError(t, err)
+	createTempManifestFile(t, filepath.Join(envDir, "dev", "appsets"), "ingress-nginx.yaml", string(fixture))
+	createTestAppset(t, envDir, "dev", "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`)
+
+	// Applications are opt-in, by default only *appset.yaml files are read
+	charts, err := findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, false, false)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+
+	charts, err = findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, false, true)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 2)
+	assert.Contains(t, charts, ChartRenderParams{
+		Env:            "dev",
+		ChartName:      "ingress-nginx",
+		RepoURL:        "https://kubernetes.github.io/ingress-nginx",
+		ChartVersion:   "4.10.0",
+		BaseValuesFile: defaultSrcPrefix + "env/dev/ingress-nginx/values.yaml",
+		ValuesOverride: defaultSrcPrefix + "env/dev/ingress-nginx/override.yaml",
+		Namespace:      "ingress-nginx",
+		InlineValues:   "controller:\n  replicaCount: 2\n",
+	})
+}
+
+func TestFindChartsReadsEveryApplicationOfAFile(t *testing.T) {
+	envDir := t.TempDir()
+	application := func(chart string) string {
+		return `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: ` + chart + `
+spec:
+  source:
+    chart: ` + chart + `
+    repoURL: https://example.com/charts
+    targetRevision: 1.0.0
+  destination:
+    namespace: ` + chart + `
+`
+	}
+	createTempManifestFile(t, filepath.Join(envDir, "dev", "appsets"), "applications.yaml", application("web")+"---\n"+application("api"))
+
+	charts, err := findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, false, true)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 2)
+	assert.Equal(t, "web", charts[0].ChartName)
+	assert.Equal(t, "api", charts[1].ChartName)
+}
+
+func TestExtractApplicationChart(t *testing.T) {
+	t.Run("git path application is skipped", func(t *testing.T) {
+		doc := map[string]any{"kind": "Application", "spec": map[string]any{"source": map[string]any{
+			"repoURL": "https://example.com/deployments.git",
+			"path":    "apps/web",
+		}}}
+		_, ok, err := extractApplicationChart(doc, "dev", defaultSrcPrefix)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("too many value files", func(t *testing.T) {
+		doc := map[string]any{"kind": "Application", "spec": map[string]any{"source": map[string]any{
+			"chart": "web",
+			"helm":  map[string]any{"valueFiles": []any{"a.yaml", "b.yaml", "c.yaml"}},
+		}}}
+		_, _, err := extractApplicationChart(doc, "dev", defaultSrcPrefix)
+		assert.EqualError(t, err, `application for chart "web" has 3 helm.valueFiles, at most a base and an override file are supported`)
+	})
+
+	t.Run("multi-source application", func(t *testing.T) {
+		doc := map[string]any{"kind": "Application", "metadata": map[string]any{"name": "web"}, "spec": map[string]any{"sources": []any{
+			map[string]any{"chart": "web", "repoURL": "https://example.com/charts", "helm": map[string]any{"valueFiles": []any{"$values/env/dev/values.yaml"}}},
+			map[string]any{"repoURL": "https://example.com/deployments.git", "ref": "values"},
+		}}}
+		_, _, err := extractApplicationChart(doc, "dev", defaultSrcPrefix)
+		assert.EqualError(t, err, `application "web" uses spec.sources, multi-source applications are not supported`)
+	})
+}
+
+func TestExtractApplicationChartWithoutAllValueFiles(t *testing.T) {
+	application := func(valueFiles ...any) map[string]any {
+		return map[string]any{"kind": "Application", "spec": map[string]any{"source": map[string]any{
+			"chart":          "web",
+			"repoURL":        "https://example.com/charts",
+			"targetRevision": "1.0.0",
+			"helm":           map[string]any{"valueFiles": valueFiles},
+		}}}
+	}
+
+	chart, ok, err := extractApplicationChart(application(), "dev", defaultSrcPrefix)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Empty(t, chart.BaseValuesFile, "Expected no values file rather than the bare prefix")
+	assert.Empty(t, chart.ValuesOverride)
+
+	chart, _, err = extractApplicationChart(application("env/dev/web/values.yaml"), "dev", defaultSrcPrefix)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSrcPrefix+"env/dev/web/values.yaml", chart.BaseValuesFile)
+	assert.Empty(t, chart.ValuesOverride)
+}
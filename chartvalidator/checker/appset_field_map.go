@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// appsetFieldMap names the ApplicationSet element keys that hold each chart
+// field. The -field-map file uses the same layout and only needs the fields
+// whose keys differ from the defaults:
+//
+//	chartName: chart
+//	chartVersion: version
+//	baseValuesFile: valuesFile
+type appsetFieldMap struct {
+	ChartName      string `yaml:"chartName"`
+	RepoURL        string `yaml:"repoURL"`
+	ChartVersion   string `yaml:"chartVersion"`
+	BaseValuesFile string `yaml:"baseValuesFile"`
+	ValuesOverride string `yaml:"valuesOverride"`
+	Namespace      string `yaml:"namespace"`
+}
+
+// defaultAppsetFieldMap reads every field from the element key of the same name
+var defaultAppsetFieldMap = appsetFieldMap{
+	ChartName:      "chartName",
+	RepoURL:        "repoURL",
+	ChartVersion:   "chartVersion",
+	BaseValuesFile: "baseValuesFile",
+	ValuesOverride: "valuesOverride",
+	Namespace:      "namespace",
+}
+
+// appsetFields are the element keys read by extractChartInfo
+var appsetFields = defaultAppsetFieldMap
+
+// keys returns the element keys in field order
+func (fields appsetFieldMap) keys() []string {
+	return []string{fields.ChartName, fields.RepoURL, fields.ChartVersion, fields.BaseValuesFile, fields.ValuesOverride, fields.Namespace}
+}
+
+// loadAppsetFieldMap reads a field map, keeping the default key for every
+// field the file leaves out
+func loadAppsetFieldMap(path string) (appsetFieldMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return appsetFieldMap{}, fmt.Errorf("failed to read field map: %w", err)
+	}
+
+	fields := defaultAppsetFieldMap
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&fields); err != nil {
+		return appsetFieldMap{}, fmt.Errorf("failed to parse field map %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for _, key := range fields.keys() {
+		if key == "" {
+			return appsetFieldMap{}, fmt.Errorf("field map %s maps a field to an empty key", path)
+		}
+		if seen[key] {
+			return appsetFieldMap{}, fmt.Errorf("field map %s maps more than one field to key %q", path, key)
+		}
+		seen[key] = true
+	}
+	return fields, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAppsetFieldMapKeepsDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fields.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("chartName: chart\nchartVersion: version\n"), 0644))
+
+	fields, err := loadAppsetFieldMap(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, appsetFieldMap{
+		ChartName:      "chart",
+		RepoURL:        "repoURL",
+		ChartVersion:   "version",
+		BaseValuesFile: "baseValuesFile",
+		ValuesOverride: "valuesOverride",
+		Namespace:      "namespace",
+	}, fields)
+}
+
+func TestLoadAppsetFieldMapErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"unknown field", "chartNmae: chart\n", "field chartNmae not found"},
+		{"empty key", "repoURL: \"\"\n", "maps a field to an empty key"},
+		{"shared key", "chartName: name\nrepoURL: name\n", `maps more than one field to key "name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fields.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0644))
+
+			_, err := loadAppsetFieldMap(path)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestFindChartsInAppsetsWithCustomFieldMap(t *testing.T) {
+	envDir := t.TempDir()
+	createTestAppset(t, envDir, "dev", "web", `      - chart: web
+        repo: https://example.com/charts
+        version: 1.2.3
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`)
+
+	appsetFields = appsetFieldMap{
+		ChartName:      "chart",
+		RepoURL:        "repo",
+		ChartVersion:   "version",
+		BaseValuesFile: "baseValuesFile",
+		ValuesOverride: "valuesOverride",
+		Namespace:      "namespace",
+	}
+	defer func() { appsetFields = defaultAppsetFieldMap }()
+
+	charts, err := findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, true, false)
+
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+	assert.Equal(t, "web", charts[0].ChartName)
+	assert.Equal(t, "https://example.com/charts", charts[0].RepoURL)
+	assert.Equal(t, "1.2.3", charts[0].ChartVersion)
+	assert.Equal(t, defaultSrcPrefix+"env/dev/values.yaml", charts[0].BaseValuesFile)
+}
+
+func TestStrictAppsetSuggestsMappedKeys(t *testing.T) {
+	appsetFields.ChartVersion = "version"
+	defer func() { appsetFields = defaultAppsetFieldMap }()
+
+	err := checkUnknownElementKeys(map[string]any{"chartName": "web", "chartVersion": "1.0.0"})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown key "chartVersion"`)
+	assert.NoError(t, checkUnknownElementKeys(map[string]any{"chartName": "web", "version": "1.0.0"}))
+}
@@ -1,58 +1,38 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
-
-	"gopkg.in/yaml.v3"
 )
 
-// findChartsInAppsets scans ApplicationSet files and extracts chart information
-func findChartsInAppsets(envDir, selectedEnv string) ([]ChartRenderParams, error) {
+// findChartsInAppsets scans ApplicationSet files and extracts chart information.
+// In strict mode unknown element keys are reported as errors. With
+// includeApplications every YAML file is read and Argo CD Applications are
+// recognised alongside ApplicationSets. srcPrefix is prepended to the values
+// file paths.
+func findChartsInAppsets(envDir, srcPrefix string, selectedEnvs []string, strict, includeApplications bool) ([]ChartRenderParams, error) {
 	const suffix = "appset.yaml"
-	var out []ChartRenderParams
 
-	fmt.Println("Scanning environments in", envDir)
+	fmt.Fprintln(logOutput, "Scanning environments in", envDir)
 
-	if selectedEnv != "" {
-		envPath := filepath.Join(envDir, selectedEnv)
-		ok, err := existsDir(envPath)
-		if err != nil {
-			return nil, err
-		}
-		if !ok {
-			return nil, fmt.Errorf("environment %q not found in %s", selectedEnv, envDir)
-		}
-		ch, err := processEnvironment(selectedEnv, envPath, suffix)
-		if err != nil {
-			return nil, err
-		}
-		return ch, nil
-	}
-
-	entries, err := os.ReadDir(envDir)
-	if err != nil {
-		return nil, err
-	}
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		envName := e.Name()
-		envPath := filepath.Join(envDir, envName)
-		ch, err := processEnvironment(envName, envPath, suffix)
-		if err != nil {
-			return nil, err
+	return forEachEnvironment(envDir, selectedEnvs, func(envName, envPath string) ([]ChartRenderParams, error) {
+		if includeApplications {
+			return processEnvironment(envName, envPath, ".yaml", srcPrefix, strict, true)
 		}
-		out = append(out, ch...)
-	}
-	return out, nil
+		return processEnvironment(envName, envPath, suffix, srcPrefix, strict, false)
+	})
 }
 
 // processEnvironment extracts charts from a single environment directory
-func processEnvironment(envName, envPath, suffix string) ([]ChartRenderParams, error) {
+func processEnvironment(envName, envPath, suffix, srcPrefix string, strict, includeApplications bool) ([]ChartRenderParams, error) {
 	appsetsPath := filepath.Join(envPath, "appsets")
 	ok, err := existsDir(appsetsPath)
 	if err != nil || !ok {
@@ -66,17 +46,49 @@ func processEnvironment(envName, envPath, suffix string) ([]ChartRenderParams, e
 
 	var charts []ChartRenderParams
 	for _, f := range files {
-		data, err := os.ReadFile(f)
+		// A file may hold several documents, e.g. an Application per chart
+		docs, err := readYAMLDocuments(f)
 		if err != nil {
-			return nil, err
-		}
-		var node any
-		if err := yaml.Unmarshal(data, &node); err != nil {
 			return nil, fmt.Errorf("failed to parse YAML %s: %w", f, err)
 		}
-		elems := extractElements(node)
+		var found []map[string]any
+		for _, doc := range docs {
+			if includeApplications && isApplication(doc) {
+				chart, ok, err := extractApplicationChart(doc, envName, srcPrefix)
+				if err != nil {
+					return nil, fmt.Errorf("invalid application in %s: %w", f, err)
+				}
+				if !ok {
+					logEngineWarning("Discovery", -1, fmt.Sprintf("application in %s doesn't deploy a Helm chart from a repository, skipping it", f))
+					continue
+				}
+				if err := validateValuesPaths(chart); err != nil {
+					return nil, fmt.Errorf("invalid chart %s in %s: %w", chart.ChartName, f, err)
+				}
+				charts = append(charts, chart)
+				continue
+			}
+			elements, unresolved := extractElements(doc)
+			for _, generator := range unresolved {
+				logEngineWarning("Discovery", -1, fmt.Sprintf("%s generator in %s can't be resolved without a cluster or repository, its charts are skipped", generator, f))
+			}
+			found = append(found, elements...)
+		}
+		elems, duplicates := dedupeElements(found)
+		for _, el := range duplicates {
+			logEngineWarning("Discovery", -1, fmt.Sprintf("duplicate element for chart %q in %s, processing it once", str(el[appsetFields.ChartName]), f))
+		}
 		for _, el := range elems {
-			charts = append(charts, extractChartInfo(el, envName))
+			if strict {
+				if err := checkUnknownElementKeys(el); err != nil {
+					return nil, fmt.Errorf("invalid element in %s: %w", f, err)
+				}
+			}
+			chart := extractChartInfo(el, envName, srcPrefix)
+			if err := validateValuesPaths(chart); err != nil {
+				return nil, fmt.Errorf("invalid chart %s in %s: %w", chart.ChartName, f, err)
+			}
+			charts = append(charts, chart)
 		}
 	}
 	return charts, nil
@@ -113,52 +125,247 @@ func existsDir(path string) (bool, error) {
 	return info.IsDir(), nil
 }
 
-// extractElements extracts the list elements from an ApplicationSet document
-func extractElements(doc any) []map[string]any {
-	// Navigate: spec.generators[0].list.elements
+// extractElements extracts the parameter sets of every generator of an
+// ApplicationSet document. list generators are read directly and matrix
+// generators combine the elements of their nested generators. Generators that
+// can only be resolved against a cluster or repository, such as git or
+// clusters, are returned by name in unresolved.
+func extractElements(doc any) (elements []map[string]any, unresolved []string) {
+	// Navigate: spec.generators[*]
 	m, ok := doc.(map[string]any)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 	spec, _ := m["spec"].(map[string]any)
 	if spec == nil {
-		return nil
+		return nil, nil
 	}
 	gens, _ := spec["generators"].([]any)
-	if len(gens) == 0 {
-		return nil
+	for _, g := range gens {
+		gen, _ := g.(map[string]any)
+		if gen == nil {
+			continue
+		}
+		elems, skipped := generatorElements(gen)
+		elements = append(elements, elems...)
+		unresolved = append(unresolved, skipped...)
 	}
-	gen0, _ := gens[0].(map[string]any)
-	if gen0 == nil {
-		return nil
+	return elements, unresolved
+}
+
+// generatorElements resolves the parameter sets of a single generator
+func generatorElements(gen map[string]any) (elements []map[string]any, unresolved []string) {
+	if lst, ok := gen["list"].(map[string]any); ok {
+		elems, _ := lst["elements"].([]any)
+		for _, e := range elems {
+			if mm, ok := e.(map[string]any); ok {
+				elements = append(elements, mm)
+			}
+		}
+		return elements, nil
 	}
-	lst, _ := gen0["list"].(map[string]any)
-	if lst == nil {
-		return nil
+
+	if matrix, ok := gen["matrix"].(map[string]any); ok {
+		// Every combination of the nested generators' elements is one parameter set
+		combined := []map[string]any{{}}
+		nested, _ := matrix["generators"].([]any)
+		for _, n := range nested {
+			child, _ := n.(map[string]any)
+			elems, skipped := generatorElements(child)
+			if len(skipped) > 0 {
+				// Without all of its generators the matrix can't be resolved at all
+				return nil, []string{"matrix of " + strings.Join(skipped, ", ")}
+			}
+			var next []map[string]any
+			for _, left := range combined {
+				for _, right := range elems {
+					merged := maps.Clone(left)
+					maps.Copy(merged, right)
+					next = append(next, merged)
+				}
+			}
+			combined = next
+		}
+		if len(nested) == 0 {
+			return nil, nil
+		}
+		return combined, nil
 	}
-	elems, _ := lst["elements"].([]any)
-	if len(elems) == 0 {
-		return nil
+
+	return nil, []string{generatorName(gen)}
+}
+
+// generatorName names a generator by its type key, e.g. git or clusters
+func generatorName(gen map[string]any) string {
+	var names []string
+	for key := range gen {
+		// selector and template refine a generator rather than being one
+		if key != "selector" && key != "template" {
+			names = append(names, key)
+		}
+	}
+	if len(names) == 0 {
+		return "empty"
 	}
-	var out []map[string]any
-	for _, e := range elems {
-		if mm, ok := e.(map[string]any); ok {
-			out = append(out, mm)
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// dedupeElements drops elements identical to an earlier one, e.g. from a
+// copy-paste error, returning the unique elements and the dropped duplicates
+func dedupeElements(elems []map[string]any) (unique, duplicates []map[string]any) {
+	for _, el := range elems {
+		if slices.ContainsFunc(unique, func(seen map[string]any) bool { return reflect.DeepEqual(seen, el) }) {
+			duplicates = append(duplicates, el)
+			continue
 		}
+		unique = append(unique, el)
 	}
-	return out
+	return unique, duplicates
 }
 
-// extractChartInfo extracts Chart information from an ApplicationSet element
-func extractChartInfo(el map[string]any, env string) ChartRenderParams {
+// extractChartInfo extracts Chart information from an ApplicationSet element,
+// prefixing its values file paths and the path of a vendored chart with srcPrefix
+func extractChartInfo(el map[string]any, env, srcPrefix string) ChartRenderParams {
+	repoURL := str(el[appsetFields.RepoURL])
 	return ChartRenderParams{
 		Env:            env,
-		ChartName:      str(el["chartName"]),
-		RepoURL:        str(el["repoURL"]),
-		ChartVersion:   str(el["chartVersion"]),
-		BaseValuesFile: srcPrefix + str(el["baseValuesFile"]),
-		ValuesOverride: srcPrefix + str(el["valuesOverride"]),
+		ChartName:      prefixChartPath(srcPrefix, repoURL, str(el[appsetFields.ChartName])),
+		RepoURL:        repoURL,
+		ChartVersion:   str(el[appsetFields.ChartVersion]),
+		BaseValuesFile: prefixValuesFile(srcPrefix, str(el[appsetFields.BaseValuesFile])),
+		ValuesOverride: prefixValuesFile(srcPrefix, str(el[appsetFields.ValuesOverride])),
+		Namespace:      str(el[appsetFields.Namespace]),
+	}
+}
+
+// prefixValuesFile resolves a values file path against the srcPrefix
+// directory, leaving an unset path empty so that no values file is passed to helm
+func prefixValuesFile(srcPrefix, path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(srcPrefix, path)
+}
+
+// prefixChartPath resolves the chart of an element without a repo, a
+// vendored chart directory, against the srcPrefix like its values files
+func prefixChartPath(srcPrefix, repoURL, chart string) string {
+	if repoURL != "" {
+		return chart
+	}
+	return prefixValuesFile(srcPrefix, chart)
+}
+
+// checkUnknownElementKeys returns an error naming every key of an element that
+// extractChartInfo does not read, suggesting the known key it most likely misspells
+func checkUnknownElementKeys(el map[string]any) error {
+	known := appsetFields.keys()
+	var problems []string
+	for key := range el {
+		if slices.Contains(known, key) {
+			continue
+		}
+		problem := fmt.Sprintf("unknown key %q", key)
+		if suggestion := closestKey(key, known); suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("element for chart %q has %s", str(el[appsetFields.ChartName]), strings.Join(problems, ", "))
+}
+
+// closestKey returns the candidate within a small edit distance of key, if any
+func closestKey(key string, candidates []string) string {
+	const maxDistance = 2
+	best, bestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// validateValuesPaths ensures the values files of a chart stay within valuesRoot
+func validateValuesPaths(chart ChartRenderParams) error {
+	if err := ensureWithinRoot(valuesRoot, chart.BaseValuesFile); err != nil {
+		return fmt.Errorf("baseValuesFile: %w", err)
+	}
+	if err := ensureWithinRoot(valuesRoot, chart.ValuesOverride); err != nil {
+		return fmt.Errorf("valuesOverride: %w", err)
+	}
+	return nil
+}
+
+// ensureWithinRoot returns an error when path resolves to a location outside
+// of root. Symlinks are resolved first, so a link inside root can't point out of it.
+func ensureWithinRoot(root, path string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve root %s: %w", root, err)
+	}
+	if absRoot, err = resolveSymlinks(absRoot); err != nil {
+		return fmt.Errorf("failed to resolve root %s: %w", root, err)
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if absPath, err = resolveSymlinks(absPath); err != nil {
+		return fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path %q resolves outside of %s", path, absRoot)
+	}
+	return nil
+}
+
+// resolveSymlinks resolves the symlinks in an absolute path. Missing files are
+// reported elsewhere, so only the part of the path that exists is resolved.
+func resolveSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	resolvedParent, err := resolveSymlinks(parent)
+	if err != nil {
+		return "", err
 	}
+	return filepath.Join(resolvedParent, filepath.Base(path)), nil
 }
 
 // str converts any value to string, handling nil safely
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureWithinRoot(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name        string
+		path        string
+		expectError bool
+	}{
+		{
+			name:        "values file inside root",
+			path:        filepath.Join(root, "env", "dev", "values.yaml"),
+			expectError: false,
+		},
+		{
+			name:        "traversal out of root",
+			path:        filepath.Join(root, "../../etc/passwd"),
+			expectError: true,
+		},
+		{
+			name:        "traversal that stays inside root",
+			path:        filepath.Join(root, "env/../charts/values.yaml"),
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ensureWithinRoot(root, tt.path)
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "resolves outside of")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestExtractChartInfoRejectsTraversal(t *testing.T) {
+	element := map[string]any{
+		"chartName":      "test-chart",
+		"baseValuesFile": "../../etc/passwd",
+		"valuesOverride": "env/dev/override.yaml",
+	}
+
+	chart := extractChartInfo(element, "dev", defaultSrcPrefix)
+	err := validateValuesPaths(chart)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "baseValuesFile")
+
+	element["baseValuesFile"] = "env/dev/values.yaml"
+	chart = extractChartInfo(element, "dev", defaultSrcPrefix)
+	assert.NoError(t, validateValuesPaths(chart))
+}
+
+// Helper function to write an ApplicationSet with the given list elements into envDir/<env>/appsets
+func createTestAppset(t *testing.T, envDir, env, name, elements string) {
+	appset := `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: ` + name + `
+spec:
+  generators:
+  - list:
+      elements:
+` + elements
+	createTempManifestFile(t, filepath.Join(envDir, env, "appsets"), name+"-appset.yaml", appset)
+}
+
+func TestStrictAppsetReportsUnknownKeys(t *testing.T) {
+	envDir := t.TempDir()
+	createTestAppset(t, envDir, "dev", "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVesion: 1.0.0
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`)
+
+	// Without strict mode the typo silently yields an empty version
+	charts, err := findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, false, false)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+	assert.Empty(t, charts[0].ChartVersion)
+
+	_, err = findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, true, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown key "chartVesion" (did you mean "chartVersion"?)`)
+}
+
+func TestCheckUnknownElementKeys(t *testing.T) {
+	valid := map[string]any{
+		"chartName":      "web",
+		"repoURL":        "https://example.com/charts",
+		"chartVersion":   "1.0.0",
+		"baseValuesFile": "values.yaml",
+		"valuesOverride": "override.yaml",
+	}
+	assert.NoError(t, checkUnknownElementKeys(valid))
+
+	unrelated := map[string]any{"chartName": "web", "cluster": "in-cluster"}
+	err := checkUnknownElementKeys(unrelated)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown key "cluster"`)
+	assert.NotContains(t, err.Error(), "did you mean")
+}
+
+func TestDuplicateAppsetElementsAreProcessedOnce(t *testing.T) {
+	envDir := t.TempDir()
+	element := `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`
+	createTestAppset(t, envDir, "dev", "web", element+element)
+
+	logs := captureLogs(t, "info")
+
+	charts, err := findChartsInAppsets(envDir, defaultSrcPrefix, []string{"dev"}, false, false)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+	assert.Equal(t, "web", charts[0].ChartName)
+	assert.Contains(t, logs.String(), `duplicate element for chart "web"`)
+}
+
+func TestDedupeElementsKeepsDistinctElements(t *testing.T) {
+	dev := map[string]any{"chartName": "web", "chartVersion": "1.0.0"}
+	prod := map[string]any{"chartName": "web", "chartVersion": "2.0.0"}
+
+	unique, duplicates := dedupeElements([]map[string]any{dev, prod, {"chartName": "web", "chartVersion": "1.0.0"}})
+
+	assert.Equal(t, []map[string]any{dev, prod}, unique)
+	assert.Equal(t, []map[string]any{dev}, duplicates)
+}
+
+func TestProcessEnvironmentWithMultipleGenerators(t *testing.T) {
+	envDir := t.TempDir()
+	fixture, err := os.ReadFile("test_data/multi_generator_appset.yaml")
+	assert.NoError(t, err)
+	createTempManifestFile(t, filepath.Join(envDir, "dev", "appsets"), "platform-appset.yaml", string(fixture))
+
+	logs := captureLogs(t, "info")
+
+	charts, err := processEnvironment("dev", filepath.Join(envDir, "dev"), "appset.yaml", defaultSrcPrefix, true, false)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []ChartRenderParams{
+		{Env: "dev", ChartName: "web", RepoURL: "https://example.com/charts", ChartVersion: "1.0.0", BaseValuesFile: defaultSrcPrefix + "env/dev/web/values.yaml", ValuesOverride: defaultSrcPrefix + "env/dev/web/override.yaml"},
+		{Env: "dev", ChartName: "api", RepoURL: "https://example.com/internal", ChartVersion: "2.0.0", BaseValuesFile: defaultSrcPrefix + "env/dev/shared/values.yaml", ValuesOverride: defaultSrcPrefix + "env/dev/shared/override.yaml"},
+		{Env: "dev", ChartName: "worker", RepoURL: "https://example.com/internal", ChartVersion: "2.1.0", BaseValuesFile: defaultSrcPrefix + "env/dev/shared/values.yaml", ValuesOverride: defaultSrcPrefix + "env/dev/shared/override.yaml"},
+	}, charts)
+	assert.Contains(t, logs.String(), "git generator in")
+	assert.Contains(t, logs.String(), "its charts are skipped")
+}
+
+func TestExtractElementsSkipsUnresolvableMatrix(t *testing.T) {
+	doc := map[string]any{
+		"spec": map[string]any{
+			"generators": []any{
+				map[string]any{"matrix": map[string]any{"generators": []any{
+					map[string]any{"clusters": map[string]any{}},
+					map[string]any{"list": map[string]any{"elements": []any{map[string]any{"chartName": "web"}}}},
+				}}},
+				map[string]any{"merge": map[string]any{}, "template": map[string]any{}},
+			},
+		},
+	}
+
+	elements, unresolved := extractElements(doc)
+
+	assert.Empty(t, elements)
+	assert.Equal(t, []string{"matrix of clusters", "merge"}, unresolved)
+}
+
+func TestProcessEnvironmentReadsEveryListGenerator(t *testing.T) {
+	envDir := t.TempDir()
+	fixture, err := os.ReadFile("test_data/split_list_generators_appset.yaml")
+	assert.NoError(t, err)
+	createTempManifestFile(t, filepath.Join(envDir, "dev", "appsets"), "web-appset.yaml", string(fixture))
+
+	charts, err := processEnvironment("dev", filepath.Join(envDir, "dev"), "appset.yaml", defaultSrcPrefix, true, false)
+	assert.NoError(t, err)
+
+	var versions []string
+	for _, chart := range charts {
+		versions = append(versions, chart.ChartVersion)
+	}
+	assert.Equal(t, []string{"1.0.0", "2.0.0"}, versions, "Expected the elements of both list generators")
+}
+
+func TestExtractChartInfoReadsNamespace(t *testing.T) {
+	chart := extractChartInfo(map[string]any{"chartName": "web", "namespace": "frontend"}, "dev", defaultSrcPrefix)
+	assert.Equal(t, "frontend", chart.Namespace)
+
+	chart = extractChartInfo(map[string]any{"chartName": "web"}, "dev", defaultSrcPrefix)
+	assert.Empty(t, chart.Namespace)
+	assert.Empty(t, chart.BaseValuesFile, "Expected no values file rather than the bare prefix")
+	assert.NoError(t, checkUnknownElementKeys(map[string]any{"chartName": "web", "namespace": "frontend"}))
+}
+
+func TestFindChartsResolvesValuesFilesFromSrcPrefix(t *testing.T) {
+	repoDir := t.TempDir()
+	envDir := filepath.Join(repoDir, "env")
+	createTestAppset(t, envDir, "dev", "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`)
+	defer func() { valuesRoot = defaultSrcPrefix }()
+
+	// The prefix is a directory, with or without a trailing separator
+	for _, prefix := range []string{repoDir, repoDir + string(filepath.Separator)} {
+		valuesRoot = repoDir
+		charts, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, SrcPrefix: prefix})
+
+		assert.NoError(t, err)
+		assert.Len(t, charts, 1)
+		assert.Equal(t, filepath.Join(repoDir, "env", "dev", "values.yaml"), charts[0].BaseValuesFile)
+		assert.Equal(t, filepath.Join(repoDir, "env", "dev", "override.yaml"), charts[0].ValuesOverride)
+
+		// The default values root doesn't contain the temporary repository
+		valuesRoot = defaultSrcPrefix
+		_, err = findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, SrcPrefix: prefix})
+		assert.ErrorContains(t, err, "resolves outside of")
+	}
+}
+
+func TestPrefixValuesFile(t *testing.T) {
+	assert.Equal(t, "../env/dev/values.yaml", prefixValuesFile("..", "env/dev/values.yaml"))
+	assert.Equal(t, "../env/dev/values.yaml", prefixValuesFile("../", "env/dev/values.yaml"))
+	assert.Equal(t, "/repo/env/dev/values.yaml", prefixValuesFile("/repo", "env/dev/values.yaml"))
+	assert.Equal(t, "", prefixValuesFile("/repo", ""))
+}
+
+func TestExtractChartInfoResolvesVendoredChartFromSrcPrefix(t *testing.T) {
+	chart := extractChartInfo(map[string]any{"chartName": "charts/web"}, "dev", "/repo")
+	assert.Equal(t, "/repo/charts/web", chart.ChartName)
+
+	chart = extractChartInfo(map[string]any{"chartName": "web", "repoURL": "https://example.com/charts"}, "dev", "/repo")
+	assert.Equal(t, "web", chart.ChartName, "Expected a chart from a repo to keep its name")
+}
+
+func TestEnsureWithinRootResolvesSymlinks(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(outside, "secrets.yaml"), []byte("password: hunter2\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "env", "dev"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "env", "dev", "values.yaml"), []byte("replicas: 1\n"), 0644))
+
+	// A file and a directory inside the root both linking out of it
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "secrets.yaml"), filepath.Join(root, "env", "dev", "linked.yaml")))
+	assert.NoError(t, os.Symlink(outside, filepath.Join(root, "shared")))
+	// A link that stays inside the root
+	assert.NoError(t, os.Symlink(filepath.Join(root, "env", "dev", "values.yaml"), filepath.Join(root, "env", "dev", "alias.yaml")))
+
+	assert.ErrorContains(t, ensureWithinRoot(root, filepath.Join(root, "env", "dev", "linked.yaml")), "resolves outside of")
+	assert.ErrorContains(t, ensureWithinRoot(root, filepath.Join(root, "shared", "secrets.yaml")), "resolves outside of")
+	assert.ErrorContains(t, ensureWithinRoot(root, filepath.Join(root, "shared", "missing.yaml")), "resolves outside of")
+	assert.NoError(t, ensureWithinRoot(root, filepath.Join(root, "env", "dev", "alias.yaml")))
+	assert.NoError(t, ensureWithinRoot(root, filepath.Join(root, "env", "dev", "missing.yaml")))
+
+	// The root itself may be reached through a symlink
+	linkedRoot := filepath.Join(t.TempDir(), "repo")
+	assert.NoError(t, os.Symlink(root, linkedRoot))
+	assert.NoError(t, ensureWithinRoot(linkedRoot, filepath.Join(root, "env", "dev", "values.yaml")))
+}
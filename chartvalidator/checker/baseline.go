@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// baselineResultsFile is written to the output directory by run-checks with
+// the result records of the run, so the directory can serve as a -baseline
+const baselineResultsFile = "results.ndjson"
+
+// baselineChart identifies the charts of the result records in a baseline
+type baselineChart struct {
+	Env     string
+	Chart   string
+	Version string
+}
+
+// baselinePath returns where the baseline render of a rendered manifest lives.
+// Rendered manifests are named after the env, chart and version, and a hash of
+// the chart, so a baseline written by an earlier run uses the same name.
+func baselinePath(baselineDir, manifestPath string) string {
+	return filepath.Join(baselineDir, filepath.Base(manifestPath))
+}
+
+// matchesBaseline reports whether the rendered manifest is byte-identical to the
+// stored baseline. A missing baseline file counts as changed.
+func matchesBaseline(baselineDir string, manifestPath string) (bool, error) {
+	baseline, err := os.ReadFile(baselinePath(baselineDir, manifestPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	rendered, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read rendered manifest: %w", err)
+	}
+
+	return bytes.Equal(baseline, rendered), nil
+}
+
+// checkBaselineDir returns an error for a baseline inside the output
+// directory, which is cleared before the run gets to compare against it
+func checkBaselineDir(baselineDir, outputDir string) error {
+	if baselineDir == "" {
+		return nil
+	}
+	if err := ensureWithinRoot(outputDir, baselineDir); err == nil {
+		return fmt.Errorf("baseline %s is inside the output directory %s, which is cleared before rendering: move the earlier output elsewhere first", baselineDir, outputDir)
+	}
+	return nil
+}
+
+// loadBaselineResults reads the result records of the run that wrote the
+// baseline, grouped by chart
+func loadBaselineResults(baselineDir string) (map[baselineChart][]resultRecord, error) {
+	records, err := readResultRecords(filepath.Join(baselineDir, baselineResultsFile))
+	if err != nil {
+		return nil, err
+	}
+	results := map[baselineChart][]resultRecord{}
+	for _, record := range records {
+		chart := baselineChart{Env: record.Env, Chart: record.Chart, Version: record.ChartVersion}
+		results[chart] = append(results[chart], record)
+	}
+	return results, nil
+}
+
+// writeBaselineResults writes the result records of a run next to its rendered manifests
+func writeBaselineResults(outputDir string, results []AppCheckResult) error {
+	f, err := os.Create(filepath.Join(outputDir, baselineResultsFile))
+	if err != nil {
+		return fmt.Errorf("failed to write baseline results: %w", err)
+	}
+	defer f.Close()
+
+	records := make([]resultRecord, 0, len(results))
+	for _, result := range results {
+		records = append(records, newResultRecord(result))
+	}
+	if err := writeResultRecords(f, records); err != nil {
+		return fmt.Errorf("failed to write baseline results: %w", err)
+	}
+	return nil
+}
+
+// baselineResult turns a result record of the baseline back into a result of
+// chart, marked as skipped because its checks weren't run again
+func baselineResult(chart ChartRenderParams, record resultRecord) AppCheckResult {
+	result := AppCheckResult{
+		Chart:         chart,
+		Image:         record.Image,
+		OriginalImage: record.OriginalImage,
+		Sources:       record.Sources,
+		Skipped:       true,
+		Missing:       record.Missing,
+		AccessDenied:  record.Status == statusAccessDenied,
+		Warning:       record.Status == statusWarning,
+	}
+	if record.Error != "" {
+		result.Error = errors.New(record.Error)
+	}
+	return result
+}
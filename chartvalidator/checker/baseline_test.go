@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesBaseline(t *testing.T) {
+	baselineDir := t.TempDir()
+	renderDir := t.TempDir()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(baselineDir, "development_test-chart_abc123.yaml"), []byte("kind: ConfigMap\n"), 0644))
+
+	tests := []struct {
+		name     string
+		manifest string
+		rendered string
+		expected bool
+	}{
+		{
+			name:     "identical render",
+			manifest: "development_test-chart_abc123.yaml",
+			rendered: "kind: ConfigMap\n",
+			expected: true,
+		},
+		{
+			name:     "changed render",
+			manifest: "development_test-chart_abc123.yaml",
+			rendered: "kind: Secret\n",
+			expected: false,
+		},
+		{
+			name:     "no baseline for chart",
+			manifest: "production_other-chart_def456.yaml",
+			rendered: "kind: ConfigMap\n",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := createTempManifestFile(t, renderDir, tt.manifest, tt.rendered)
+
+			unchanged, err := matchesBaseline(baselineDir, manifestPath)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, unchanged)
+		})
+	}
+}
+
+func TestCheckBaselineDir(t *testing.T) {
+	workDir := t.TempDir()
+	outputDir := filepath.Join(workDir, "manifests")
+
+	assert.NoError(t, checkBaselineDir("", outputDir))
+	assert.NoError(t, checkBaselineDir(filepath.Join(workDir, "previous"), outputDir))
+	assert.ErrorContains(t, checkBaselineDir(outputDir, outputDir), "is inside the output directory")
+	assert.ErrorContains(t, checkBaselineDir(filepath.Join(outputDir, "previous"), outputDir), "is inside the output directory")
+}
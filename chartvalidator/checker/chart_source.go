@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChartSource discovers the charts to render and check from one kind of input
+type ChartSource interface {
+	Charts(ctx context.Context) ([]ChartRenderParams, error)
+}
+
+// appsetSource reads charts from the list generators of Argo CD ApplicationSets
+type appsetSource struct {
+	envDir    string
+	srcPrefix string
+	envs      []string
+	// strict reports unknown element keys as errors
+	strict bool
+	// includeApplications also reads standalone Argo CD Applications
+	includeApplications bool
+}
+
+func (source appsetSource) Charts(ctx context.Context) ([]ChartRenderParams, error) {
+	return findChartsInAppsets(source.envDir, source.srcPrefix, source.envs, source.strict, source.includeApplications)
+}
+
+// helmReleaseSource reads charts from Flux HelmReleases
+type helmReleaseSource struct {
+	envDir string
+	envs   []string
+}
+
+func (source helmReleaseSource) Charts(ctx context.Context) ([]ChartRenderParams, error) {
+	return findChartsInHelmReleases(source.envDir, source.envs)
+}
+
+// chartSources are the values of -source, each creating its source for the
+// selected environments, empty meaning all
+var chartSources = map[string]func(options DiscoveryOptions, envs []string) ChartSource{
+	sourceAppsets: func(options DiscoveryOptions, envs []string) ChartSource {
+		return appsetSource{
+			envDir:              options.EnvDir,
+			srcPrefix:           options.SrcPrefix,
+			envs:                envs,
+			strict:              options.StrictAppsets,
+			includeApplications: options.IncludeApplications,
+		}
+	},
+	sourceFlux: func(options DiscoveryOptions, envs []string) ChartSource {
+		return helmReleaseSource{envDir: options.EnvDir, envs: envs}
+	},
+}
+
+// newChartSource returns the source selected with -source, ApplicationSets when not set
+func newChartSource(options DiscoveryOptions, envs []string) (ChartSource, error) {
+	name := options.Source
+	if name == "" {
+		name = sourceAppsets
+	}
+	create, ok := chartSources[name]
+	if !ok {
+		names := make([]string, 0, len(chartSources))
+		for known := range chartSources {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown chart source %q, use one of %s", options.Source, strings.Join(names, ", "))
+	}
+	return create(options, envs), nil
+}
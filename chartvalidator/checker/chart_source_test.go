@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChartSourcesFindEquivalentCharts(t *testing.T) {
+	appsetDir := t.TempDir()
+	createTestAppset(t, appsetDir, "staging", "podinfo", `      - chartName: podinfo
+        repoURL: https://stefanprodan.github.io/podinfo
+        chartVersion: 6.5.4
+`)
+	fluxDir := t.TempDir()
+	createTempManifestFile(t, fluxDir, "staging/podinfo.yaml", sampleHelmRelease)
+
+	appsets, err := newChartSource(DiscoveryOptions{Source: sourceAppsets, EnvDir: appsetDir}, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, appsetSource{}, appsets)
+	flux, err := newChartSource(DiscoveryOptions{Source: sourceFlux, EnvDir: fluxDir}, nil)
+	assert.NoError(t, err)
+	assert.IsType(t, helmReleaseSource{}, flux)
+
+	fromAppsets, err := appsets.Charts(createTestContext())
+	assert.NoError(t, err)
+	fromFlux, err := flux.Charts(createTestContext())
+	assert.NoError(t, err)
+
+	assert.Len(t, fromAppsets, 1)
+	assert.Len(t, fromFlux, 1)
+	for _, charts := range [][]ChartRenderParams{fromAppsets, fromFlux} {
+		assert.Equal(t, "staging", charts[0].Env)
+		assert.Equal(t, "podinfo", charts[0].ChartName)
+		assert.Equal(t, "https://stefanprodan.github.io/podinfo", charts[0].RepoURL)
+		assert.Equal(t, "6.5.4", charts[0].ChartVersion)
+	}
+}
+
+func TestNewChartSource(t *testing.T) {
+	source, err := newChartSource(DiscoveryOptions{EnvDir: "env"}, []string{"dev"})
+	assert.NoError(t, err)
+	assert.Equal(t, appsetSource{envDir: "env", envs: []string{"dev"}}, source, "Expected ApplicationSets when no source is set")
+
+	_, err = newChartSource(DiscoveryOptions{Source: "helmfile"}, nil)
+	assert.EqualError(t, err, `unknown chart source "helmfile", use one of appsets, flux`)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvPrefix prefixes environment variables that supply flag values,
+// e.g. CHART_CHECKER_ENVDIR for -envdir
+const configEnvPrefix = "CHART_CHECKER_"
+
+// flagEnvName returns the environment variable consulted for a flag
+func flagEnvName(name string) string {
+	return configEnvPrefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// applyConfigSources fills in every flag that was not given on the command line,
+// first from its environment variable and then from the YAML config file, which
+// maps flag names to values. Precedence is command line > environment > file > default.
+func applyConfigSources(fs *flag.FlagSet, configFile string) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	fileValues := map[string]any{}
+	if configFile != "" {
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", configFile, err)
+		}
+		for name := range fileValues {
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("config file %s sets unknown flag %q", configFile, name)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] {
+			return
+		}
+		if value, found := os.LookupEnv(flagEnvName(f.Name)); found {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %w", value, flagEnvName(f.Name), setErr)
+			}
+			return
+		}
+		if value, found := fileValues[f.Name]; found {
+			if setErr := setFlagFromConfig(fs, f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %q in config file: %w", f.Name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// setFlagFromConfig sets a flag from a decoded YAML value, calling Set once per item for lists
+func setFlagFromConfig(fs *flag.FlagSet, name string, value any) error {
+	if items, ok := value.([]any); ok {
+		for _, item := range items {
+			if err := fs.Set(name, fmt.Sprint(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fs.Set(name, fmt.Sprint(value))
+}
+
+// printEffectiveConfig writes the resolved value of every flag as YAML
+func printEffectiveConfig(w io.Writer, fs *flag.FlagSet, skip ...string) error {
+	skipped := map[string]bool{}
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	effective := map[string]any{}
+	fs.VisitAll(func(f *flag.Flag) {
+		if skipped[f.Name] {
+			return
+		}
+		effective[f.Name] = effectiveFlagValue(f)
+	})
+
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+	// Map keys are sorted by the encoder, so the output is stable
+	return encoder.Encode(effective)
+}
+
+// effectiveFlagValue returns the typed value of a flag for printing
+func effectiveFlagValue(f *flag.Flag) any {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return f.Value.String()
+	}
+	if d, ok := getter.Get().(time.Duration); ok {
+		return d.String()
+	}
+	return getter.Get()
+}
+
+// stringListFlag is a flag that can be repeated, each use adding one value
+type stringListFlag []string
+
+func (list *stringListFlag) String() string {
+	return strings.Join(*list, " ")
+}
+
+func (list *stringListFlag) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// Get returns the values for printEffectiveConfig
+func (list *stringListFlag) Get() any {
+	return []string(*list)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+// Helper function to build a flag set resembling the run-checks flags
+func createConfigTestFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("run-checks", flag.ContinueOnError)
+	fs.String("envdir", "../env", "")
+	fs.String("output", "manifests", "")
+	fs.Bool("detect-secrets", false, "")
+	fs.Int("suffix-length", defaultSuffixLength, "")
+	fs.Duration("max-render-duration", 0, "")
+	fs.String("config", "", "")
+	return fs
+}
+
+func TestPrintEffectiveConfigMergesSources(t *testing.T) {
+	configFile := createTempManifestFile(t, t.TempDir(), "checker.yaml", `envdir: ../../env
+output: from-file
+detect-secrets: true
+max-render-duration: 30s
+`)
+	t.Setenv("CHART_CHECKER_SUFFIX_LENGTH", "12")
+	t.Setenv("CHART_CHECKER_ENVDIR", "from-env")
+
+	fs := createConfigTestFlagSet()
+	assert.NoError(t, fs.Parse([]string{"-config", configFile, "-output", "from-cli"}))
+	assert.NoError(t, applyConfigSources(fs, configFile))
+
+	var out bytes.Buffer
+	assert.NoError(t, printEffectiveConfig(&out, fs, "config"))
+
+	var printed map[string]any
+	assert.NoError(t, yaml.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, map[string]any{
+		"envdir":              "from-env", // environment beats the file
+		"output":              "from-cli", // command line beats the file
+		"detect-secrets":      true,       // file beats the default
+		"suffix-length":       12,         // environment beats the default
+		"max-render-duration": "30s",      // file beats the default
+	}, printed)
+}
+
+func TestApplyConfigSourcesRejectsUnknownFlags(t *testing.T) {
+	configFile := createTempManifestFile(t, t.TempDir(), "checker.yaml", "outputdir: typo\n")
+
+	fs := createConfigTestFlagSet()
+	assert.NoError(t, fs.Parse(nil))
+	err := applyConfigSources(fs, configFile)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown flag "outputdir"`)
+}
+
+func TestApplyConfigSourcesWithoutFile(t *testing.T) {
+	fs := createConfigTestFlagSet()
+	assert.NoError(t, fs.Parse([]string{"-max-render-duration", "1m"}))
+	assert.NoError(t, applyConfigSources(fs, ""))
+
+	assert.Equal(t, time.Minute, fs.Lookup("max-render-duration").Value.(flag.Getter).Get())
+	assert.Equal(t, "manifests", fs.Lookup("output").Value.String())
+}
+
+func TestStringListFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var helmArgs stringListFlag
+	fs.Var(&helmArgs, "helm-arg", "")
+
+	assert.NoError(t, fs.Parse([]string{"-helm-arg", "--kube-version=1.29", "-helm-arg", "--set", "-helm-arg", "motd=hello world"}))
+
+	assert.Equal(t, stringListFlag{"--kube-version=1.29", "--set", "motd=hello world"}, helmArgs, "Expected every use to be one argument, spaces included")
+}
+
+func TestApplyConfigSourcesRepeatableFlags(t *testing.T) {
+	configFile := createTempManifestFile(t, t.TempDir(), "checker.yaml", `schema-location:
+  - default
+  - ci/schemas/{{ .ResourceKind }}.json
+helm-arg:
+  - --kube-version=1.29
+`)
+
+	fs := createConfigTestFlagSet()
+	var schemaLocations, helmArgs stringListFlag
+	fs.Var(&schemaLocations, "schema-location", "")
+	fs.Var(&helmArgs, "helm-arg", "")
+	assert.NoError(t, fs.Parse([]string{"-config", configFile, "-helm-arg", "--set=ingress.enabled=true"}))
+	assert.NoError(t, applyConfigSources(fs, configFile))
+
+	assert.Equal(t, stringListFlag{"default", "ci/schemas/{{ .ResourceKind }}.json"}, schemaLocations, "Expected every list item of the file to be used")
+	assert.Equal(t, stringListFlag{"--set=ingress.enabled=true"}, helmArgs, "Expected the command line to replace the file's list")
+	assert.Equal(t, "manifests", fs.Lookup("output").Value.String())
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// conftestResult is one file of conftest's --output json report
+type conftestResult struct {
+	Filename  string           `json:"filename"`
+	Namespace string           `json:"namespace"`
+	Failures  []conftestRecord `json:"failures"`
+	Warnings  []conftestRecord `json:"warnings"`
+}
+
+type conftestRecord struct {
+	Msg string `json:"msg"`
+}
+
+// policyFinding is a deny or, when Warning is set, a warn rule that matched a manifest
+type policyFinding struct {
+	Namespace string
+	Message   string
+	Warning   bool
+}
+
+func (finding policyFinding) String() string {
+	return fmt.Sprintf("%s: %s", finding.Namespace, finding.Message)
+}
+
+// conftestArgs tests a manifest against every policy namespace in policyDir
+func conftestArgs(policyDir, manifestFile string) []string {
+	return []string{"test", "--policy", policyDir, "--all-namespaces", "--output", "json", "--no-color", manifestFile}
+}
+
+// runConftest tests a rendered manifest against the Rego policies in policyDir.
+// conftest exits non-zero when a deny rule matches, so the JSON report rather
+// than the exit code tells violations apart from conftest itself failing.
+func runConftest(ctx context.Context, executor CommandExecutor, policyDir, manifestFile string) ([]policyFinding, error) {
+	cmd := executor.CommandContext(ctx, "conftest", conftestArgs(policyDir, manifestFile)...)
+	stdout, stderr, runErr := cmd.SplitOutput()
+
+	var results []conftestResult
+	if err := json.Unmarshal(stdout, &results); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("conftest failed: %w: %s", runErr, strings.TrimSpace(string(stderr)))
+		}
+		return nil, fmt.Errorf("failed to parse conftest output: %w", err)
+	}
+
+	var findings []policyFinding
+	for _, result := range results {
+		for _, failure := range result.Failures {
+			findings = append(findings, policyFinding{Namespace: result.Namespace, Message: failure.Msg})
+		}
+		for _, warning := range result.Warnings {
+			findings = append(findings, policyFinding{Namespace: result.Namespace, Message: warning.Msg, Warning: true})
+		}
+	}
+	if runErr != nil && len(findings) == 0 {
+		return nil, fmt.Errorf("conftest failed: %w: %s", runErr, strings.TrimSpace(string(stderr)))
+	}
+	return findings, nil
+}
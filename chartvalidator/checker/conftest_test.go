@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// conftestExecutor answers conftest with output chosen by the manifest under
+// test and leaves every other command to the mock executor
+type conftestExecutor struct {
+	*MockCommandExecutor
+	report func(manifestFile string) (string, error)
+}
+
+func (e *conftestExecutor) CommandContext(ctx context.Context, name string, args ...string) Command {
+	command := e.MockCommandExecutor.CommandContext(ctx, name, args...)
+	if name != "conftest" {
+		return command
+	}
+	output, err := e.report(args[len(args)-1])
+	return &MockCommand{executor: e.MockCommandExecutor, output: []byte(output), err: err}
+}
+
+func TestRunConftest(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`[
+  {
+    "filename": "manifests/development_web.yaml",
+    "namespace": "main",
+    "successes": 3,
+    "failures": [{"msg": "Pod web must not use hostNetwork"}],
+    "warnings": [{"msg": "Deployment web has no PodDisruptionBudget"}]
+  }
+]`)
+	mockExecutor.Error = fmt.Errorf("exit status 1")
+
+	findings, err := runConftest(createTestContext(), mockExecutor, "policy", "manifests/development_web.yaml")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []policyFinding{
+		{Namespace: "main", Message: "Pod web must not use hostNetwork"},
+		{Namespace: "main", Message: "Deployment web has no PodDisruptionBudget", Warning: true},
+	}, findings)
+	assertCommandExecution(t, mockExecutor, "conftest test --policy policy --all-namespaces --output json --no-color manifests/development_web.yaml")
+}
+
+func TestRunConftestPasses(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`[{"filename": "web.yaml", "namespace": "main", "successes": 4}]`)
+
+	findings, err := runConftest(createTestContext(), mockExecutor, "policy", "web.yaml")
+
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestRunConftestFailure(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("")
+	mockExecutor.Stderr = []byte("Error: running test: load: loading policies: no policies found in [policy]")
+	mockExecutor.Error = fmt.Errorf("exit status 1")
+
+	_, err := runConftest(createTestContext(), mockExecutor, "policy", "web.yaml")
+
+	assert.EqualError(t, err, "conftest failed: exit status 1: Error: running test: load: loading policies: no policies found in [policy]")
+}
+
+func TestAppCheckerReportsPolicyViolationsPerChart(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+	executor := &conftestExecutor{
+		MockCommandExecutor: mockExecutor,
+		report: func(manifestFile string) (string, error) {
+			if strings.Contains(manifestFile, "_web_") {
+				return `[{"filename": "` + manifestFile + `", "namespace": "main", "failures": [{"msg": "ConfigMap config must set an owner label"}]}]`, fmt.Errorf("exit status 1")
+			}
+			return `[{"filename": "` + manifestFile + `", "namespace": "main", "successes": 1}]`, nil
+		},
+	}
+
+	engine := NewAppCheckerEngine(createTestContext(), executor, AppCheckerOptions{
+		OutputDir:      t.TempDir(),
+		ConftestPolicy: "policy",
+	})
+	engine.Start(1)
+
+	web := createTestChart()
+	web.ChartName = "web"
+	api := createTestChart()
+	api.ChartName = "api"
+	sendChartsToAppChecker(engine, []ChartRenderParams{web, api})
+	results := collectAppCheckResults(engine)
+
+	byChart := map[string]AppCheckResult{}
+	for _, result := range results {
+		byChart[result.Chart.ChartName] = result
+	}
+	assert.Len(t, results, 2)
+	assert.EqualError(t, byChart["web"].Error, "policy violation in main: ConfigMap config must set an owner label")
+	assert.False(t, byChart["web"].Warning)
+	assert.NoError(t, byChart["api"].Error, "Expected the chart without violations to pass")
+}
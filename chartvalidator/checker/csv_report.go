@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// csvHeader are the columns of the -csv-out report
+var csvHeader = []string{"env", "chart", "version", "image", "status", "error"}
+
+// writeCSVReport writes one row per result, sorted by env, chart and image
+func writeCSVReport(path string, results []AppCheckResult) error {
+	records := make([]resultRecord, 0, len(results))
+	for _, result := range results {
+		records = append(records, newResultRecord(result))
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].Env != records[j].Env {
+			return records[i].Env < records[j].Env
+		}
+		if records[i].Chart != records[j].Chart {
+			return records[i].Chart < records[j].Chart
+		}
+		return records[i].Image < records[j].Image
+	})
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV report %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(csvHeader)
+	for _, record := range records {
+		w.Write([]string{record.Env, record.Chart, record.ChartVersion, record.Image, record.Status, record.Error})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV report %s: %w", path, err)
+	}
+	return f.Close()
+}
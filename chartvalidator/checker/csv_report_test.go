@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCSVReport(t *testing.T) {
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+
+	results := []AppCheckResult{
+		{Chart: production, Skipped: true},
+		{Chart: development, Image: "redis:6.2", Error: fmt.Errorf("registry error for redis:6.2: exit status 1: \"quoted\", with commas\nand a newline"), Unverifiable: true},
+		{Chart: development, Image: "nginx:1.25"},
+	}
+
+	path := filepath.Join(t.TempDir(), "results.csv")
+	assert.NoError(t, writeCSVReport(path, results))
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	assert.NoError(t, err)
+
+	assert.Equal(t, [][]string{
+		{"env", "chart", "version", "image", "status", "error"},
+		{"development", "test-chart", "1.0.0", "nginx:1.25", "passed", ""},
+		{"development", "test-chart", "1.0.0", "redis:6.2", "failed", "registry error for redis:6.2: exit status 1: \"quoted\", with commas\nand a newline"},
+		{"production", "test-chart", "1.0.0", "", "skipped", ""},
+	}, rows)
+}
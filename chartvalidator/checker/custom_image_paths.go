@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// customKindImages tells the image extractor where a custom resource keeps its
+// images: direct image fields, pod templates (objects with a spec holding
+// containers), or both. Paths are dot separated and "[]" descends into every
+// element of a list, e.g. spec.components[].image.
+type customKindImages struct {
+	Images       []string `yaml:"images"`
+	PodTemplates []string `yaml:"podTemplates"`
+}
+
+// customImagePathsFile is the layout of the -image-paths file:
+//
+//	kinds:
+//	  MyApp:
+//	    images: [spec.image]
+//	  Rollout:
+//	    podTemplates: [spec.template]
+type customImagePathsFile struct {
+	Kinds map[string]customKindImages `yaml:"kinds"`
+}
+
+// customImagePaths maps kinds to the paths their images are read from. A kind
+// listed here is extracted with its paths instead of any built-in handling.
+var customImagePaths map[string]customKindImages
+
+// loadCustomImagePaths reads the kind to image path configuration
+func loadCustomImagePaths(path string) (map[string]customKindImages, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image paths file: %w", err)
+	}
+
+	var file customImagePathsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse image paths file %s: %w", path, err)
+	}
+	for kind, paths := range file.Kinds {
+		if len(paths.Images) == 0 && len(paths.PodTemplates) == 0 {
+			return nil, fmt.Errorf("kind %s in %s has no images or podTemplates paths", kind, path)
+		}
+	}
+	return file.Kinds, nil
+}
+
+// valuesAtPath returns every value found at a dotted path, "[]" segments fanning out over lists
+func valuesAtPath(value interface{}, path string) []interface{} {
+	if path == "" {
+		return []interface{}{value}
+	}
+	segment, rest, _ := strings.Cut(path, ".")
+
+	key, isList := strings.CutSuffix(segment, "[]")
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	next, ok := m[key]
+	if !ok {
+		return nil
+	}
+	if !isList {
+		return valuesAtPath(next, rest)
+	}
+
+	items, _ := next.([]interface{})
+	var out []interface{}
+	for _, item := range items {
+		out = append(out, valuesAtPath(item, rest)...)
+	}
+	return out
+}
+
+// extractImagesFromCustomKind reads the images of a custom resource from its configured paths
+func extractImagesFromCustomKind(manifest map[string]interface{}, paths customKindImages) ([]ImageSource, error) {
+	images := []ImageSource{}
+	for _, path := range paths.Images {
+		for _, value := range valuesAtPath(manifest, path) {
+			if image, ok := value.(string); ok {
+				images = append(images, ImageSource{Image: image, Field: path})
+			}
+		}
+	}
+	for _, path := range paths.PodTemplates {
+		for _, value := range valuesAtPath(manifest, path) {
+			template, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			podImages, err := extractImagesFromPod(template)
+			if err != nil {
+				return images, err
+			}
+			images = append(images, podImages...)
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const customImagePathsConfig = `kinds:
+  MyApp:
+    images:
+      - spec.image
+      - spec.components[].image
+  Rollout:
+    podTemplates:
+      - spec.template
+`
+
+func useCustomImagePaths(t *testing.T, config string) {
+	paths, err := loadCustomImagePaths(createTempManifestFile(t, t.TempDir(), "image-paths.yaml", config))
+	assert.NoError(t, err)
+	customImagePaths = paths
+	t.Cleanup(func() { customImagePaths = nil })
+}
+
+func TestExtractImagesFromCustomKindDirectField(t *testing.T) {
+	useCustomImagePaths(t, customImagePathsConfig)
+
+	images, err := extractImageFromManifest(`apiVersion: example.com/v1
+kind: MyApp
+metadata:
+  name: shop
+spec:
+  image: registry.example.com/shop:1.4
+  components:
+    - name: worker
+      image: registry.example.com/shop-worker:1.4
+    - name: cache
+      image: redis:7.2
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"registry.example.com/shop:1.4", "registry.example.com/shop-worker:1.4", "redis:7.2"}, images)
+}
+
+func TestCustomImagePathSourceNamesThePath(t *testing.T) {
+	useCustomImagePaths(t, customImagePathsConfig)
+
+	sources, err := extractImageSourcesFromManifest(`apiVersion: example.com/v1
+kind: MyApp
+metadata:
+  name: shop
+spec:
+  image: registry.example.com/shop:1.4
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, sources, 1)
+	assert.Empty(t, sources[0].Container)
+	assert.Equal(t, "spec.image in MyApp shop", sources[0].String())
+}
+
+func TestExtractImagesFromCustomKindPodTemplate(t *testing.T) {
+	useCustomImagePaths(t, customImagePathsConfig)
+
+	images, err := extractImageFromManifest(`apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: registry.example.com/migrate:2.0
+      containers:
+        - name: web
+          image: nginx:1.25
+`, 0)
+
+	assert.NoError(t, err)
+	assertImageSetMatches(t, map[string]bool{"registry.example.com/migrate:2.0": true, "nginx:1.25": true}, images, "Rollout")
+}
+
+func TestExtractImagesFromUnconfiguredCustomKind(t *testing.T) {
+	images, err := extractImageFromManifest(`apiVersion: example.com/v1
+kind: MyApp
+metadata:
+  name: shop
+spec:
+  image: registry.example.com/shop:1.4
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+}
+
+func TestLoadCustomImagePathsRequiresPaths(t *testing.T) {
+	_, err := loadCustomImagePaths(createTempManifestFile(t, t.TempDir(), "image-paths.yaml", "kinds:\n  MyApp: {}\n"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Supported values for the -source flag
+const (
+	sourceAppsets = "appsets"
+	sourceFlux    = "flux"
+)
+
+// DiscoveryOptions selects where and how charts are discovered
+type DiscoveryOptions struct {
+	Source    string
+	EnvDir    string
+	SingleEnv string
+	// SrcPrefix is prepended to the values file paths read from the environments
+	SrcPrefix string
+
+	// Envs restricts discovery to these environments, in addition to SingleEnv
+	Envs []string
+	// SkipBadEnvs warns about selected environments that don't exist instead of failing
+	SkipBadEnvs bool
+
+	// StrictAppsets reports unknown ApplicationSet element keys as errors
+	StrictAppsets bool
+	// MinCharts fails discovery that finds fewer charts, guarding against a
+	// misconfiguration that finds nothing and so passes
+	MinCharts int
+	// IncludeApplications also reads standalone Argo CD Applications next to the ApplicationSets
+	IncludeApplications bool
+}
+
+// ErrEnvDirNotFound is returned when the -envdir directory does not exist
+var ErrEnvDirNotFound = errors.New("environment directory not found")
+
+// findCharts discovers charts using the source selected in the options
+func findCharts(ctx context.Context, options DiscoveryOptions) ([]ChartRenderParams, error) {
+	warnings, err := preflightEnvDir(options)
+	if err != nil {
+		return nil, err
+	}
+	envs, skipped, err := selectEnvironments(options)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range append(warnings, skipped...) {
+		logEngineWarning("Discovery", -1, warning)
+	}
+
+	source, err := newChartSource(options, envs)
+	if err != nil {
+		return nil, err
+	}
+	charts, err := source.Charts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(charts) < options.MinCharts {
+		return nil, fmt.Errorf("found %d charts in %s, fewer than the minimum of %d (check -envdir, -env/-envs and -source)", len(charts), options.EnvDir, options.MinCharts)
+	}
+	return charts, nil
+}
+
+// checkValuesFilesExist reports every values file referenced by the charts
+// that doesn't exist in one error, so typos in the appsets show up before any
+// chart is rendered rather than one by one as the charts fail
+func checkValuesFilesExist(executor CommandExecutor, charts []ChartRenderParams) error {
+	var missing []string
+	for _, chart := range charts {
+		files := []struct{ kind, path string }{
+			{"base values file", chart.BaseValuesFile},
+			{"values override file", chart.ValuesOverride},
+		}
+		for _, file := range files {
+			if file.path != "" && !executor.FileExists(file.path) {
+				missing = append(missing, fmt.Sprintf("%s %s of chart %s from env %s", file.kind, file.path, chart.ChartName, chart.Env))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d values file(s) referenced by the charts do not exist:\n  %s", len(missing), strings.Join(missing, "\n  "))
+}
+
+// preflightEnvDir checks that the environment directory has the expected layout
+// before discovery, so a wrong -envdir fails with an explanation rather than
+// finding zero charts. Problems that don't prevent discovery are returned as warnings.
+func preflightEnvDir(options DiscoveryOptions) ([]string, error) {
+	ok, err := existsDir(options.EnvDir)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s (point -envdir at the folder containing one directory per environment)", ErrEnvDirNotFound, options.EnvDir)
+	}
+
+	envNames := options.requestedEnvs()
+	if len(envNames) == 0 {
+		entries, err := os.ReadDir(options.EnvDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				envNames = append(envNames, e.Name())
+			}
+		}
+		if len(envNames) == 0 {
+			return nil, fmt.Errorf("no environment directories found in %s (expected e.g. %s)", options.EnvDir, filepath.Join(options.EnvDir, "<env>", "appsets"))
+		}
+	}
+
+	// Only ApplicationSets are expected in a fixed appsets folder
+	if options.Source != "" && options.Source != sourceAppsets {
+		return nil, nil
+	}
+
+	var warnings []string
+	var withAppsets int
+	for _, envName := range envNames {
+		ok, err := existsDir(filepath.Join(options.EnvDir, envName, "appsets"))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			withAppsets++
+		}
+	}
+	if withAppsets == 0 {
+		warnings = append(warnings, fmt.Sprintf("none of the %d environment(s) in %s contain an appsets directory, no charts will be found (expected %s)", len(envNames), options.EnvDir, filepath.Join(options.EnvDir, envNames[0], "appsets")))
+	}
+	return warnings, nil
+}
+
+// requestedEnvs returns the environments selected with -env and -envs, empty meaning all
+func (options DiscoveryOptions) requestedEnvs() []string {
+	var envs []string
+	for _, env := range append([]string{options.SingleEnv}, options.Envs...) {
+		if env != "" && !slices.Contains(envs, env) {
+			envs = append(envs, env)
+		}
+	}
+	return envs
+}
+
+// parseCommaList splits a comma separated flag value such as a list of
+// environment names, dropping blank entries
+func parseCommaList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// selectEnvironments checks that every requested environment exists. Missing
+// environments are an error unless SkipBadEnvs is set, in which case they are
+// dropped and returned as warnings. An empty selection means all environments.
+func selectEnvironments(options DiscoveryOptions) ([]string, []string, error) {
+	requested := options.requestedEnvs()
+	if len(requested) == 0 {
+		return nil, nil, nil
+	}
+
+	var envs, warnings []string
+	for _, env := range requested {
+		ok, err := existsDir(filepath.Join(options.EnvDir, env))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			envs = append(envs, env)
+			continue
+		}
+		if !options.SkipBadEnvs {
+			return nil, nil, fmt.Errorf("environment %q not found in %s", env, options.EnvDir)
+		}
+		warnings = append(warnings, fmt.Sprintf("skipping environment %q, not found in %s", env, options.EnvDir))
+	}
+	if len(envs) == 0 {
+		return nil, nil, fmt.Errorf("none of the selected environments %s exist in %s", strings.Join(requested, ", "), options.EnvDir)
+	}
+	return envs, warnings, nil
+}
+
+// forEachEnvironment runs process for each selected environment, or for every
+// environment directory under envDir when none are selected, and merges the charts
+func forEachEnvironment(envDir string, selectedEnvs []string, process func(envName, envPath string) ([]ChartRenderParams, error)) ([]ChartRenderParams, error) {
+	var out []ChartRenderParams
+
+	if len(selectedEnvs) > 0 {
+		for _, env := range selectedEnvs {
+			envPath := filepath.Join(envDir, env)
+			ok, err := existsDir(envPath)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("environment %q not found in %s", env, envDir)
+			}
+			ch, err := process(env, envPath)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, ch...)
+		}
+		return out, nil
+	}
+
+	entries, err := os.ReadDir(envDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		ch, err := process(e.Name(), filepath.Join(envDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ch...)
+	}
+	return out, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightEnvDir(t *testing.T) {
+	t.Run("missing envdir", func(t *testing.T) {
+		_, err := preflightEnvDir(DiscoveryOptions{EnvDir: filepath.Join(t.TempDir(), "missing")})
+		assert.ErrorIs(t, err, ErrEnvDirNotFound)
+		assert.Contains(t, err.Error(), "-envdir")
+	})
+
+	t.Run("empty envdir", func(t *testing.T) {
+		_, err := preflightEnvDir(DiscoveryOptions{EnvDir: t.TempDir()})
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrEnvDirNotFound)
+		assert.Contains(t, err.Error(), "no environment directories found")
+	})
+
+	t.Run("envs lacking appsets", func(t *testing.T) {
+		envDir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(envDir, "dev", "charts"), 0755))
+		assert.NoError(t, os.MkdirAll(filepath.Join(envDir, "prod"), 0755))
+
+		warnings, err := preflightEnvDir(DiscoveryOptions{EnvDir: envDir})
+		assert.NoError(t, err)
+		assert.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "contain an appsets directory")
+	})
+
+	t.Run("valid layout", func(t *testing.T) {
+		envDir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(envDir, "dev", "appsets"), 0755))
+		assert.NoError(t, os.MkdirAll(filepath.Join(envDir, "prod"), 0755))
+
+		warnings, err := preflightEnvDir(DiscoveryOptions{EnvDir: envDir})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+
+	t.Run("flux source does not need appsets", func(t *testing.T) {
+		envDir := t.TempDir()
+		assert.NoError(t, os.MkdirAll(filepath.Join(envDir, "dev"), 0755))
+
+		warnings, err := preflightEnvDir(DiscoveryOptions{EnvDir: envDir, Source: sourceFlux})
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+}
+
+func TestFindChartsWithEnvList(t *testing.T) {
+	envDir := t.TempDir()
+	for _, env := range []string{"dev", "staging", "prod"} {
+		createTestAppset(t, envDir, env, "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/`+env+`/values.yaml
+        valuesOverride: env/`+env+`/override.yaml
+`)
+	}
+
+	t.Run("only listed envs are processed", func(t *testing.T) {
+		charts, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, Envs: parseCommaList("dev, prod")})
+		assert.NoError(t, err)
+
+		var envs []string
+		for _, chart := range charts {
+			envs = append(envs, chart.Env)
+		}
+		assert.Equal(t, []string{"dev", "prod"}, envs)
+	})
+
+	t.Run("missing env fails", func(t *testing.T) {
+		_, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, Envs: []string{"dev", "qa"}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `environment "qa" not found`)
+	})
+
+	t.Run("missing env skipped", func(t *testing.T) {
+		charts, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, Envs: []string{"dev", "qa"}, SkipBadEnvs: true})
+		assert.NoError(t, err)
+		assert.Len(t, charts, 1)
+		assert.Equal(t, "dev", charts[0].Env)
+	})
+
+	t.Run("all envs missing fails even when skipping", func(t *testing.T) {
+		_, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, Envs: []string{"qa"}, SkipBadEnvs: true})
+		assert.Error(t, err)
+	})
+}
+
+func TestMinChartsFailsTheRun(t *testing.T) {
+	envDir := t.TempDir()
+	createTestAppset(t, envDir, "dev", "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/dev/values.yaml
+        valuesOverride: env/dev/override.yaml
+`)
+
+	charts, err := findCharts(createTestContext(), DiscoveryOptions{EnvDir: envDir, MinCharts: 1})
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+
+	discovery := DiscoveryOptions{EnvDir: envDir, MinCharts: 2}
+	err = runAllChartChecks(discovery, AppCheckerOptions{OutputDir: t.TempDir()}, ReportOptions{})
+	assert.ErrorContains(t, err, "found 1 charts in "+envDir+", fewer than the minimum of 2")
+}
+
+func TestParseEnvList(t *testing.T) {
+	assert.Equal(t, []string{"dev", "staging"}, parseCommaList("dev, staging,"))
+	assert.Empty(t, parseCommaList(""))
+}
+
+func TestCheckValuesFilesExist(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.FileExistsMap = map[string]bool{"typo/values.yaml": false, "api/override.yaml": false}
+
+	web := createTestChart()
+	web.ChartName = "web"
+	web.BaseValuesFile = "typo/values.yaml"
+	api := createTestChart()
+	api.ChartName = "api"
+	api.ValuesOverride = "api/override.yaml"
+	inline := createTestChart()
+	inline.BaseValuesFile = ""
+	inline.ValuesOverride = ""
+
+	assert.NoError(t, checkValuesFilesExist(mockExecutor, []ChartRenderParams{createTestChart(), inline}))
+	assert.EqualError(t, checkValuesFilesExist(mockExecutor, []ChartRenderParams{web, createTestChart(), api}), `2 values file(s) referenced by the charts do not exist:
+  base values file typo/values.yaml of chart web from env development
+  values override file api/override.yaml of chart api from env development`)
+}
+
+func TestMissingValuesFileFailsBeforeRendering(t *testing.T) {
+	envDir := t.TempDir()
+	createTestAppset(t, envDir, "dev", "web", `      - chartName: web
+        repoURL: https://example.com/charts
+        chartVersion: 1.0.0
+        baseValuesFile: env/dev/does-not-exist.yaml
+`)
+
+	outputDir := filepath.Join(t.TempDir(), "manifests")
+	err := runAllChartChecks(DiscoveryOptions{EnvDir: envDir}, AppCheckerOptions{OutputDir: outputDir}, ReportOptions{})
+
+	assert.EqualError(t, err, "1 values file(s) referenced by the charts do not exist:\n  base values file env/dev/does-not-exist.yaml of chart web from env dev")
+	assert.NoDirExists(t, outputDir, "Expected the run to stop before rendering")
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
+	"text/template"
+	"time"
 )
 
 type AppCheckInstruction struct {
@@ -13,7 +16,168 @@ type AppCheckInstruction struct {
 type AppCheckResult struct {
 	Chart ChartRenderParams
 	Image string
+	// OriginalImage is the extracted reference when Image was rewritten before validation
+	OriginalImage string
+	// Sources are where the chart's manifest references the image
+	Sources []ImageSource
 	Error error
+
+	// Skipped is set when the chart rendered identically to the baseline and
+	// the result was carried over from it rather than checked again
+	Skipped bool
+
+	// Unverifiable is set when the registry couldn't be asked whether the image exists
+	Unverifiable bool
+
+	// AccessDenied is set when the registry refused the checker's credentials
+	AccessDenied bool
+
+	// Missing is set when the registry reported that the image doesn't exist
+	Missing bool
+
+	// Stage is the pipeline stage a chart-level error came from, e.g. render
+	Stage string
+
+	// Warning is set when Error is advisory, e.g. a mutable tag, and doesn't fail the run
+	Warning bool
+
+	// Started is when rendering of the chart began and Duration how long the
+	// chart had been in the pipeline when this result was produced
+	Started  time.Time
+	Duration time.Duration
+}
+
+// AppCheckerOptions holds the settings that shape the run-checks pipeline
+type AppCheckerOptions struct {
+	OutputDir string
+
+	// BaselineDir, when set, points at the output directory of an earlier run.
+	// Charts rendering identically reuse its results instead of being checked again.
+	BaselineDir string
+
+	// DetectSecrets reports literal credentials found in rendered manifests
+	DetectSecrets bool
+
+	// RequireSecurityContext reports containers that are privileged, run as root
+	// or lack runAsNonRoot/allowPrivilegeEscalation settings
+	RequireSecurityContext bool
+
+	// RequiredValues are dotted value paths, e.g. image.tag, that must be set
+	// for every chart. Rendered placeholders such as REPLACE_ME are reported too.
+	RequiredValues []string
+
+	// SuffixLength is the length of the hash suffix on rendered filenames
+	SuffixLength int
+
+	// ManifestNameTemplate builds rendered filenames, nil keeps the default scheme
+	ManifestNameTemplate *template.Template
+
+	// SerialWrites writes rendered manifests from a single goroutine
+	SerialWrites bool
+
+	// ConftestPolicy, when set, is a directory of Rego policies every rendered manifest is tested against
+	ConftestPolicy string
+
+	// MaxRenderDuration fails charts whose helm render takes longer, zero disables the check
+	MaxRenderDuration time.Duration
+
+	// OCIAuth configures authentication for charts from oci:// registries, keyed by host
+	OCIAuth map[string]ociRegistryAuth
+
+	// KubeconformBatch validates all rendered manifests with a single kubeconform run
+	KubeconformBatch bool
+
+	// ImageRewrites are applied to extracted images before they are validated
+	ImageRewrites []imageRewriteRule
+
+	// RegistryMirrors map registry hosts to the mirror their images are validated against
+	RegistryMirrors map[string]string
+
+	// SignatureVerifier, when set, fails images whose cosign signature doesn't verify
+	SignatureVerifier *signatureVerifier
+
+	// BatchRegistryLookups looks images up over the registry HTTP API, collecting
+	// them first so that each registry host is asked for one token
+	BatchRegistryLookups bool
+
+	// DockerConfig, when set, is the DOCKER_CONFIG directory holding registry credentials for image validation
+	DockerConfig string
+
+	// RegistryTool looks images up in their registry, docker when not set
+	RegistryTool registryTool
+
+	// RequirePlatform, when set, fails images that aren't available for that platform
+	RequirePlatform *imagePlatform
+
+	// MaxRegistryConcurrency caps the images looked up in their registry at once, no cap when zero
+	MaxRegistryConcurrency int
+
+	// CacheFile, when set, persists image validation results across runs for CacheTTL
+	CacheFile string
+	CacheTTL  time.Duration
+	// SharedCache coordinates CacheFile with concurrent runs using the same file
+	SharedCache bool
+
+	// IgnoreAuthErrors reports images the registry denied access to as warnings instead of failures
+	IgnoreAuthErrors bool
+
+	// MutableTags configures which tags count as mutable and where they fail the check
+	MutableTags *mutableTagPolicy
+
+	// RequireDigest warns about images that aren't pinned to a digest
+	RequireDigest bool
+
+	// RegistryPolicy, when set, restricts the registries images may come from per env
+	RegistryPolicy *registryPolicy
+
+	// NoLock skips the output directory lockfile taken for the duration of a run
+	NoLock bool
+
+	// IndexOut, when set, is where the JSON index of manifests and their images is written
+	IndexOut string
+
+	// AppVersions reports each chart's appVersion, read with helm show chart
+	AppVersions bool
+
+	// MetricsAddr, when set, is where engine counters are served over HTTP during the run
+	MetricsAddr string
+
+	// SelfVerify cross-checks image extraction against a deep scan of each manifest
+	SelfVerify bool
+
+	// DryRun logs the helm, kubeconform and registry commands instead of running them
+	DryRun bool
+
+	// HelmArgs are appended to every helm template command
+	HelmArgs []string
+
+	// BuildDeps runs helm dependency build for vendored charts before rendering them
+	BuildDeps bool
+
+	// RenderTimeout bounds each helm template command, zero means no limit
+	RenderTimeout time.Duration
+
+	// SchemaLocations replace the built-in kubeconform schema locations when set
+	SchemaLocations []string
+
+	// KubeVersion is the Kubernetes version kubeconform validates against
+	KubeVersion string
+
+	// SkipKinds are resource kinds kubeconform doesn't validate
+	SkipKinds []string
+}
+
+// commandExecutor returns the executor the run's commands go through
+func (options AppCheckerOptions) commandExecutor() CommandExecutor {
+	if options.DryRun {
+		return &DryRunCommandExecutor{}
+	}
+	return &RealCommandExecutor{}
+}
+
+// hasRenderStage reports whether render results need inspecting before validation
+func (options AppCheckerOptions) hasRenderStage() bool {
+	return options.BaselineDir != "" || options.DetectSecrets || options.RequireSecurityContext || len(options.RequiredValues) > 0 || options.MaxRenderDuration > 0 || options.ConftestPolicy != ""
 }
 
 type AppCheckerEngine struct {
@@ -28,13 +192,40 @@ type AppCheckerEngine struct {
 
 	context    context.Context
 	executor   CommandExecutor
+	options    AppCheckerOptions
 
 	workerWaitGroup sync.WaitGroup
 
+	// started records when each chart was handed to the renderer, reported
+	// which charts have had a result
+	started     map[ChartRenderParams]time.Time
+	reported    map[ChartRenderParams]bool
+	startedLock sync.Mutex
+
+	// baselineResults are the results of the run that wrote the baseline
+	baselineResults map[baselineChart][]resultRecord
+
 	name string
 }
 
-func NewAppCheckerEngine(context context.Context, outputDir string) *AppCheckerEngine {
+// newDockerValidationCache returns the -cache-file cache, or an in-memory one
+func newDockerValidationCache(options AppCheckerOptions) DockerValidationCache {
+	if options.CacheFile == "" {
+		return newMemoryValidationCache()
+	}
+	load := loadFileValidationCache
+	if options.SharedCache {
+		load = loadSharedValidationCache
+	}
+	fileCache, err := load(options.CacheFile, options.CacheTTL)
+	if err != nil {
+		logEngineWarning("AppChecker", -1, fmt.Sprintf("starting with an empty validation cache: %v", err))
+	}
+	fileCache.checks = validationChecks(options.SignatureVerifier, options.RequirePlatform)
+	return fileCache
+}
+
+func NewAppCheckerEngine(context context.Context, executor CommandExecutor, options AppCheckerOptions) *AppCheckerEngine {
 
 	errorChan := make(chan ErrorResult)
 
@@ -42,19 +233,37 @@ func NewAppCheckerEngine(context context.Context, outputDir string) *AppCheckerE
 		inputChan: make(chan ChartRenderParams),
 		resultChan: make(chan RenderResult),
 		errorChan: errorChan,
-		outputDir: outputDir,
+		outputDir: options.OutputDir,
+		suffixLength: options.SuffixLength,
+		nameTemplate: options.ManifestNameTemplate,
+		serialWrites: options.SerialWrites,
+		ociAuth: options.OCIAuth,
+		appVersions: options.AppVersions,
+		helmArgs: options.HelmArgs,
+		buildDeps: options.BuildDeps,
+		renderTimeout: options.RenderTimeout,
 		context: context,
-		executor: &RealCommandExecutor{},
+		executor: executor,
 		name: "ChartRenderer",
 	}
 
+	// Render results may be inspected and filtered before validation
+	validationInput := cre.resultChan
+	if options.hasRenderStage() {
+		validationInput = make(chan RenderResult)
+	}
+
 	mve := ManifestValidationEngine{
-		inputChan: cre.resultChan,
+		inputChan: validationInput,
 		resultChan: make(chan ManifestValidationResult),
 		errorChan: errorChan,
 		context: context,
-		executor: &RealCommandExecutor{},
+		executor: executor,
 		name: "ManifestValidator",
+		batch: options.KubeconformBatch,
+		schemaLocations: options.SchemaLocations,
+		kubeVersion: options.KubeVersion,
+		skipKinds: options.SkipKinds,
 		workerWaitGroup: sync.WaitGroup{},
 	}
 
@@ -64,40 +273,75 @@ func NewAppCheckerEngine(context context.Context, outputDir string) *AppCheckerE
 		errorChan: errorChan,
 		context: context,
 		name: "ImageExtractor",
+		rewriteRules: options.ImageRewrites,
+		registryMirrors: options.RegistryMirrors,
+		registryPolicy: options.RegistryPolicy,
+		selfVerify: options.SelfVerify,
 		workerWaitGroup: sync.WaitGroup{},
 	}
+	if options.IndexOut != "" {
+		iee.index = newManifestIndex()
+	}
 
 	dve := DockerImageValidationEngine{
 		inputChan: iee.outputChan,
 		outputChan: make(chan DockerImageValidationResult),
 		context: context,
-		executor: &RealCommandExecutor{},
+		executor: executor,
 		name: "DockerValidator",
-		cache: map[string]DockerImageValidationResult{},
+		cache: newDockerValidationCache(options),
+		signatures: options.SignatureVerifier,
+		registryTool: options.RegistryTool,
+		platform: options.RequirePlatform,
+		registrySlots: newRegistrySlots(options.MaxRegistryConcurrency),
+		dockerConfig: options.DockerConfig,
 		pending: map[string]*sync.WaitGroup{},
 		cacheLock: sync.RWMutex{},
 		workerWaitGroup: sync.WaitGroup{},
 	}
+	if options.BatchRegistryLookups {
+		dve.registryClient = newRegistryLookup(executor, options.DockerConfig)
+	}
 	
 	return &AppCheckerEngine{
 		inputChan:  make(chan AppCheckInstruction),
 		resultChan: make(chan AppCheckResult),
-		errorChan:  make(chan ErrorResult),
+		errorChan:  errorChan,
 
 		context:    context,
-		executor:   &RealCommandExecutor{},
+		executor:   executor,
+		options:    options,
 
 		ChartRenderingEngine: &cre,
 		ManifestValidationEngine: &mve,
 		ImageExtractionEngine:   &iee,
 		DockerValidationEngine:   &dve,
 
+		started: map[ChartRenderParams]time.Time{},
+		reported: map[ChartRenderParams]bool{},
+
+		baselineResults: loadBaselineResultsOrWarn(options.BaselineDir),
+
 		name: "AppChecker",
 	}
 }
 
+// loadBaselineResultsOrWarn loads the results of the -baseline run. Without
+// them unchanged charts can't reuse their results and are checked again.
+func loadBaselineResultsOrWarn(baselineDir string) map[baselineChart][]resultRecord {
+	if baselineDir == "" {
+		return nil
+	}
+	results, err := loadBaselineResults(baselineDir)
+	if err != nil {
+		logEngineWarning("AppChecker", -1, fmt.Sprintf("checking every chart again, the baseline has no usable results: %v", err))
+	}
+	return results
+}
+
 func (engine *AppCheckerEngine) allDoneWorker() {
 	engine.workerWaitGroup.Wait()
+	engine.reportChartsWithoutResults()
 	logEngineDebug(engine.name,-1,"all workers done, closing output channel")	
 	close(engine.resultChan)
 }
@@ -115,6 +359,12 @@ func (engine *AppCheckerEngine) Start(workerCount int) {
 	go engine.pumpAppCheckInstructionsToChartRenderer()
 	engine.workerWaitGroup.Add(1)	
 	go engine.pumpOutputsToAppCheckResults()
+	engine.workerWaitGroup.Add(1)
+	go engine.pumpErrorsToAppCheckResults()
+	if engine.options.hasRenderStage() {
+		engine.workerWaitGroup.Add(1)
+		go engine.pumpRenderResultsToValidation()
+	}
 
 	go engine.allDoneWorker()
 }
@@ -123,38 +373,234 @@ func (engine *AppCheckerEngine) pumpOutputsToAppCheckResults() {
 	defer engine.workerWaitGroup.Done()
 	for dockerResult := range engine.DockerValidationEngine.outputChan {
 		if dockerResult.Error != nil {
-			engine.resultChan <- AppCheckResult{
+			engine.emit(AppCheckResult{
 				Chart: dockerResult.Chart,
 				Image: dockerResult.Image,
+				OriginalImage: dockerResult.OriginalImage,
+				Sources: dockerResult.Sources,
+				Unverifiable: dockerResult.Unverifiable,
+				AccessDenied: dockerResult.AccessDenied,
+				Warning: dockerResult.AccessDenied && engine.options.IgnoreAuthErrors,
 				Error: dockerResult.Error,
-			}
+			})
 			continue
 		} else {
 			var err error = nil
+			warning := false
 			if !dockerResult.Exists {
 				err = fmt.Errorf("docker image does not exist: %s", dockerResult.Image)
+			} else if err = engine.options.MutableTags.check(dockerResult.Image); err != nil {
+				warning = !engine.options.MutableTags.disallowed(dockerResult.Chart.Env)
+			} else if engine.options.RequireDigest {
+				err = checkImageDigest(dockerResult.Image)
+				warning = err != nil
 			}
-			engine.resultChan <- AppCheckResult{
+			engine.emit(AppCheckResult{
 				Chart: dockerResult.Chart,
 				Image: dockerResult.Image,
+				OriginalImage: dockerResult.OriginalImage,
+				Sources: dockerResult.Sources,
+				Missing: !dockerResult.Exists,
+				Warning: warning,
 				Error: err,
-			}
+			})
 		}
 	}
 	logEngineDebug(engine.name, -1, "docker validation output closed")
+
+	// Docker validation normally only finishes once rendering, manifest
+	// validation and image extraction have all finished. When the context is
+	// cancelled it can stop first, so wait for the others before closing the
+	// channel they send errors on.
+	engine.ChartRenderingEngine.workerWaitGroup.Wait()
+	engine.ManifestValidationEngine.workerWaitGroup.Wait()
+	engine.ImageExtractionEngine.workerWaitGroup.Wait()
+	close(engine.errorChan)
+}
+
+// Reports errors from the rendering, validation and extraction engines as
+// failed results for their chart, so a failing chart doesn't stall the pipeline
+func (engine *AppCheckerEngine) pumpErrorsToAppCheckResults() {
+	defer engine.workerWaitGroup.Done()
+	for errorResult := range engine.errorChan {
+		engine.emit(AppCheckResult{
+			Chart: errorResult.Chart,
+			Stage: errorResult.Stage,
+			Error: errorResult.Error,
+		})
+	}
+	logEngineDebug(engine.name, -1, "error channel closed")
 }
 
 func (engine *AppCheckerEngine) pumpAppCheckInstructionsToChartRenderer() {
 	defer engine.workerWaitGroup.Done()
 	for instruction := range engine.inputChan {
-		engine.ChartRenderingEngine.inputChan <- ChartRenderParams{
-			Env: instruction.Chart.Env,
-			ChartName: instruction.Chart.ChartName,
-			RepoURL: instruction.Chart.RepoURL,
-			ChartVersion: instruction.Chart.ChartVersion,
-			BaseValuesFile: instruction.Chart.BaseValuesFile,
-			ValuesOverride: instruction.Chart.ValuesOverride,
+		engine.startedLock.Lock()
+		engine.started[chartKey(instruction.Chart)] = time.Now()
+		engine.startedLock.Unlock()
+		if !sendOrDone(engine.context, engine.ChartRenderingEngine.inputChan, instruction.Chart) {
+			break
 		}
 	}
 	close(engine.ChartRenderingEngine.inputChan)
-}
\ No newline at end of file
+}
+
+// Inspects each render result before validation. Charts whose render is unchanged
+// from the baseline reuse its results, hardcoded secrets and policy
+// violations are reported as failures, as are slow renders, and everything
+// else continues on to manifest validation.
+func (engine *AppCheckerEngine) pumpRenderResultsToValidation() {
+	defer engine.workerWaitGroup.Done()
+	for renderResult := range engine.ChartRenderingEngine.resultChan {
+		if engine.options.BaselineDir != "" {
+			unchanged, err := matchesBaseline(engine.options.BaselineDir, renderResult.ManifestPath)
+			if err != nil {
+				logEngineWarning(engine.name, -1, fmt.Sprintf("failed to compare %s against baseline: %v", renderResult.ManifestPath, err))
+			}
+			chart := renderResult.Chart
+			prior := engine.baselineResults[baselineChart{Env: chart.Env, Chart: chart.ChartName, Version: chart.ChartVersion}]
+			if unchanged && len(prior) > 0 {
+				logEngineDebug(engine.name, -1, fmt.Sprintf("%s unchanged from baseline, reusing its results", chart.ChartName))
+				for _, record := range prior {
+					engine.emit(baselineResult(chart, record))
+				}
+				continue
+			}
+		}
+		if engine.options.MaxRenderDuration > 0 && renderResult.Duration > engine.options.MaxRenderDuration {
+			logEngineWarning(engine.name, -1, fmt.Sprintf("chart %s took %s to render", renderResult.Chart.ChartName, renderResult.Duration))
+			engine.emit(AppCheckResult{
+				Chart: renderResult.Chart,
+				Error: fmt.Errorf("render took %s, exceeding the maximum of %s", renderResult.Duration.Round(time.Millisecond), engine.options.MaxRenderDuration),
+			})
+		}
+		if engine.options.DetectSecrets {
+			engine.reportHardcodedSecrets(renderResult)
+		}
+		if engine.options.RequireSecurityContext {
+			engine.reportSecurityContextViolations(renderResult)
+		}
+		if len(engine.options.RequiredValues) > 0 {
+			engine.reportUnsetRequiredValues(renderResult)
+		}
+		if engine.options.ConftestPolicy != "" {
+			engine.reportPolicyViolations(renderResult)
+		}
+		if !sendOrDone(engine.context, engine.ManifestValidationEngine.inputChan, renderResult) {
+			break
+		}
+	}
+	close(engine.ManifestValidationEngine.inputChan)
+}
+
+func (engine *AppCheckerEngine) reportHardcodedSecrets(renderResult RenderResult) {
+	findings, err := detectHardcodedSecrets(renderResult.ManifestPath)
+	if err != nil {
+		logEngineWarning(engine.name, -1, fmt.Sprintf("failed to scan %s for secrets: %v", renderResult.ManifestPath, err))
+		return
+	}
+	for _, finding := range findings {
+		logEngineWarning(engine.name, -1, fmt.Sprintf("possible hardcoded secret in chart %s: %s", renderResult.Chart.ChartName, finding))
+		engine.emit(AppCheckResult{
+			Chart: renderResult.Chart,
+			Error: fmt.Errorf("possible hardcoded secret in %s", finding),
+		})
+	}
+}
+
+func (engine *AppCheckerEngine) reportSecurityContextViolations(renderResult RenderResult) {
+	findings, err := checkSecurityContexts(renderResult.ManifestPath)
+	if err != nil {
+		logEngineWarning(engine.name, -1, fmt.Sprintf("failed to check security contexts in %s: %v", renderResult.ManifestPath, err))
+		return
+	}
+	for _, finding := range findings {
+		engine.emit(AppCheckResult{
+			Chart: renderResult.Chart,
+			Error: fmt.Errorf("insecure security context in %s", finding),
+		})
+	}
+}
+
+func (engine *AppCheckerEngine) reportUnsetRequiredValues(renderResult RenderResult) {
+	findings, err := checkRequiredValues(renderResult.Chart, renderResult.ManifestPath, engine.options.RequiredValues)
+	if err != nil {
+		logEngineWarning(engine.name, -1, fmt.Sprintf("failed to check required values of %s: %v", renderResult.Chart.ChartName, err))
+		return
+	}
+	for _, finding := range findings {
+		engine.emit(AppCheckResult{
+			Chart: renderResult.Chart,
+			Error: fmt.Errorf("required values check failed for %s", finding),
+		})
+	}
+}
+
+// reportPolicyViolations tests the rendered manifest against the conftest
+// policies. Unlike the scans above, a conftest that fails to run fails the
+// chart, since its violations would otherwise go unnoticed.
+func (engine *AppCheckerEngine) reportPolicyViolations(renderResult RenderResult) {
+	findings, err := runConftest(engine.context, engine.executor, engine.options.ConftestPolicy, renderResult.ManifestPath)
+	if err != nil {
+		logEngineWarning(engine.name, -1, fmt.Sprintf("failed to test %s against policies: %v", renderResult.ManifestPath, err))
+		engine.emit(AppCheckResult{
+			Chart: renderResult.Chart,
+			Error: fmt.Errorf("failed to test %s against policies: %w", renderResult.ManifestPath, err),
+		})
+		return
+	}
+	for _, finding := range findings {
+		engine.emit(AppCheckResult{
+			Chart:   renderResult.Chart,
+			Warning: finding.Warning,
+			Error:   fmt.Errorf("policy violation in %s", finding),
+		})
+	}
+}
+
+// chartKey identifies a chart independently of what rendering fills in
+func chartKey(chart ChartRenderParams) ChartRenderParams {
+	chart.AppVersion = ""
+	return chart
+}
+
+// reportChartsWithoutResults reports a passed result for every chart that made
+// it through the pipeline without a result, such as a chart without images, so
+// that every chart has an outcome. A cancelled run may have dropped charts
+// anywhere in the pipeline, so they aren't reported as passed.
+func (engine *AppCheckerEngine) reportChartsWithoutResults() {
+	if engine.context.Err() != nil {
+		return
+	}
+	engine.startedLock.Lock()
+	var charts []ChartRenderParams
+	for chart := range engine.started {
+		if !engine.reported[chart] {
+			charts = append(charts, chart)
+		}
+	}
+	engine.startedLock.Unlock()
+
+	sort.Slice(charts, func(i, j int) bool {
+		if charts[i].Env != charts[j].Env {
+			return charts[i].Env < charts[j].Env
+		}
+		return charts[i].ChartName < charts[j].ChartName
+	})
+	for _, chart := range charts {
+		engine.emit(AppCheckResult{Chart: chart})
+	}
+}
+
+// emit stamps a result with the timing of its chart and reports it
+func (engine *AppCheckerEngine) emit(result AppCheckResult) {
+	engine.startedLock.Lock()
+	started, ok := engine.started[chartKey(result.Chart)]
+	engine.reported[chartKey(result.Chart)] = true
+	engine.startedLock.Unlock()
+	if ok {
+		result.Started = started
+		result.Duration = time.Since(started)
+	}
+	engine.resultChan <- result
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to collect every result produced by an app checker engine
+func collectAppCheckResults(engine *AppCheckerEngine) []AppCheckResult {
+	results := make([]AppCheckResult, 0)
+	for result := range engine.resultChan {
+		results = append(results, result)
+	}
+	return results
+}
+
+// configMapManifest is a valid rendered manifest that references no images
+const configMapManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+// Helper function to feed charts into an app checker engine and close its input,
+// stopping early when the engine's context is cancelled
+func sendChartsToAppChecker(engine *AppCheckerEngine, charts []ChartRenderParams) {
+	go func() {
+		for _, chart := range charts {
+			if !sendOrDone(engine.context, engine.inputChan, AppCheckInstruction{Chart: chart}) {
+				break
+			}
+		}
+		close(engine.inputChan)
+	}()
+}
+
+func TestAppCheckerStopsWhenContextIsCancelled(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		time.Sleep(10 * time.Millisecond)
+		return []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.20
+`), nil, nil
+	}
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(createTestContext())
+	defer cancel()
+	engine := NewAppCheckerEngine(ctx, mockExecutor, AppCheckerOptions{OutputDir: t.TempDir()})
+	engine.Start(2)
+
+	var charts []ChartRenderParams
+	for i := 0; i < 100; i++ {
+		chart := createTestChart()
+		chart.ChartName = fmt.Sprintf("chart-%d", i)
+		charts = append(charts, chart)
+	}
+	sendChartsToAppChecker(engine, charts)
+
+	<-engine.resultChan
+	cancel()
+
+	done := make(chan []AppCheckResult)
+	go func() {
+		done <- collectAppCheckResults(engine)
+	}()
+	select {
+	case remaining := <-done:
+		assert.Less(t, len(remaining)+1, len(charts), "Expected the engine to stop producing results once cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the result channel to close after the context was cancelled")
+	}
+}
+
+func TestAppCheckerReusesResultsOfChartMatchingBaseline(t *testing.T) {
+	testChart := createTestChart()
+	otherVersion := createTestChart()
+	otherVersion.ChartVersion = "2.0.0"
+
+	// The earlier run's output directory is the baseline
+	podExecutor := func() *MockCommandExecutor {
+		mockExecutor := createMockExecutor()
+		mockExecutor.Output = []byte(sampleManifests["pod_sample"])
+		return mockExecutor
+	}
+	baselineDir := t.TempDir()
+	first := NewAppCheckerEngine(createTestContext(), podExecutor(), AppCheckerOptions{OutputDir: baselineDir})
+	first.Start(1)
+	sendChartsToAppChecker(first, []ChartRenderParams{testChart})
+	prior := collectAppCheckResults(first)
+	assert.Len(t, prior, 1)
+	assert.Equal(t, "nginx:1.14.2", prior[0].Image)
+	assert.NoError(t, writeBaselineResults(baselineDir, prior))
+
+	mockExecutor := podExecutor()
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:   t.TempDir(),
+		BaselineDir: baselineDir,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{testChart})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, len(prior), "Expected every result of the baseline to be reused")
+	for i, result := range results {
+		assert.True(t, result.Skipped, "Expected chart matching the baseline to be skipped")
+		assert.NoError(t, result.Error)
+		assert.Equal(t, testChart.ChartName, result.Chart.ChartName)
+		assert.Equal(t, prior[i].Image, result.Image)
+	}
+
+	// Only helm should have run, kubeconform and docker are skipped
+	assert.Equal(t, "helm", mockExecutor.LastCommand)
+
+	// Another version of the chart has a baseline of its own
+	mockExecutor = podExecutor()
+	engine = NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:   t.TempDir(),
+		BaselineDir: baselineDir,
+	})
+	engine.Start(1)
+	sendChartsToAppChecker(engine, []ChartRenderParams{otherVersion})
+	for _, result := range collectAppCheckResults(engine) {
+		assert.False(t, result.Skipped, "Expected a chart version without a baseline to be checked")
+	}
+	assert.Equal(t, "docker", mockExecutor.LastCommand)
+}
+
+func TestBaselineResultKeepsFailures(t *testing.T) {
+	record := resultRecord{Env: "development", Chart: "test-chart", ChartVersion: "1.0.0", Image: "nginx:1.25", Status: statusFailed, Error: "docker image does not exist: nginx:1.25", Missing: true}
+
+	result := baselineResult(createTestChart(), record)
+
+	assert.True(t, result.Skipped)
+	assert.True(t, result.failed(), "Expected a failure in the baseline to still fail the run")
+	assert.True(t, result.Missing)
+	assert.Equal(t, statusFailed, resultStatus(result))
+}
+
+func TestAppCheckerFailsSlowRenders(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		time.Sleep(50 * time.Millisecond)
+		return []byte(configMapManifest), nil, nil
+	}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:         t.TempDir(),
+		MaxRenderDuration: 10 * time.Millisecond,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.Error(t, results[0].Error)
+	assert.Contains(t, results[0].Error.Error(), "exceeding the maximum of 10ms")
+}
+
+func TestAppCheckerAllowsRendersWithinThreshold(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:         t.TempDir(),
+		MaxRenderDuration: time.Minute,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.Equal(t, statusPassed, resultStatus(results[0]), "Expected a fast render without images to pass")
+}
+
+func TestAppCheckerReportsMalformedImages(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: "nginx:"
+`)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.Equal(t, "nginx:", results[0].Image)
+	assert.EqualError(t, results[0].Error, `malformed image reference "nginx:": empty tag or digest`)
+	assert.NotContains(t, mockExecutor.History, "docker manifest inspect nginx:", "Malformed images must not be passed to docker")
+}
+
+func TestAppCheckerReportsUnparseableManifests(t *testing.T) {
+	var renders atomic.Int32
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		if renders.Add(1) == 1 {
+			return []byte("apiVersion: v1\nkind: Pod\nmetadata: [unclosed\n"), nil, nil
+		}
+		return []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+`), nil, nil
+	}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	broken := createTestChart()
+	broken.ChartName = "broken"
+	healthy := createTestChart()
+	healthy.ChartName = "healthy"
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{broken, healthy})
+	results := collectAppCheckResults(engine)
+
+	byChart := map[string]AppCheckResult{}
+	for _, result := range results {
+		byChart[result.Chart.ChartName] = result
+	}
+	assert.Len(t, results, 2)
+	assert.Error(t, byChart["broken"].Error)
+	assert.Contains(t, byChart["broken"].Error.Error(), "rendered output is not valid YAML")
+	assert.NoError(t, byChart["healthy"].Error)
+	assert.Equal(t, "nginx:1.25", byChart["healthy"].Image)
+}
+
+func TestAppCheckerReportsRenderErrors(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.FileExistsMap = map[string]bool{"values.yaml": false}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.EqualError(t, results[0].Error, "base values file does not exist: values.yaml")
+}
+
+func TestAppCheckerReportsEveryRenderFailureWithoutBlocking(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		return nil, []byte("Error: chart not found"), fmt.Errorf("exit status 1")
+	}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	// More failing charts than workers, so a worker has to send several errors
+	var charts []ChartRenderParams
+	for i := 0; i < 5; i++ {
+		chart := createTestChart()
+		chart.ChartName = fmt.Sprintf("chart-%d", i)
+		charts = append(charts, chart)
+	}
+	sendChartsToAppChecker(engine, charts)
+
+	done := make(chan []AppCheckResult)
+	go func() {
+		done <- collectAppCheckResults(engine)
+	}()
+	select {
+	case results := <-done:
+		assert.Len(t, results, len(charts))
+		for _, result := range results {
+			assert.Error(t, result.Error)
+			assert.True(t, result.failed())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected every render failure to reach the result channel")
+	}
+}
+
+func TestAppCheckerClassifiesAccessDenied(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ignore auth errors %v", ignore), func(t *testing.T) {
+			mockExecutor := createMockExecutor()
+			mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+				return []byte("unauthorized: authentication required"), fmt.Errorf("exit status 1")
+			}
+			mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: registry.example.com/private:1.0
+`)
+
+			engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+				OutputDir:        t.TempDir(),
+				IgnoreAuthErrors: ignore,
+			})
+			engine.Start(1)
+
+			sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+			results := collectAppCheckResults(engine)
+
+			assert.Len(t, results, 1)
+			assert.True(t, results[0].AccessDenied)
+			assert.Contains(t, results[0].Error.Error(), "access denied to registry.example.com/private:1.0")
+			assert.Equal(t, ignore, results[0].Warning)
+			if ignore {
+				assert.Equal(t, statusWarning, resultStatus(results[0]))
+			} else {
+				assert.Equal(t, statusAccessDenied, resultStatus(results[0]))
+			}
+		})
+	}
+}
+
+func TestAppCheckerDoesntClassifyValidationFailuresAsAccessDenied(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		if mockExecutor.LastCommand != "kubeconform" {
+			return mockExecutor.Output, nil, nil
+		}
+		// A schema location refusing the request is a validation failure, not a registry one
+		return nil, []byte("failed downloading schema: unauthorized: authentication required"), fmt.Errorf("exit status 1")
+	}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{OutputDir: t.TempDir()})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.False(t, results[0].AccessDenied)
+	assert.Equal(t, statusFailed, resultStatus(results[0]))
+	assert.Contains(t, results[0].Error.Error(), "kubeconform command failed")
+}
+
+func TestAppCheckerAccountsForEveryChartInTheSummary(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+	mockExecutor.FileExistsMap = map[string]bool{"broken-values.yaml": false}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	good := createTestChart()
+	good.ChartName = "good"
+	broken := createTestChart()
+	broken.ChartName = "broken"
+	broken.BaseValuesFile = "broken-values.yaml"
+	sendChartsToAppChecker(engine, []ChartRenderParams{good, broken})
+	results := collectAppCheckResults(engine)
+
+	summary := Aggregate(results)
+	assert.Equal(t, 1, summary.Passed, "Expected the chart without images to be counted as passed")
+	assert.Equal(t, 1, summary.Failed, "Expected the chart that failed to render to be counted as failed")
+	assert.Equal(t, 2, summary.Total())
+
+	var out bytes.Buffer
+	for _, result := range results {
+		printResult(&out, result)
+	}
+	assert.Contains(t, out.String(), ">>> chart good 1.0.0 from env development: ✓ All checks passed, no images")
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"text/template"
+	"time"
 )
 
 
@@ -21,11 +24,75 @@ type ChartRenderingEngine struct {
 	executor   CommandExecutor
 	name	   string
 	workerWaitGroup sync.WaitGroup
+
+	// Length of the hash suffix added to rendered filenames, defaults to 6 and
+	// is at least minSuffixLength
+	suffixLength int
+
+	// nameTemplate builds rendered filenames, nil means defaultManifestNameTemplate
+	nameTemplate *template.Template
+
+	// serialWrites hands rendered manifests to a single writer goroutine so
+	// that rendering workers don't compete for disk I/O
+	serialWrites bool
+	writeChan    chan manifestWrite
+
+	// ociAuth configures authentication per OCI registry host, logins are done once per host
+	ociAuth   map[string]ociRegistryAuth
+	ociLogins ociLogins
+
+	// counters are exposed on the metrics endpoint
+	counters engineCounters
+
+	// appVersions looks up each chart's appVersion with helm show chart after rendering
+	appVersions bool
+
+	// helmArgs are appended to every helm template command, one argument each, e.g. --kube-version=1.29
+	helmArgs []string
+
+	// renderTimeout bounds each helm template command, zero means no limit
+	renderTimeout time.Duration
+
+	// buildDeps runs helm dependency build once per vendored chart before rendering it
+	buildDeps    bool
+	dependencies chartDependencies
+
+	// claimedPaths remembers which chart each output file was written for, so
+	// two charts can never silently overwrite each other's manifest
+	claimedPaths map[string]ChartRenderParams
+	claimLock    sync.Mutex
+
+	// renderedCharts counts the renders of each chart, giving identical
+	// charts in one run distinct suffixes
+	renderedCharts map[string]int
+
+	// fullSuffixes holds the hashes planSuffixes found to collide once
+	// shortened, which are used in full
+	fullSuffixes map[string]bool
 }
 
+// manifestWrite asks the writer goroutine to write data to path and report back on done
+type manifestWrite struct {
+	path string
+	data []byte
+	done chan error
+}
+
+const defaultSuffixLength = 6
+
+// minSuffixLength keeps shortened hashes from colliding all the time
+const minSuffixLength = 4
+
+// defaultRenderTimeout is how long a helm template command may run by default
+const defaultRenderTimeout = 3 * time.Minute
+
 type RenderResult struct {
 	Chart            ChartRenderParams
 	ManifestPath string
+	// Duration is how long helm took to render the chart
+	Duration     time.Duration
+	// Warnings is what helm wrote to stderr, kept out of the manifest file
+	Warnings     string
 }
 
 func (engine *ChartRenderingEngine) Start(workerCount int) {
@@ -35,6 +102,11 @@ func (engine *ChartRenderingEngine) Start(workerCount int) {
 		panic("This should not happen")
 	}
 
+	if engine.serialWrites {
+		engine.writeChan = make(chan manifestWrite)
+		go engine.writer()
+	}
+
 	for i := 0; i < workerCount; i++ {
 		engine.workerWaitGroup.Add(1)		
 		go func(workerId int) {
@@ -46,6 +118,9 @@ func (engine *ChartRenderingEngine) Start(workerCount int) {
 
 func (engine *ChartRenderingEngine) allDoneWorker() {
 	engine.workerWaitGroup.Wait()
+	if engine.writeChan != nil {
+		close(engine.writeChan)
+	}
 	logEngineDebug(engine.name,-1,"all workers done, closing output channel")	
 	close(engine.resultChan)
 }
@@ -61,12 +136,16 @@ func (engine *ChartRenderingEngine) worker(workerId int) {
 				return
 			}
 
+			engine.counters.received.Add(1)
 			result, err := engine.renderSingleChart(chart, workerId)
+			engine.counters.finish(err)
 			if err != nil {
-				engine.errorChan <- ErrorResult{Chart: chart, Error: err}
+				engine.errorChan <- ErrorResult{Chart: chart, Stage: stageRender, Error: err}
 				continue
 			}
-			engine.resultChan <- *result
+			if !sendOrDone(engine.context, engine.resultChan, *result) {
+				return
+			}
 		case <-engine.context.Done():
 			logEngineDebug(engine.name, workerId, "context done")
 			return
@@ -77,43 +156,126 @@ func (engine *ChartRenderingEngine) worker(workerId int) {
 
 func (engine *ChartRenderingEngine) renderSingleChart(chart ChartRenderParams, workerId int) (*RenderResult, error) {
 
-	if !engine.executor.FileExists(chart.BaseValuesFile) {
+	if chart.BaseValuesFile != "" && !engine.executor.FileExists(chart.BaseValuesFile) {
 		msg := fmt.Sprintf("base values file does not exist: %s", chart.BaseValuesFile)
 		logEngineWarning(engine.name, workerId, msg)
 		return nil, fmt.Errorf("base values file does not exist: %s", chart.BaseValuesFile)
 	}
-	if !engine.executor.FileExists(chart.ValuesOverride) {
+	if chart.ValuesOverride != "" && !engine.executor.FileExists(chart.ValuesOverride) {
 		msg := fmt.Sprintf("values override file does not exist: %s", chart.ValuesOverride)
 		logEngineWarning(engine.name, workerId, msg)
 		return nil, fmt.Errorf("values override file does not exist: %s", chart.ValuesOverride)
 	}
+	suffix := engine.filenameSuffix(chart)
 
+	release := chart.ChartName
+	if chart.ReleaseName != "" {
+		release = chart.ReleaseName
+	}
 	args := []string{
-		"template", chart.ChartName,
+		"template", release,
 		"--release-name", chart.ChartName,
 		"--repo", chart.RepoURL,
-		"-f", chart.BaseValuesFile,
-		"-f", chart.ValuesOverride,
-		"--version", chart.ChartVersion,
-		"--include-crds",
 	}
+	if host := ociHost(chart.RepoURL); host != "" {
+		// OCI charts are referenced directly, helm template has no --repo for them
+		args = []string{
+			"template", release, strings.TrimSuffix(chart.RepoURL, "/") + "/" + chart.ChartName,
+		}
+		if auth, ok := engine.ociAuth[host]; ok {
+			if auth.RegistryConfig != "" {
+				args = append(args, "--registry-config", auth.RegistryConfig)
+			} else if err := engine.ociLogins.ensure(engine.context, engine.executor, host, auth); err != nil {
+				logEngineWarning(engine.name, workerId, err.Error())
+				return nil, err
+			}
+		}
+	}
+	// A chart without a repo is vendored, its name is the path of its
+	// directory, already resolved against -src-prefix by discovery
+	local := chart.RepoURL == ""
+	if local {
+		if !engine.executor.FileExists(chart.ChartName) {
+			msg := fmt.Sprintf("vendored chart not found: %s", chart.ChartName)
+			logEngineWarning(engine.name, workerId, msg)
+			return nil, fmt.Errorf("vendored chart not found: %s", chart.ChartName)
+		}
+		// Vendored charts are rendered from their directory, without a repo or version
+		if chart.ReleaseName == "" {
+			release = filepath.Base(chart.ChartName)
+		}
+		args = []string{"template", release, chart.ChartName}
+		if engine.buildDeps {
+			if err := engine.dependencies.ensure(engine.context, engine.executor, chart.ChartName); err != nil {
+				logEngineWarning(engine.name, workerId, err.Error())
+				return nil, err
+			}
+		}
+	}
+	for _, valuesFile := range []string{chart.BaseValuesFile, chart.ValuesOverride} {
+		if valuesFile != "" {
+			args = append(args, "-f", valuesFile)
+		}
+	}
+	if chart.InlineValues != "" {
+		valuesFile, err := engine.writeInlineValues(chart, suffix)
+		if err != nil {
+			logEngineWarning(engine.name, workerId, err.Error())
+			return nil, err
+		}
+		args = append(args, "-f", valuesFile)
+	}
+	if !local {
+		args = append(args, "--version", chart.ChartVersion)
+	}
+	args = append(args, "--include-crds")
+	if chart.Namespace != "" {
+		args = append(args, "--namespace", chart.Namespace)
+	}
+	args = append(args, engine.helmArgs...)
 
 	logEngineDebug(engine.name, workerId, fmt.Sprintf("helm %s", strings.Join(args, " ")))
-	cmd := engine.executor.CommandContext(engine.context, "helm", args...)
+	ctx := engine.context
+	if engine.renderTimeout > 0 {
+		// A hung chart pull must not stall the worker for the rest of the run
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(engine.context, engine.renderTimeout)
+		defer cancel()
+	}
+	cmd := engine.executor.CommandContext(ctx, "helm", args...)
 	
 	// Set working directory to current directory so relative paths work
 	if wd, err := os.Getwd(); err == nil {
 		cmd.SetDir(wd)
 	}
 	
-	output, err := cmd.CombinedOutput()
+	started := time.Now()
+	output, stderr, err := cmd.SplitOutput()
+	duration := time.Since(started)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logEngineWarning(engine.name, workerId, fmt.Sprintf("helm template of chart %s timed out after %s", chart.ChartName, engine.renderTimeout))
+		return nil, fmt.Errorf("helm template timed out after %s: %w", engine.renderTimeout, err)
+	}
 	if err != nil {
-		msg := fmt.Sprintf("helm command failed: %s\nOutput: %s", err.Error(), string(output))
+		msg := fmt.Sprintf("helm command failed: %s\nOutput: %s", err.Error(), string(stderr))
 		logEngineWarning(engine.name, workerId, msg)
 		return nil, fmt.Errorf("helm command failed: %w", err)
 	}
+	warnings := strings.TrimSpace(string(stderr))
+	if warnings != "" {
+		logEngineWarning(engine.name, workerId, fmt.Sprintf("helm warnings for chart %s: %s", chart.ChartName, warnings))
+	}
 
-	logEngineDebug(engine.name, workerId, fmt.Sprintf("helm %s\t\tCOMPLETED", strings.Join(args, " ")))
+	logEngineDebug(engine.name, workerId, fmt.Sprintf("helm %s\t\tCOMPLETED in %s", strings.Join(args, " "), duration))
+
+	if engine.appVersions {
+		// A missing appVersion is only reported, it doesn't fail the chart
+		appVersion, err := chartAppVersion(engine.context, engine.executor, chart, engine.ociAuth[ociHost(chart.RepoURL)])
+		if err != nil {
+			logEngineWarning(engine.name, workerId, err.Error())
+		}
+		chart.AppVersion = appVersion
+	}
 
 	// Create output file path using release name (use absolute path for output)
 	absOutputDir, err := filepath.Abs(engine.outputDir)
@@ -123,26 +285,140 @@ func (engine *ChartRenderingEngine) renderSingleChart(chart ChartRenderParams, w
 		return nil, fmt.Errorf("failed to get absolute path for output dir: %w", err)
 	}
 	
-	randStr := generateRandomString(6)
-	filename := fmt.Sprintf("%s_%s.yaml", chart.ChartName, randStr)
-	outputPath := filepath.Join(absOutputDir, filename)
+	outputPath, err := engine.claimManifestPath(absOutputDir, chart, suffix)
+	if err != nil {
+		logEngineWarning(engine.name, workerId, err.Error())
+		return nil, err
+	}
 
 	// Write rendered manifests to file
-	if err := os.WriteFile(outputPath, output, 0644); err != nil {
+	if err := engine.writeManifest(outputPath, output); err != nil {
 		msg := fmt.Sprintf("failed to write rendered manifest to file: %s", err.Error())
 		logEngineWarning(engine.name, workerId, msg)
 		return nil, fmt.Errorf("failed to write rendered manifest to file: %w", err)
 	}
 
-	return &RenderResult{Chart: chart, ManifestPath: outputPath}, nil
+	return &RenderResult{Chart: chart, ManifestPath: outputPath, Duration: duration, Warnings: warnings}, nil
+}
+
+// claimManifestPath names the manifest of chart with its hash cut to the
+// suffix length and claims it. Hashes planSuffixes found to collide are used
+// in full, as is the hash of a chart it didn't see that collides anyway.
+func (engine *ChartRenderingEngine) claimManifestPath(outputDir string, chart ChartRenderParams, hash string) (string, error) {
+	lengths := []int{min(engine.filenameSuffixLength(), len(hash)), len(hash)}
+	if engine.fullSuffixes[hash] {
+		lengths = lengths[1:]
+	}
+	var err error
+	for _, length := range lengths {
+		filename, nameErr := manifestName(engine.nameTemplate, chart, hash[:length])
+		if nameErr != nil {
+			return "", nameErr
+		}
+		path := filepath.Join(outputDir, filename)
+		if err = engine.claimOutputPath(path, chart); err == nil {
+			return path, nil
+		}
+	}
+	return "", err
+}
+
+// claimOutputPath reserves path for chart, failing when another chart already wrote there
+func (engine *ChartRenderingEngine) claimOutputPath(path string, chart ChartRenderParams) error {
+	engine.claimLock.Lock()
+	defer engine.claimLock.Unlock()
+
+	if engine.claimedPaths == nil {
+		engine.claimedPaths = map[string]ChartRenderParams{}
+	}
+	if other, ok := engine.claimedPaths[path]; ok {
+		return fmt.Errorf("output file %s for chart %s %s from env %s collides with chart %s %s from env %s",
+			path, chart.ChartName, chart.ChartVersion, chart.Env, other.ChartName, other.ChartVersion, other.Env)
+	}
+	engine.claimedPaths[path] = chart
+	return nil
+}
+
+// writer performs every manifest write requested on writeChan, one at a time
+func (engine *ChartRenderingEngine) writer() {
+	for request := range engine.writeChan {
+		request.done <- os.WriteFile(request.path, request.data, 0644)
+	}
+	logEngineDebug(engine.name, -1, "writer done")
+}
+
+// writeManifest writes directly, or through the writer goroutine when serialWrites is enabled
+func (engine *ChartRenderingEngine) writeManifest(path string, data []byte) error {
+	if engine.writeChan == nil {
+		return os.WriteFile(path, data, 0644)
+	}
+	done := make(chan error, 1)
+	engine.writeChan <- manifestWrite{path: path, data: data, done: done}
+	return <-done
+}
+
+// writeInlineValues stores the inline values of a chart next to the rendered output so helm can read them
+func (engine *ChartRenderingEngine) writeInlineValues(chart ChartRenderParams, suffix string) (string, error) {
+	valuesDir := filepath.Join(engine.outputDir, "values")
+	if err := os.MkdirAll(valuesDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create inline values directory: %w", err)
+	}
+	valuesPath, err := filepath.Abs(filepath.Join(valuesDir, fmt.Sprintf("%s_%s.yaml", chart.ChartName, suffix)))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path for inline values: %w", err)
+	}
+	if err := os.WriteFile(valuesPath, []byte(chart.InlineValues), 0644); err != nil {
+		return "", fmt.Errorf("failed to write inline values: %w", err)
+	}
+	return valuesPath, nil
+}
+
+// planSuffixes finds, over every chart of the run, the charts whose hash
+// collides with another chart's once shortened. They get the full hash up
+// front, so which of them keeps the short one doesn't depend on which worker
+// renders first. Call it before Start.
+func (engine *ChartRenderingEngine) planSuffixes(charts []ChartRenderParams) {
+	length := min(engine.filenameSuffixLength(), sha256.Size*2)
+	occurrences := map[string]int{}
+	byShortHash := map[string][]string{}
+	for _, chart := range charts {
+		// Counted the same way as filenameSuffix does
+		key := chartSuffix(chart, 0, sha256.Size*2)
+		hash := chartSuffix(chart, occurrences[key], sha256.Size*2)
+		occurrences[key]++
+		byShortHash[hash[:length]] = append(byShortHash[hash[:length]], hash)
+	}
+
+	engine.fullSuffixes = map[string]bool{}
+	for _, hashes := range byShortHash {
+		if len(hashes) > 1 {
+			for _, hash := range hashes {
+				engine.fullSuffixes[hash] = true
+			}
+		}
+	}
+}
+
+func (engine *ChartRenderingEngine) filenameSuffixLength() int {
+	if engine.suffixLength > 0 {
+		return max(engine.suffixLength, minSuffixLength)
+	}
+	return defaultSuffixLength
 }
 
-// Suffix the files just in case two charts end up having the same name
-func generateRandomString(length int) string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[rand.Int63()%int64(len(charset))]
+// filenameSuffix returns the full hash the files rendered for chart are
+// suffixed with. It only depends on the chart, so reruns write the same files,
+// except that a chart repeated within a run gets a new hash each time it is
+// rendered.
+func (engine *ChartRenderingEngine) filenameSuffix(chart ChartRenderParams) string {
+	engine.claimLock.Lock()
+	defer engine.claimLock.Unlock()
+
+	if engine.renderedCharts == nil {
+		engine.renderedCharts = map[string]int{}
 	}
-	return string(b)
+	key := chartSuffix(chart, 0, sha256.Size*2)
+	occurrence := engine.renderedCharts[key]
+	engine.renderedCharts[key]++
+	return chartSuffix(chart, occurrence, sha256.Size*2)
 }
@@ -2,7 +2,11 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -48,6 +52,168 @@ func TestRenderBasics(t *testing.T) {
 	assert.Equal(t, expectedCommand, actualCommand)
 }
 
+func TestRenderAppendsExtraHelmArgs(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		inputChan:  make(chan ChartRenderParams),
+		resultChan: make(chan RenderResult),
+		outputDir:  "test_output",
+		context:    context.Background(),
+		executor:   mockExecutor,
+		helmArgs:   []string{"--kube-version", "1.29", "--set", "ingress.enabled=true"},
+	}
+	engine.Start(1)
+	defer cleanupEngine(engine)
+
+	engine.inputChan <- createTestChart()
+	<-engine.resultChan
+
+	expectedCommand := "helm template test-chart --release-name test-chart --repo https://example.com/charts -f values.yaml -f override.yaml --version 1.0.0 --include-crds --kube-version 1.29 --set ingress.enabled=true"
+	assert.Equal(t, expectedCommand, mockExecutor.GetFullCommand())
+}
+
+func TestRenderCommandForHTTPAndOCIRepositories(t *testing.T) {
+	tests := []struct {
+		name     string
+		repoURL  string
+		expected string
+	}{
+		{"http repository", "https://example.com/charts", "helm template test-chart --release-name test-chart --repo https://example.com/charts --version 1.0.0 --include-crds"},
+		{"oci registry", "oci://registry.example.com/charts", "helm template test-chart oci://registry.example.com/charts/test-chart --version 1.0.0 --include-crds"},
+		{"oci registry with trailing slash", "oci://registry.example.com/charts/", "helm template test-chart oci://registry.example.com/charts/test-chart --version 1.0.0 --include-crds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecutor := createMockExecutor()
+			engine := &ChartRenderingEngine{
+				outputDir: t.TempDir(),
+				context:   context.Background(),
+				executor:  mockExecutor,
+			}
+			chart := createTestChart()
+			chart.RepoURL = tt.repoURL
+			chart.BaseValuesFile = ""
+			chart.ValuesOverride = ""
+
+			_, err := engine.renderSingleChart(chart, 0)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, mockExecutor.GetFullCommand())
+		})
+	}
+}
+
+func TestRenderVendoredChartFromPath(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.FileExistsMap = map[string]bool{"charts/web": true}
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+	}
+	chart := createTestChart()
+	chart.ChartName = "charts/web"
+	chart.RepoURL = ""
+
+	_, err := engine.renderSingleChart(chart, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "helm template web charts/web -f values.yaml -f override.yaml --include-crds", mockExecutor.GetFullCommand())
+}
+
+func TestRenderVendoredChartNotFound(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.FileExistsMap = map[string]bool{"charts/missing": false}
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+	}
+	chart := createTestChart()
+	chart.ChartName = "charts/missing"
+	chart.RepoURL = ""
+
+	_, err := engine.renderSingleChart(chart, 0)
+
+	assert.EqualError(t, err, "vendored chart not found: charts/missing")
+	assert.Empty(t, mockExecutor.History, "Expected helm not to be run without a repo")
+}
+
+func TestRenderTimesOutHungHelmCommand(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		// Block like a hung chart pull until the command's context gives up
+		<-mockExecutor.LastContext.Done()
+		return nil, nil, mockExecutor.LastContext.Err()
+	}
+	engine := &ChartRenderingEngine{
+		outputDir:     t.TempDir(),
+		context:       context.Background(),
+		executor:      mockExecutor,
+		renderTimeout: 50 * time.Millisecond,
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := engine.renderSingleChart(createTestChart(), 0)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "helm template timed out after 50ms")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the render timeout to stop the hung helm command")
+	}
+}
+
+func TestRenderTimeoutReachesErrorChannel(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		<-mockExecutor.LastContext.Done()
+		return nil, nil, mockExecutor.LastContext.Err()
+	}
+	engine := &ChartRenderingEngine{
+		inputChan:     make(chan ChartRenderParams),
+		resultChan:    make(chan RenderResult),
+		errorChan:     make(chan ErrorResult),
+		outputDir:     t.TempDir(),
+		context:       context.Background(),
+		executor:      mockExecutor,
+		renderTimeout: 50 * time.Millisecond,
+	}
+	engine.Start(1)
+
+	engine.inputChan <- createTestChart()
+
+	select {
+	case errResult := <-engine.errorChan:
+		assert.Equal(t, "test-chart", errResult.Chart.ChartName)
+		assert.Contains(t, errResult.Error.Error(), "timed out")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an error result for the chart that timed out")
+	}
+	close(engine.inputChan)
+}
+
+func TestRenderPassesNamespaceOnlyWhenSet(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := createEngine(mockExecutor, false)
+	defer cleanupEngine(engine)
+
+	engine.inputChan <- createTestChart()
+	<-engine.resultChan
+	assert.NotContains(t, mockExecutor.LastArgs, "--namespace")
+
+	chart := createTestChart()
+	chart.Namespace = "ingress"
+	engine.inputChan <- chart
+	<-engine.resultChan
+	assert.Equal(t, "helm template test-chart --release-name test-chart --repo https://example.com/charts -f values.yaml -f override.yaml --version 1.0.0 --include-crds --namespace ingress", mockExecutor.GetFullCommand())
+}
+
 func TestRenderBaseFileNotExist(t *testing.T) {
 	mockExecutor := createMockExecutor()
 	mockExecutor.FileExistsMap = map[string]bool{
@@ -65,4 +231,230 @@ func TestRenderBaseFileNotExist(t *testing.T) {
 	assert.Equal(t, errorResult.Chart.ChartName, testChart.ChartName)
 	assert.NotNil(t, errorResult.Error)
 	assert.Contains(t, errorResult.Error.Error(), "base values file does not exist")
-}
\ No newline at end of file
+}
+func TestRenderSameChartNameGetsDistinctFilenames(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		outputDir:    t.TempDir(),
+		context:      context.Background(),
+		executor:     mockExecutor,
+		suffixLength: 10,
+	}
+
+	first, err := engine.renderSingleChart(createTestChart(), 0)
+	assert.NoError(t, err)
+	second, err := engine.renderSingleChart(createTestChart(), 0)
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.ManifestPath, second.ManifestPath)
+	assert.Regexp(t, `test-chart_[a-zA-Z0-9]{10}\.yaml$`, first.ManifestPath)
+	assert.FileExists(t, first.ManifestPath)
+	assert.FileExists(t, second.ManifestPath)
+}
+
+func TestRenderWithSerialWrites(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		inputChan:    make(chan ChartRenderParams),
+		resultChan:   make(chan RenderResult),
+		errorChan:    make(chan ErrorResult),
+		outputDir:    t.TempDir(),
+		context:      context.Background(),
+		executor:     mockExecutor,
+		serialWrites: true,
+	}
+	engine.Start(4)
+
+	const chartCount = 12
+	go func() {
+		for i := 0; i < chartCount; i++ {
+			engine.inputChan <- createTestChart()
+		}
+		close(engine.inputChan)
+	}()
+
+	paths := map[string]bool{}
+	for result := range engine.resultChan {
+		paths[result.ManifestPath] = true
+		content, err := os.ReadFile(result.ManifestPath)
+		assert.NoError(t, err)
+		assert.Equal(t, mockExecutor.Output, content)
+	}
+	assert.Len(t, paths, chartCount)
+
+	// The writer goroutine is shut down together with the workers
+	_, open := <-engine.writeChan
+	assert.False(t, open)
+}
+
+func TestRenderKeepsHelmStderrOutOfManifest(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n")
+	mockExecutor.Stderr = []byte("walk.go:74: found symbolic link in path\nWARNING: Kubernetes configuration file is group-readable\n")
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+	}
+
+	result, err := engine.renderSingleChart(createTestChart(), 0)
+	assert.NoError(t, err)
+
+	written, err := os.ReadFile(result.ManifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, string(mockExecutor.Output), string(written))
+	assert.Equal(t, "walk.go:74: found symbolic link in path\nWARNING: Kubernetes configuration file is group-readable", result.Warnings)
+}
+
+func TestRenderRecordsAppVersion(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		if mockExecutor.LastArgs[0] == "show" {
+			return []byte(showChartOutput), nil, nil
+		}
+		return []byte(configMapManifest), nil, nil
+	}
+	engine := &ChartRenderingEngine{
+		outputDir:   t.TempDir(),
+		context:     context.Background(),
+		executor:    mockExecutor,
+		appVersions: true,
+	}
+
+	result, err := engine.renderSingleChart(createTestChart(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.4.1", result.Chart.AppVersion)
+	assert.Contains(t, mockExecutor.History, "helm show chart test-chart --repo https://example.com/charts --version 1.0.0")
+
+	written, err := os.ReadFile(result.ManifestPath)
+	assert.NoError(t, err)
+	assert.Equal(t, configMapManifest, string(written))
+}
+
+func TestRenderSameChartInDifferentEnvsGetsDistinctFilenames(t *testing.T) {
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  createMockExecutor(),
+	}
+
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+
+	first, err := engine.renderSingleChart(development, 0)
+	assert.NoError(t, err)
+	second, err := engine.renderSingleChart(production, 0)
+	assert.NoError(t, err)
+
+	assert.Regexp(t, `development_test-chart_[a-zA-Z0-9]{6}\.yaml$`, first.ManifestPath)
+	assert.Regexp(t, `production_test-chart_[a-zA-Z0-9]{6}\.yaml$`, second.ManifestPath)
+}
+
+func TestClaimOutputPathDetectsCollisions(t *testing.T) {
+	engine := &ChartRenderingEngine{}
+
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+
+	assert.NoError(t, engine.claimOutputPath("/manifests/test-chart.yaml", development))
+	err := engine.claimOutputPath("/manifests/test-chart.yaml", production)
+	assert.EqualError(t, err, "output file /manifests/test-chart.yaml for chart test-chart 1.0.0 from env production collides with chart test-chart 1.0.0 from env development")
+	assert.NoError(t, engine.claimOutputPath("/manifests/other-chart.yaml", production))
+}
+
+func TestRenderFilenamesAreStableAcrossRuns(t *testing.T) {
+	web := createTestChart()
+	web.ChartName = "web"
+	otherRepo := web
+	otherRepo.RepoURL = "https://charts.example.com/other"
+
+	render := func() []string {
+		engine := &ChartRenderingEngine{
+			outputDir: t.TempDir(),
+			context:   context.Background(),
+			executor:  createMockExecutor(),
+		}
+		var names []string
+		for _, chart := range []ChartRenderParams{web, otherRepo, web} {
+			result, err := engine.renderSingleChart(chart, 0)
+			assert.NoError(t, err)
+			names = append(names, filepath.Base(result.ManifestPath))
+		}
+		return names
+	}
+
+	first := render()
+	assert.Equal(t, first, render(), "Expected every run to use the same filenames")
+	assert.Regexp(t, `^development_web_[0-9a-f]{6}\.yaml$`, first[0])
+	assert.NotEqual(t, first[0], first[1], "Expected charts with the same name to get distinct filenames")
+	assert.NotEqual(t, first[0], first[2], "Expected a repeated chart to get a distinct filename")
+}
+
+func TestRenderFallsBackToFullHashWhenSuffixesCollide(t *testing.T) {
+	// Find two versions of a chart whose hashes share the shortest suffix
+	seen := map[string]ChartRenderParams{}
+	var first, second ChartRenderParams
+	for i := 0; second.ChartName == ""; i++ {
+		chart := createTestChart()
+		chart.ChartVersion = fmt.Sprintf("1.0.%d", i)
+		suffix := chartSuffix(chart, 0, minSuffixLength)
+		if other, ok := seen[suffix]; ok {
+			first, second = other, chart
+		}
+		seen[suffix] = chart
+	}
+
+	engine := &ChartRenderingEngine{
+		outputDir:    t.TempDir(),
+		context:      context.Background(),
+		executor:     createMockExecutor(),
+		suffixLength: 1,
+	}
+	assert.Equal(t, minSuffixLength, engine.filenameSuffixLength())
+
+	firstResult, err := engine.renderSingleChart(first, 0)
+	assert.NoError(t, err)
+	secondResult, err := engine.renderSingleChart(second, 0)
+	assert.NoError(t, err, "Expected a colliding suffix not to fail the chart")
+
+	assert.Equal(t, "development_test-chart_"+chartSuffix(first, 0, minSuffixLength)+".yaml", filepath.Base(firstResult.ManifestPath))
+	assert.Equal(t, "development_test-chart_"+chartSuffix(second, 0, 64)+".yaml", filepath.Base(secondResult.ManifestPath))
+}
+
+func TestPlannedSuffixesDontDependOnRenderOrder(t *testing.T) {
+	// Find two versions of a chart whose hashes share the shortest suffix
+	seen := map[string]ChartRenderParams{}
+	var first, second ChartRenderParams
+	for i := 0; second.ChartName == ""; i++ {
+		chart := createTestChart()
+		chart.ChartVersion = fmt.Sprintf("1.0.%d", i)
+		suffix := chartSuffix(chart, 0, minSuffixLength)
+		if other, ok := seen[suffix]; ok {
+			first, second = other, chart
+		}
+		seen[suffix] = chart
+	}
+	unrelated := createTestChart()
+	unrelated.ChartName = "unrelated"
+
+	for _, order := range [][]ChartRenderParams{{first, second}, {second, first}} {
+		engine := &ChartRenderingEngine{
+			outputDir:    t.TempDir(),
+			context:      context.Background(),
+			executor:     createMockExecutor(),
+			suffixLength: minSuffixLength,
+		}
+		engine.planSuffixes([]ChartRenderParams{first, second, unrelated})
+
+		for _, chart := range order {
+			result, err := engine.renderSingleChart(chart, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, "development_test-chart_"+chartSuffix(chart, 0, 64)+".yaml", filepath.Base(result.ManifestPath), "Expected both colliding charts to get the full hash")
+		}
+		result, err := engine.renderSingleChart(unrelated, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "development_unrelated_"+chartSuffix(unrelated, 0, minSuffixLength)+".yaml", filepath.Base(result.ManifestPath))
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -16,7 +15,37 @@ import (
 
 // DockerImageValidationResult represents the result of validating a single Docker image
 
+// DockerValidationCache stores validation results keyed by image reference.
+// Implementations may be backed by memory, files or a shared service.
+type DockerValidationCache interface {
+	Get(image string) (DockerImageValidationResult, bool)
+	Set(image string, result DockerImageValidationResult)
+}
+
+// memoryValidationCache is the default in-process cache. The engine guards
+// access to it with its cacheLock.
+type memoryValidationCache struct {
+	results map[string]DockerImageValidationResult
+}
+
+func newMemoryValidationCache() *memoryValidationCache {
+	return &memoryValidationCache{results: map[string]DockerImageValidationResult{}}
+}
 
+func (c *memoryValidationCache) Get(image string) (DockerImageValidationResult, bool) {
+	result, found := c.results[image]
+	return result, found
+}
+
+func (c *memoryValidationCache) Set(image string, result DockerImageValidationResult) {
+	c.results[image] = result
+}
+
+// persistentValidationCache is a cache that is saved once the engine is done
+type persistentValidationCache interface {
+	DockerValidationCache
+	Save() error
+}
 
 type DockerImageValidationEngine struct {
 	inputChan  chan ImageExtractionResult
@@ -25,55 +54,155 @@ type DockerImageValidationEngine struct {
 	executor CommandExecutor
 	context context.Context
 
-	cache  map[string]DockerImageValidationResult	
+	cache  DockerValidationCache
 	pending map[string]*sync.WaitGroup
 	cacheLock sync.RWMutex
 
 	name string
 
 	workerWaitGroup sync.WaitGroup
+
+	// counters are exposed on the metrics endpoint
+	counters engineCounters
+
+	// signatures, when set, verifies the signature of every image that exists
+	signatures *signatureVerifier
+
+	// registryClient, when set, looks images up over the registry HTTP API
+	// instead of with a command. Every image is collected first, so that each
+	// registry host is asked for one token covering all of its images.
+	registryClient registryLookup
+
+	// registryTool looks images up in their registry, docker when not set
+	registryTool registryTool
+
+	// platform, when set, fails images that have no manifest for it
+	platform *imagePlatform
+
+	// registrySlots, when set, caps the number of images looked up in their
+	// registry at once, whatever the number of workers
+	registrySlots chan struct{}
+
+	// dockerConfig, when set, is the DOCKER_CONFIG directory whose config.json
+	// holds the registry credentials, instead of ~/.docker
+	dockerConfig string
 }
 
 func (engine *DockerImageValidationEngine) Start(workerCount int) {
+	if engine.cache == nil {
+		engine.cache = newMemoryValidationCache()
+	}
+	input := engine.inputChan
+	if engine.registryClient != nil {
+		input = engine.batchInputs()
+	}
 	for i := 0; i < workerCount; i++ {
 		engine.workerWaitGroup.Add(1)		
 		go func(workerId int) {
-			engine.worker(workerId)
+			engine.worker(workerId, input)
 		}(i)
 	}
 	go engine.allDoneWorker()
 }
 
+// send counts a finished image and hands its result on
+func (engine *DockerImageValidationEngine) send(result DockerImageValidationResult) {
+	engine.counters.finish(result.Error)
+	engine.outputChan <- result
+}
+
 func (engine *DockerImageValidationEngine) allDoneWorker() {
 	engine.workerWaitGroup.Wait()
+	if persistent, ok := engine.cache.(persistentValidationCache); ok {
+		engine.cacheLock.Lock()
+		if err := persistent.Save(); err != nil {
+			logEngineWarning(engine.name, -1, err.Error())
+		}
+		engine.cacheLock.Unlock()
+	}
 	logEngineDebug(engine.name,-1,"all workers done, closing output channel")
 	close(engine.outputChan)
 }
 
-func (engine *DockerImageValidationEngine) worker(workerId int) {
+// batchInputs collects every input before handing them on, preparing the
+// registry client for all of their images at once
+func (engine *DockerImageValidationEngine) batchInputs() chan ImageExtractionResult {
+	out := make(chan ImageExtractionResult)
+	go func() {
+		defer close(out)
+		var inputs []ImageExtractionResult
+		var images []string
+	collect:
+		for {
+			select {
+			case input, ok := <-engine.inputChan:
+				if !ok {
+					break collect
+				}
+				inputs = append(inputs, input)
+				if input.Error == nil {
+					images = append(images, input.Image)
+				}
+			case <-engine.context.Done():
+				return
+			}
+		}
+
+		engine.registryClient.prepare(removeDuplicates(images))
+		logEngineDebug(engine.name, -1, fmt.Sprintf("validating a batch of %d images", len(inputs)))
+		for _, input := range inputs {
+			select {
+			case out <- input:
+			case <-engine.context.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (engine *DockerImageValidationEngine) worker(workerId int, inputs <-chan ImageExtractionResult) {
 	defer engine.workerWaitGroup.Done()
 
 	for {
 		select {
-		case input, ok := <-engine.inputChan:
+		case input, ok := <-inputs:
 			if !ok {
 				logEngineDebug(engine.name, workerId, "input closed")
 				return
 			}
+			engine.counters.received.Add(1)
 			image := input.Image
 
+			// Malformed references were already rejected during extraction
+			if input.Error != nil {
+				engine.send(DockerImageValidationResult{
+					Chart:         input.Chart,
+					Image:         image,
+					OriginalImage: input.OriginalImage,
+					Error:         input.Error,
+					Sources:       input.Sources,
+				})
+				continue
+			}
+
 			// If there is a result pending, then wait for it and return it
 			pending_result := engine.waitForPending(input.Chart, image, workerId)
 			if pending_result != nil {
-				engine.outputChan <- *pending_result
+				pending_result.OriginalImage = input.OriginalImage
+				pending_result.Sources = input.Sources
+				engine.send(*pending_result)
 				continue
 			}
 
-			// If already cached, return that one
+			// If already cached, return that one for this chart
 			engine.cacheLock.RLock()
-			if result, found := engine.cache[image]; found {
+			if result, found := engine.cache.Get(image); found {
 				engine.cacheLock.RUnlock()
-				engine.outputChan <- result
+				result.Chart = input.Chart
+				result.OriginalImage = input.OriginalImage
+				result.Sources = input.Sources
+				engine.send(result)
 				continue
 			}
 			engine.cacheLock.RUnlock()
@@ -87,11 +216,13 @@ func (engine *DockerImageValidationEngine) worker(workerId int) {
 			result := engine.validateSingleDockerImage(input.Chart, image, workerId)
 
 			engine.cacheLock.Lock()
-				engine.cache[image] = result
+				engine.cache.Set(image, result)
 				pendingWG.Done()
 				delete(engine.pending, image)
 			engine.cacheLock.Unlock()
-			engine.outputChan <- result
+			result.OriginalImage = input.OriginalImage
+			result.Sources = input.Sources
+			engine.send(result)
 
 		case <-engine.context.Done():
 			logEngineDebug(engine.name,workerId,"context done")
@@ -108,15 +239,11 @@ func (engine *DockerImageValidationEngine) waitForPending(chart ChartRenderParam
 		logEngineDebug(engine.name, workerId, fmt.Sprintf("waiting for pending: %s", image))
 		wg.Wait()
 		engine.cacheLock.RLock()
-		if result, found := engine.cache[image]; found {
+		if result, found := engine.cache.Get(image); found {
 			engine.cacheLock.RUnlock()
 			logEngineDebug(engine.name, workerId, fmt.Sprintf("submitting %s result we were waiting for", image))
-			return &DockerImageValidationResult{
-				Image:  image,
-				Exists: result.Exists,
-				Error:  result.Error,
-				Chart: 	chart,
-			}
+			result.Chart = chart
+			return &result
 		}
 		logEngineWarning(engine.name, workerId, fmt.Sprintf("even after waiting no result found for %s", image))
 		engine.cacheLock.RUnlock()
@@ -127,34 +254,165 @@ func (engine *DockerImageValidationEngine) waitForPending(chart ChartRenderParam
 }
 
 func (engine *DockerImageValidationEngine) validateSingleDockerImage(chart ChartRenderParams, image string, workerId int) DockerImageValidationResult {
+	// The slot is held for the lookup and the signature check, which both call the registry
+	if engine.registrySlots != nil {
+		select {
+		case engine.registrySlots <- struct{}{}:
+			defer func() { <-engine.registrySlots }()
+		case <-engine.context.Done():
+			return DockerImageValidationResult{
+				Chart:        chart,
+				Image:        image,
+				Unverifiable: true,
+				Error:        fmt.Errorf("registry lookup of %s cancelled: %w", image, engine.context.Err()),
+			}
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(engine.context, 2*time.Minute)
 	defer cancel()
 
-	args := []string{"manifest", "inspect", image}
-	cmd := engine.executor.CommandContext(ctx, "docker", args...)
+	tool := engine.registryTool
+	if tool == nil {
+		tool = dockerRegistryTool{}
+	}
+	var output []byte
+	var err error
+	var cmdStr string
+	if engine.registryClient != nil {
+		cmdStr = "registry lookup of " + image
+		logEngineDebug(engine.name, workerId, cmdStr)
+		output, err = engine.registryClient.lookup(ctx, image)
+	} else {
+		name, args := tool.command(image)
+		if raw, ok := tool.(rawManifestTool); ok && engine.platform != nil {
+			name, args = raw.rawCommand(image)
+		}
+		cmd := engine.executor.CommandContext(ctx, name, args...)
+		if engine.dockerConfig != "" {
+			cmd.SetEnv([]string{"DOCKER_CONFIG=" + engine.dockerConfig})
+		}
 
-	// Print the command being executed using interface methods
-	cmdStr := fmt.Sprintf("%s %s", filepath.Base(cmd.GetPath()), strings.Join(cmd.GetArgs()[1:], " "))
-	logEngineDebug(engine.name, workerId, fmt.Sprintf("executing: %s", cmdStr))
+		// Print the command being executed using interface methods
+		cmdStr = fmt.Sprintf("%s %s", filepath.Base(cmd.GetPath()), strings.Join(cmd.GetArgs()[1:], " "))
+		logEngineDebug(engine.name, workerId, fmt.Sprintf("executing: %s", cmdStr))
 
-	err := cmd.Run()
+		output, err = cmd.CombinedOutput()
+	}
 
 	exists := err == nil
+	unverifiable := false
+	accessDenied := false
 	if err != nil {
-		logEngineWarning(engine.name, workerId, fmt.Sprintf("failed: %s", cmdStr))
+		logEngineWarning(engine.name, workerId, fmt.Sprintf("failed: %s: %s", cmdStr, strings.TrimSpace(string(output))))
+		// Auth failures often say a repository was not found, so they are told apart first
+		if isAccessDenied(string(output)) {
+			unverifiable = true
+			accessDenied = true
+			err = fmt.Errorf("access denied to %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+		} else if isImageNotFound(string(output)) {
+			// The registry answered, the image or tag just isn't there
+			err = nil
+		} else {
+			unverifiable = true
+			err = fmt.Errorf("registry error for %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+		}
 	} else {
 		logEngineDebug(engine.name, workerId, fmt.Sprintf("completed: %s", cmdStr))
 	}
 
+	if exists && engine.signatures != nil {
+		err = engine.signatures.verify(ctx, engine.executor, image)
+		if err != nil {
+			logEngineWarning(engine.name, workerId, err.Error())
+		}
+	}
+
+	if exists && err == nil && engine.platform != nil {
+		err = checkImagePlatform(image, output, *engine.platform)
+		if err != nil {
+			logEngineWarning(engine.name, workerId, err.Error())
+		}
+	}
+
 	return DockerImageValidationResult{
 		Image:  image,
 		Exists: exists,
+		Unverifiable: unverifiable,
+		AccessDenied: accessDenied,
 		Error:  err,
 		Chart: 	chart,
 	}
 
 }
 
+// imageNotFoundPatterns are what registries answer through docker, skopeo or
+// crane when the image or tag doesn't exist. A bare "not found" also shows up
+// in DNS and credential errors, so only the registry's error codes are matched.
+var imageNotFoundPatterns = []string{"manifest unknown", "manifest_unknown", "no such manifest", "name unknown", "name_unknown"}
+
+// newRegistrySlots returns the semaphore of -max-registry-concurrency, nil
+// meaning no limit besides the number of workers
+func newRegistrySlots(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	return make(chan struct{}, limit)
+}
+
+// isImageNotFound tells a missing image apart from auth, network and other registry errors
+func isImageNotFound(output string) bool {
+	output = strings.ToLower(output)
+	for _, pattern := range imageNotFoundPatterns {
+		if strings.Contains(output, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// accessDeniedPatterns are what registries answer when credentials are missing or insufficient
+var accessDeniedPatterns = []string{"unauthorized", "denied", "forbidden", "authentication required", "credentials not found"}
+
+// isAccessDenied tells an auth failure apart from network and other registry errors
+func isAccessDenied(output string) bool {
+	output = strings.ToLower(output)
+	for _, pattern := range accessDeniedPatterns {
+		if strings.Contains(output, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImages checks a list of images outside the run-checks pipeline using
+// a standalone validation engine. Malformed references are not passed to docker.
+func validateImages(ctx context.Context, executor CommandExecutor, images []string) map[string]DockerImageValidationResult {
+	validator := DockerImageValidationEngine{
+		inputChan:       make(chan ImageExtractionResult),
+		outputChan:      make(chan DockerImageValidationResult),
+		context:         ctx,
+		executor:        executor,
+		name:            "DockerValidator",
+		pending:         map[string]*sync.WaitGroup{},
+		workerWaitGroup: sync.WaitGroup{},
+	}
+	validator.Start(10)
+
+	go func() {
+		for _, image := range images {
+			validator.inputChan <- ImageExtractionResult{Image: image, Error: checkImageReference(image)}
+		}
+		close(validator.inputChan)
+	}()
+
+	results := map[string]DockerImageValidationResult{}
+	for result := range validator.outputChan {
+		results[result.Image] = result
+	}
+	return results
+}
+
 // findJSONFiles recursively finds all JSON files in the given directory
 func findJSONFiles(dir string) ([]string, error) {
 	var jsonFiles []string
@@ -221,8 +479,3 @@ func deduplicateImages(images []string) []string {
 	return unique
 }
 
-
-// createDockerManifestInspectCommand creates the docker command for validating an image
-func createDockerManifestInspectCommand(image string) *exec.Cmd {
-	return exec.Command("docker", "manifest", "inspect", image)
-}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,7 +20,7 @@ func createDockerValidationEngine(mockExecutor *MockCommandExecutor) *DockerImag
 		outputChan: make(chan DockerImageValidationResult),
 		executor:   mockExecutor,
 		context:    createTestContext(),
-		cache:      make(map[string]DockerImageValidationResult),
+		cache:      newMemoryValidationCache(),
 		pending:    make(map[string]*sync.WaitGroup),
 		name:       "DockerImageValidationEngine",
 	}
@@ -130,10 +131,11 @@ func TestDockerImageValidationEngine(t *testing.T) {
 }
 
 func TestDockerImageValidationCache(t *testing.T) {
-	mockExecutor := createMockExecutorWithBehavior(func() error {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
 		time.Sleep(100 * time.Millisecond)
-		return nil
-	})
+		return nil, nil
+	}
 
 	engine := createDockerValidationEngine(mockExecutor)
 	engine.Start(2)
@@ -149,6 +151,57 @@ func TestDockerImageValidationCache(t *testing.T) {
 	engine.context.Done()
 }
 
+// recordingValidationCache is an in-test cache backend that records its use
+type recordingValidationCache struct {
+	mu      sync.Mutex
+	results map[string]DockerImageValidationResult
+	gets    []string
+	sets    []string
+}
+
+func (c *recordingValidationCache) Get(image string) (DockerImageValidationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gets = append(c.gets, image)
+	result, found := c.results[image]
+	return result, found
+}
+
+func (c *recordingValidationCache) Set(image string, result DockerImageValidationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sets = append(c.sets, image)
+	c.results[image] = result
+}
+
+func TestDockerImageValidationCustomCache(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	cache := &recordingValidationCache{
+		results: map[string]DockerImageValidationResult{
+			"nginx:1.20": {Image: "nginx:1.20", Exists: true},
+		},
+	}
+
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.cache = cache
+	engine.Start(1)
+
+	// A cached image is served from the backend without running docker
+	sendImagesToEngine(engine, []string{"nginx:1.20"})
+	result := <-engine.outputChan
+	assert.True(t, result.Exists)
+	assert.Equal(t, "", mockExecutor.LastCommand, "Expected no command for a cached image")
+
+	// An uncached image is validated and written back to the backend
+	sendImagesToEngine(engine, []string{"redis:6.2"})
+	result = <-engine.outputChan
+	assert.True(t, result.Exists)
+	assertCommandExecution(t, mockExecutor, "docker manifest inspect redis:6.2")
+
+	assert.Equal(t, []string{"nginx:1.20", "redis:6.2"}, cache.gets)
+	assert.Equal(t, []string{"redis:6.2"}, cache.sets)
+	assert.Contains(t, cache.results, "redis:6.2")
+}
 
 // TestFindJSONFiles tests finding JSON files in a directory
 func TestFindJSONFiles(t *testing.T) {
@@ -420,9 +473,9 @@ func TestValidateSingleDockerImage(t *testing.T) {
 }
 
 func TestDockerValidationError(t *testing.T) {
-	mockExecutor := createMockExecutorWithBehavior(func() error {
-		return fmt.Errorf("mocked docker error")
-	})
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("mocked docker error")
+	mockExecutor.Error = fmt.Errorf("exit status 1")
 
 	engine := createDockerValidationEngine(mockExecutor)
 	engine.Start(1)
@@ -437,6 +490,188 @@ func TestDockerValidationError(t *testing.T) {
 	result := <-engine.outputChan
 	assert.Equal(t, result.Image, img)
 	assert.NotNil(t, result.Error)
+	assert.True(t, result.Unverifiable)
 	assertCommandExecution(t, mockExecutor, "docker manifest inspect nonexistent:image")
 	engine.context.Done()
-}
\ No newline at end of file
+}
+
+func TestValidateSingleDockerImageClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name                 string
+		output               string
+		err                  error
+		expectedExists       bool
+		expectedUnverifiable bool
+		expectedAccessDenied bool
+		expectedError        string
+	}{
+		{
+			name:           "image exists",
+			output:         `{"schemaVersion": 2}`,
+			expectedExists: true,
+		},
+		{
+			name:   "manifest unknown",
+			output: "manifest unknown: manifest unknown",
+			err:    fmt.Errorf("exit status 1"),
+		},
+		{
+			name:   "no such manifest",
+			output: "no such manifest: docker.io/library/nginx:0.0.0",
+			err:    fmt.Errorf("exit status 1"),
+		},
+		{
+			name:   "tag not found",
+			output: "manifest for ghcr.io/org/app:v9 not found: manifest unknown",
+			err:    fmt.Errorf("exit status 1"),
+		},
+		{
+			name:                 "authentication failure",
+			output:               "unauthorized: authentication required",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedAccessDenied: true,
+			expectedError:        "access denied to nginx:1.25: exit status 1: unauthorized: authentication required",
+		},
+		{
+			name:                 "access denied",
+			output:               "denied: requested access to the resource is denied",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedAccessDenied: true,
+			expectedError:        "access denied to nginx:1.25: exit status 1: denied: requested access to the resource is denied",
+		},
+		{
+			name:   "repository unknown",
+			output: "NAME_UNKNOWN: repository name not known to registry",
+			err:    fmt.Errorf("exit status 1"),
+		},
+		{
+			name:                 "repository not found for credentials",
+			output:               "unauthorized: repository not found or access not granted",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedAccessDenied: true,
+			expectedError:        "access denied to nginx:1.25: exit status 1: unauthorized: repository not found or access not granted",
+		},
+		{
+			name:                 "credential helper without credentials",
+			output:               "error getting credentials - err: exit status 1, out: `credentials not found in native keychain`",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedAccessDenied: true,
+			expectedError:        "access denied to nginx:1.25: exit status 1: error getting credentials - err: exit status 1, out: `credentials not found in native keychain`",
+		},
+		{
+			name:                 "host not found",
+			output:               "pinging container registry registry.example.com: Get \"https://registry.example.com/v2/\": dial tcp: lookup registry.example.com: host not found",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedError:        "registry error for nginx:1.25: exit status 1: pinging container registry registry.example.com: Get \"https://registry.example.com/v2/\": dial tcp: lookup registry.example.com: host not found",
+		},
+		{
+			name:                 "dns failure",
+			output:               "Get \"https://registry.example.com/v2/\": dial tcp: lookup registry.example.com: no such host",
+			err:                  fmt.Errorf("exit status 1"),
+			expectedUnverifiable: true,
+			expectedError:        "registry error for nginx:1.25: exit status 1: Get \"https://registry.example.com/v2/\": dial tcp: lookup registry.example.com: no such host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecutor := createMockExecutor()
+			mockExecutor.Output = []byte(tt.output)
+			mockExecutor.Error = tt.err
+			engine := createDockerValidationEngine(mockExecutor)
+
+			result := engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+
+			assert.Equal(t, tt.expectedExists, result.Exists)
+			assert.Equal(t, tt.expectedUnverifiable, result.Unverifiable)
+			assert.Equal(t, tt.expectedAccessDenied, result.AccessDenied)
+			if tt.expectedError == "" {
+				assert.NoError(t, result.Error)
+			} else {
+				assert.EqualError(t, result.Error, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestValidateSingleDockerImageSetsDockerConfig(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := createDockerValidationEngine(mockExecutor)
+
+	// Without -docker-config the ambient credentials are used
+	engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+	assert.Empty(t, mockExecutor.Envs)
+
+	engine.dockerConfig = "/ci/registry-auth"
+	engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+	assert.Equal(t, [][]string{{"DOCKER_CONFIG=/ci/registry-auth"}}, mockExecutor.Envs)
+}
+
+func TestRealCommandSetEnvKeepsInheritedEnvironment(t *testing.T) {
+	t.Setenv("CHART_CHECKER_TEST", "inherited")
+	cmd := (&RealCommandExecutor{}).CommandContext(createTestContext(), "docker", "manifest", "inspect", "nginx:1.25").(*RealCommand)
+
+	cmd.SetEnv([]string{"DOCKER_CONFIG=/ci/registry-auth"})
+
+	assert.Contains(t, cmd.cmd.Env, "CHART_CHECKER_TEST=inherited")
+	assert.Equal(t, "DOCKER_CONFIG=/ci/registry-auth", cmd.cmd.Env[len(cmd.cmd.Env)-1])
+}
+
+func TestDockerValidationCapsRegistryConcurrency(t *testing.T) {
+	var running, maxRunning, calls atomic.Int32
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		calls.Add(1)
+		current := running.Add(1)
+		for {
+			observed := maxRunning.Load()
+			if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+		return []byte(`{"schemaVersion": 2}`), nil
+	}
+
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.registrySlots = newRegistrySlots(2)
+	engine.Start(10)
+
+	var images []string
+	for i := 0; i < 20; i++ {
+		images = append(images, fmt.Sprintf("app-%d:1.0", i))
+	}
+	// Every image is sent twice, the second time once the first result is in
+	go func() {
+		for _, image := range images {
+			engine.inputChan <- ImageExtractionResult{Chart: createTestChart(), Image: image}
+		}
+	}()
+	for range images {
+		result := <-engine.outputChan
+		assert.True(t, result.Exists)
+	}
+	go func() {
+		for _, image := range images {
+			engine.inputChan <- ImageExtractionResult{Chart: createTestChart(), Image: image}
+		}
+		close(engine.inputChan)
+	}()
+	for result := range engine.outputChan {
+		assert.True(t, result.Exists)
+	}
+
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2), "Expected at most 2 registry lookups at once")
+	assert.Equal(t, int32(20), calls.Load(), "Expected duplicate images to be served from the cache")
+}
+
+func TestNewRegistrySlots(t *testing.T) {
+	assert.Nil(t, newRegistrySlots(0))
+	assert.Equal(t, 3, cap(newRegistrySlots(3)))
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 
@@ -22,6 +26,25 @@ type ImageExtractionEngine struct {
 	context context.Context
 	workerWaitGroup sync.WaitGroup
 	name string
+
+	// counters are exposed on the metrics endpoint
+	counters engineCounters
+
+	// rewriteRules are applied to each extracted image before it is handed on
+	rewriteRules []imageRewriteRule
+
+	// registryMirrors map registry hosts to the mirror images are validated against, after rewriteRules
+	registryMirrors map[string]string
+
+	// index, when set, records the images extracted from each manifest file
+	index *manifestIndex
+
+	// registryPolicy, when set, rejects images from registries not allowed in the chart's env
+	registryPolicy *registryPolicy
+
+	// selfVerify deep scans every manifest and warns about images only one of
+	// the deep scan and the extraction by kind found
+	selfVerify bool
 }
 
 func (engine *ImageExtractionEngine) Start(workerCount int) {
@@ -50,23 +73,63 @@ func (engine *ImageExtractionEngine) worker(workerId int) {
 				logEngineDebug(engine.name, workerId, "input closed")
 				return
 			}
-			images, err := engine.extractImagesFromFile(input.ManifestFile, workerId)
-			if err != nil {
+			engine.counters.received.Add(1)
+			sources, err := engine.extractImagesFromFile(input.ManifestFile, workerId)
+			images := imageNames(sources)
+			engine.counters.finish(err)
+			if errors.Is(err, errInvalidRenderedYAML) {
+				logEngineWarning(engine.name, workerId, fmt.Sprintf("%s: %v", input.ManifestFile, err))
+				engine.errorChan <- ErrorResult{
+					Chart: input.Chart,
+					Stage: stageExtraction,
+					Error: fmt.Errorf("%s: %w", input.ManifestFile, err),
+				}
+			} else if err != nil {
 				logEngineWarning(engine.name, workerId, fmt.Sprintf("failed to extract images from %s: %v", input.ManifestFile, err))
 				engine.errorChan <- ErrorResult{
 					Chart: input.Chart,
+					Stage: stageExtraction,
 					Error:  fmt.Errorf("failed to extract images from %s: %w", input.ManifestFile, err),
 				}
-				continue
-			} else {
+			}
+			if engine.selfVerify {
+				engine.verifyExtraction(input.ManifestFile, images, workerId)
+			}
+			// Images from the documents that did parse are still validated
+			if len(images) > 0 || err == nil {
 				uniqueImages := removeDuplicates(images)
+				engine.index.record(input.ManifestFile, input.Chart, uniqueImages)
 				// Send each extracted image as a separate result for the next step
 				logEngineDebug(engine.name, workerId, fmt.Sprintf("extracted %d images from %s", len(uniqueImages), input.ManifestFile))
 				for _, img := range uniqueImages {
-					engine.outputChan <- ImageExtractionResult{
+					result := ImageExtractionResult{
 						Chart: input.Chart,
 						ManifestFile: input.ManifestFile,
 						Image:       img,
+						Sources:     sourcesOf(sources, img),
+					}
+					if err := checkImageReference(img); err != nil {
+						logEngineWarning(engine.name, workerId, fmt.Sprintf("malformed image in %s: %v", input.ManifestFile, err))
+						result.Error = err
+						if !sendOrDone(engine.context, engine.outputChan, result) {
+							return
+						}
+						continue
+					}
+					// The policy applies to the registry the cluster pulls from,
+					// a mirror only stands in for it during the checks
+					rewritten := rewriteImage(engine.rewriteRules, img)
+					if err := engine.registryPolicy.check(input.Chart.Env, rewritten); err != nil {
+						logEngineWarning(engine.name, workerId, err.Error())
+						result.Error = err
+					}
+					if rewritten = mirrorImage(engine.registryMirrors, rewritten); rewritten != img {
+						logEngineDebug(engine.name, workerId, fmt.Sprintf("rewrote %s to %s", img, rewritten))
+						result.Image = rewritten
+						result.OriginalImage = img
+					}
+					if !sendOrDone(engine.context, engine.outputChan, result) {
+						return
 					}
 				}
 			}
@@ -77,37 +140,141 @@ func (engine *ImageExtractionEngine) worker(workerId int) {
 	}
 }
 
-func (engine *ImageExtractionEngine) extractImagesFromFile(file string, workerId int) ([]string, error) {
+// verifyExtraction warns about every image of a manifest that only one of the
+// extraction by kind and a deep scan found, pointing at gaps in the extractor
+func (engine *ImageExtractionEngine) verifyExtraction(file string, extracted []string, workerId int) {
+	deepScanned, err := deepScanImagesFromFile(file)
+	if err != nil {
+		logEngineWarning(engine.name, workerId, fmt.Sprintf("self-verify of %s failed: %v", file, err))
+		return
+	}
+	for _, discrepancy := range imageDiscrepancies(extracted, deepScanned) {
+		logEngineWarning(engine.name, workerId, fmt.Sprintf("self-verify: %s in %s", discrepancy, file))
+	}
+}
+
+func (engine *ImageExtractionEngine) extractImagesFromFile(file string, workerId int) ([]ImageSource, error) {
 	// Read the manifest file
 	content, err := os.ReadFile(file)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
+	// A broken post-renderer can emit output that isn't YAML at all, which
+	// would otherwise only show up as per-document errors and no images
+	yamlErr := checkRenderedYAML(content)
+
 	// Split content into multiple YAML documents (in case of multi-document files)
-	documents := strings.Split(string(content), "\n---\n")
-	var allImages []string
+	documents := splitYAMLDocuments(string(content))
+	var allImages []ImageSource
+	var docErrors []error
 
 	for _, doc := range documents {
-		doc = strings.TrimSpace(doc)
-		if doc == "" {
-			continue
-		}
-
 		// Extract images from this document
-		images, err := extractImageFromManifest(doc, workerId)
+		images, err := extractImageSourcesFromManifest(doc, workerId)
 		if err != nil {
-			// Don't fail the entire file for one bad document, just log and continue
+			// Don't stop at one bad document, the images of the others are still returned
 			logEngineWarning(engine.name, workerId, fmt.Sprintf("failed to extract images from document in %s: %v", file, err))
+			docErrors = append(docErrors, err)
 			continue
 		}
 
 		allImages = append(allImages, images...)
 	}
 
-	return allImages, nil
+	if yamlErr != nil {
+		return allImages, yamlErr
+	}
+	return allImages, errors.Join(docErrors...)
 }
 
+// errInvalidRenderedYAML marks rendered output that doesn't parse as a YAML stream
+var errInvalidRenderedYAML = errors.New("rendered output is not valid YAML")
+
+// checkRenderedYAML parses the whole rendered output as a YAML stream
+func checkRenderedYAML(content []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", errInvalidRenderedYAML, err)
+		}
+	}
+}
+
+// documentSeparator matches a YAML document start or end marker on its own line,
+// optionally followed by whitespace or a comment
+var documentSeparator = regexp.MustCompile(`^(---|\.\.\.)\s*(#.*)?$`)
+
+// splitYAMLDocuments splits a multi-document YAML stream on its separator lines,
+// accepting a leading separator, trailing whitespace and CRLF line endings.
+// Documents are split rather than streamed through a yaml.Decoder so that one
+// malformed document can't hide the ones after it. Documents holding nothing
+// but comments, such as helm's "# Source:" headers for empty templates, are dropped.
+func splitYAMLDocuments(content string) []string {
+	var documents []string
+	var current []string
+	hasContent := false
+
+	flush := func() {
+		if hasContent {
+			documents = append(documents, strings.Join(current, "\n"))
+		}
+		current, hasContent = nil, false
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if documentSeparator.MatchString(line) {
+			flush()
+			continue
+		}
+		current = append(current, line)
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			hasContent = true
+		}
+	}
+	flush()
+
+	return documents
+}
+
+// maxImageReferenceLength is longer than any real image reference; anything
+// beyond it is almost certainly a templating bug such as an embedded YAML blob
+const maxImageReferenceLength = 512
+
+// checkImageReference rejects rendered image references that cannot be valid:
+// an empty tag or digest, e.g. "nginx:" when a chart leaves image.tag empty,
+// Go template left unrendered, e.g. by a library chart helper that was quoted
+// instead of included, whitespace or newlines, or an absurd length
+func checkImageReference(image string) error {
+	if len(image) > maxImageReferenceLength {
+		return fmt.Errorf("malformed image reference %q: longer than %d characters", truncateImage(image), maxImageReferenceLength)
+	}
+	if strings.Contains(image, "{{") || strings.Contains(image, "}}") {
+		return fmt.Errorf("render error in image reference %q: unrendered Go template", truncateImage(image))
+	}
+	if strings.ContainsAny(image, " \t\r\n") {
+		return fmt.Errorf("malformed image reference %q: contains whitespace", truncateImage(image))
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if strings.HasSuffix(name, ":") || strings.HasSuffix(image, "@") || strings.Contains(name, ":@") {
+		return fmt.Errorf("malformed image reference %q: empty tag or digest", image)
+	}
+	return nil
+}
+
+// truncateImage shortens an image reference for error messages
+func truncateImage(image string) string {
+	const maxShown = 64
+	if len(image) <= maxShown {
+		return image
+	}
+	return image[:maxShown] + "..."
+}
 
 // extractDockerImages extracts Docker images from all manifest files in the specified directory
 // and saves the results as JSON files in the output directory
@@ -155,15 +322,10 @@ func extractImagesFromFile(yamlFile, manifestDir, outputDir string, workerId int
 	}
 
 	// Split content into multiple YAML documents (in case of multi-document files)
-	documents := strings.Split(string(content), "\n---\n")
+	documents := splitYAMLDocuments(string(content))
 	var allImages []string
 
 	for _, doc := range documents {
-		doc = strings.TrimSpace(doc)
-		if doc == "" {
-			continue
-		}
-
 		// Extract images from this document
 		images, err := extractImageFromManifest(doc, workerId)
 		if err != nil {
@@ -205,7 +367,7 @@ func extractImagesFromFile(yamlFile, manifestDir, outputDir string, workerId int
 }
 
 
-func extractImagesFromDeployment(manifest map[string]interface{}) ([]string, error) {
+func extractImagesFromDeployment(manifest map[string]interface{}) ([]ImageSource, error) {
 	// Validate this is a Deployment
 	kind, ok := manifest["kind"].(string)
 	if !ok || kind != "Deployment" {
@@ -229,7 +391,7 @@ func extractImagesFromDeployment(manifest map[string]interface{}) ([]string, err
 	return extractImagesFromPod(template)
 }
 
-func extractImagesFromDaemonSet(manifest map[string]interface{}) ([]string, error) {
+func extractImagesFromDaemonSet(manifest map[string]interface{}) ([]ImageSource, error) {
 	// Validate this is a DaemonSet
 	kind, ok := manifest["kind"].(string)
 	if !ok || kind != "DaemonSet" {
@@ -253,7 +415,7 @@ func extractImagesFromDaemonSet(manifest map[string]interface{}) ([]string, erro
 	return extractImagesFromPod(template)
 }
 
-func extractImagesFromStatefulSet(manifest map[string]interface{}) ([]string, error) {
+func extractImagesFromStatefulSet(manifest map[string]interface{}) ([]ImageSource, error) {
 	// Validate this is a StatefulSet
 	kind, ok := manifest["kind"].(string)
 	if !ok || kind != "StatefulSet" {
@@ -277,31 +439,119 @@ func extractImagesFromStatefulSet(manifest map[string]interface{}) ([]string, er
 	return extractImagesFromPod(template)
 }
 
-func extractImagesFromPod(manifest map[string]interface{}) ([]string, error) {
-	images := []string{}
+// extractImagesFromList extracts the images of every object wrapped in a
+// kind: List document. Nested Lists are skipped rather than recursed into.
+func extractImagesFromList(manifest map[string]interface{}, workerId int) ([]ImageSource, error) {
+	images := []ImageSource{}
+
+	items, _ := manifest["items"].([]interface{})
+	for i, item := range items {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if itemMap["kind"] == "List" {
+			logEngineWarning("ImageExtractor", workerId, fmt.Sprintf("skipping nested List at items[%d]", i))
+			continue
+		}
+
+		itemYAML, err := yaml.Marshal(itemMap)
+		if err != nil {
+			return images, fmt.Errorf("failed to marshal List item %d: %w", i, err)
+		}
+		itemImages, err := extractImageSourcesFromManifest(string(itemYAML), workerId)
+		if err != nil {
+			return images, fmt.Errorf("List item %d: %w", i, err)
+		}
+		images = append(images, itemImages...)
+	}
+
+	return images, nil
+}
+
+func extractImagesFromJob(manifest map[string]interface{}) ([]ImageSource, error) {
+	// Validate this is a Job
+	kind, ok := manifest["kind"].(string)
+	if !ok || kind != "Job" {
+		return nil, fmt.Errorf("not a Job manifest")
+	}
+
+	// Locate spec.template, a Job without one simply has no images
+	spec, _ := manifest["spec"].(map[string]interface{})
+	template, ok := spec["template"].(map[string]interface{})
+	if !ok {
+		return []ImageSource{}, nil
+	}
+
+	return extractImagesFromPod(template)
+}
+
+func extractImagesFromCronJob(manifest map[string]interface{}) ([]ImageSource, error) {
+	// Validate this is a CronJob
+	kind, ok := manifest["kind"].(string)
+	if !ok || kind != "CronJob" {
+		return nil, fmt.Errorf("not a CronJob manifest")
+	}
+
+	// Descend through spec.jobTemplate.spec.template, missing levels mean no images
+	spec, _ := manifest["spec"].(map[string]interface{})
+	jobTemplate, _ := spec["jobTemplate"].(map[string]interface{})
+	jobSpec, _ := jobTemplate["spec"].(map[string]interface{})
+	template, ok := jobSpec["template"].(map[string]interface{})
+	if !ok {
+		return []ImageSource{}, nil
+	}
+
+	return extractImagesFromPod(template)
+}
+
+// extractImagesFromPodTemplate extracts images from spec.template of a kind
+// that isn't known to hold one. It reports false unless the template's spec
+// has containers or initContainers, so unrelated CRDs that happen to have a
+// spec.template are left alone.
+func extractImagesFromPodTemplate(manifest map[string]interface{}) ([]ImageSource, bool) {
+	spec, _ := manifest["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	podSpec, _ := template["spec"].(map[string]interface{})
+	_, hasContainers := podSpec["containers"].([]interface{})
+	_, hasInitContainers := podSpec["initContainers"].([]interface{})
+	if !hasContainers && !hasInitContainers {
+		return nil, false
+	}
+
+	images, _ := extractImagesFromPod(template)
+	return images, true
+}
+
+func extractImagesFromPod(manifest map[string]interface{}) ([]ImageSource, error) {
+	images := []ImageSource{}
 
 	spec, ok := manifest["spec"].(map[string]interface{})
 	if !ok {
 		return images, nil // No spec found
 	}
 
-	// Check containers
-	if containers, ok := spec["containers"].([]interface{}); ok {
-		for _, c := range containers {
-			if cMap, ok := c.(map[string]interface{}); ok {
-				if img, ok := cMap["image"].(string); ok {
-					images = append(images, img)
+	// Check containers and initContainers
+	for _, field := range []string{"containers", "initContainers"} {
+		if containers, ok := spec[field].([]interface{}); ok {
+			for _, c := range containers {
+				if cMap, ok := c.(map[string]interface{}); ok {
+					if img, ok := cMap["image"].(string); ok {
+						images = append(images, ImageSource{Image: img, Container: str(cMap["name"]), Field: strings.TrimSuffix(field, "s")})
+					}
 				}
 			}
 		}
 	}
 
-	// Check initContainers
-	if initContainers, ok := spec["initContainers"].([]interface{}); ok {
-		for _, c := range initContainers {
-			if cMap, ok := c.(map[string]interface{}); ok {
-				if img, ok := cMap["image"].(string); ok {
-					images = append(images, img)
+	// Check image volume sources (spec.volumes[].image.reference)
+	if volumes, ok := spec["volumes"].([]interface{}); ok {
+		for _, v := range volumes {
+			if vMap, ok := v.(map[string]interface{}); ok {
+				if imageSource, ok := vMap["image"].(map[string]interface{}); ok {
+					if ref, ok := imageSource["reference"].(string); ok {
+						images = append(images, ImageSource{Image: ref, Container: str(vMap["name"]), Field: "volume"})
+					}
 				}
 			}
 		}
@@ -310,12 +560,80 @@ func extractImagesFromPod(manifest map[string]interface{}) ([]string, error) {
 	return images, nil
 }
 
+// extractImagesFromTektonTaskSpec collects the images of the steps and sidecars of a Tekton task spec
+func extractImagesFromTektonTaskSpec(taskSpec map[string]interface{}) []ImageSource {
+	images := []ImageSource{}
+	for _, field := range []string{"steps", "sidecars"} {
+		if steps, ok := taskSpec[field].([]interface{}); ok {
+			for _, s := range steps {
+				if sMap, ok := s.(map[string]interface{}); ok {
+					if img, ok := sMap["image"].(string); ok {
+						images = append(images, ImageSource{Image: img, Container: str(sMap["name"]), Field: strings.TrimSuffix(field, "s")})
+					}
+				}
+			}
+		}
+	}
+	return images
+}
+
+// extractImagesFromTektonPipelineSpec collects the images of every embedded taskSpec in a Tekton pipeline spec
+func extractImagesFromTektonPipelineSpec(pipelineSpec map[string]interface{}) []ImageSource {
+	images := []ImageSource{}
+	for _, field := range []string{"tasks", "finally"} {
+		if tasks, ok := pipelineSpec[field].([]interface{}); ok {
+			for _, t := range tasks {
+				if tMap, ok := t.(map[string]interface{}); ok {
+					if taskSpec, ok := tMap["taskSpec"].(map[string]interface{}); ok {
+						images = append(images, extractImagesFromTektonTaskSpec(taskSpec)...)
+					}
+				}
+			}
+		}
+	}
+	return images
+}
+
+func extractImagesFromTekton(manifest map[string]interface{}) ([]ImageSource, error) {
+	kind, _ := manifest["kind"].(string)
+	spec, ok := manifest["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing spec in %s", kind)
+	}
+
+	switch kind {
+	case "Task", "ClusterTask":
+		return extractImagesFromTektonTaskSpec(spec), nil
+	case "TaskRun":
+		// TaskRuns referencing a Task by name have no embedded steps
+		if taskSpec, ok := spec["taskSpec"].(map[string]interface{}); ok {
+			return extractImagesFromTektonTaskSpec(taskSpec), nil
+		}
+		return []ImageSource{}, nil
+	case "Pipeline":
+		return extractImagesFromTektonPipelineSpec(spec), nil
+	case "PipelineRun":
+		if pipelineSpec, ok := spec["pipelineSpec"].(map[string]interface{}); ok {
+			return extractImagesFromTektonPipelineSpec(pipelineSpec), nil
+		}
+		return []ImageSource{}, nil
+	default:
+		return nil, fmt.Errorf("not a Tekton manifest")
+	}
+}
 
 // Extracts all of the docker images references from a given Kubernetes manifest.
 // This function makes the assumption that only a single manifest is provided at
 // a time, and that it is a Pod or Pod-like object (e.g. Deployment, DaemonSet).
 func extractImageFromManifest(manifest string, workerId int) ([]string, error) {
-	imagesFound := []string{}
+	sources, err := extractImageSourcesFromManifest(manifest, workerId)
+	return imageNames(sources), err
+}
+
+// extractImageSourcesFromManifest extracts the images of a single manifest
+// together with the resource and container referencing each of them
+func extractImageSourcesFromManifest(manifest string, workerId int) ([]ImageSource, error) {
+	imagesFound := []ImageSource{}
 
 	// Parse the YAML manifest into a generic map.
 	var doc map[string]interface{}
@@ -328,42 +646,60 @@ func extractImageFromManifest(manifest string, workerId int) ([]string, error) {
 		return imagesFound, fmt.Errorf("manifest missing 'kind' field")
 	}
 
-	logEngineDebug("ImageExtractor", workerId, fmt.Sprintf("Inspecting %s %s", kind, fmt.Sprint(doc["metadata"].(map[string]interface{})["name"])))
+	// Lists (e.g. kubectl get -o yaml output) have no metadata of their own
+	metadata, _ := doc["metadata"].(map[string]interface{})
+	logEngineDebug("ImageExtractor", workerId, fmt.Sprintf("Inspecting %s %s", kind, fmt.Sprint(metadata["name"])))
+
+	images, err := extractImagesOfKind(doc, kind, workerId)
+	// List items already name their own resource
+	for i := range images {
+		if images[i].Kind == "" {
+			images[i].Kind = kind
+			images[i].Resource = str(metadata["name"])
+		}
+	}
+	return append(imagesFound, images...), err
+}
+
+// extractImagesOfKind extracts the images of a parsed manifest by its kind
+func extractImagesOfKind(doc map[string]interface{}, kind string, workerId int) ([]ImageSource, error) {
+	metadata, _ := doc["metadata"].(map[string]interface{})
 
+	if paths, ok := customImagePaths[kind]; ok {
+		return extractImagesFromCustomKind(doc, paths)
+	}
+
+	var images []ImageSource
+	var err error
 	switch kind {
 	case "Pod":
-
-		images, err := extractImagesFromPod(doc)
-		if err != nil {
-			return imagesFound, err
-		}
-		imagesFound = append(imagesFound, images...)
+		images, err = extractImagesFromPod(doc)
 	case "Deployment":
-		images, err := extractImagesFromDeployment(doc)
-		if err != nil {
-			return imagesFound, err
-		}
-		imagesFound = append(imagesFound, images...)
+		images, err = extractImagesFromDeployment(doc)
 	case "DaemonSet":
-		images, err := extractImagesFromDaemonSet(doc)
-		if err != nil {
-			return imagesFound, err
-		}
-		imagesFound = append(imagesFound, images...)	
-
+		images, err = extractImagesFromDaemonSet(doc)
 	case "StatefulSet":
-		images, err := extractImagesFromStatefulSet(doc)
-		if err != nil {
-			return imagesFound, err
-		}
-		imagesFound = append(imagesFound, images...)
-
+		images, err = extractImagesFromStatefulSet(doc)
+	case "List":
+		images, err = extractImagesFromList(doc, workerId)
+	case "Job":
+		images, err = extractImagesFromJob(doc)
+	case "CronJob":
+		images, err = extractImagesFromCronJob(doc)
+	case "Task", "ClusterTask", "TaskRun", "Pipeline", "PipelineRun":
+		images, err = extractImagesFromTekton(doc)
 	default:
+		// Workload CRDs such as Argo Rollouts or CloneSets embed a standard pod template
+		if images, ok := extractImagesFromPodTemplate(doc); ok {
+			logEngineDebug("ImageExtractor", workerId, fmt.Sprintf("Found pod template in %s %s", kind, fmt.Sprint(metadata["name"])))
+			return images, nil
+		}
 		// For other kinds, we currently do not extract images.
-		logEngineDebug("ImageExtractor", workerId, fmt.Sprintf("Skipping image extraction for %s %s", kind, fmt.Sprint(doc["metadata"].(map[string]interface{})["name"])))
-		return imagesFound, nil
+		logEngineDebug("ImageExtractor", workerId, fmt.Sprintf("Skipping image extraction for %s %s", kind, fmt.Sprint(metadata["name"])))
+		return nil, nil
 	}
-
-	return imagesFound, nil
-	
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
 }
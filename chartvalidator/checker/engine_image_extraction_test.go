@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -87,6 +88,207 @@ spec:
         image: nginx:1.14.2
       - name: another-container
         image: redis:6.0
+`,
+	"pod_image_volume_sample": `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: image-volume-pod
+spec:
+  containers:
+  - name: sample-container
+    image: nginx:1.14.2
+    volumeMounts:
+    - name: artifacts
+      mountPath: /artifacts
+  volumes:
+  - name: artifacts
+    image:
+      reference: quay.io/crio/artifact:v1
+      pullPolicy: IfNotPresent
+  - name: scratch
+    emptyDir: {}
+`,
+	"tekton_task_sample": `
+apiVersion: tekton.dev/v1
+kind: Task
+metadata:
+  name: build
+spec:
+  steps:
+  - name: clone
+    image: alpine/git:2.43.0
+  - name: build
+    image: golang:1.22
+  - name: publish
+    image: gcr.io/kaniko-project/executor:v1.21.0
+  sidecars:
+  - name: registry
+    image: registry:2
+`,
+	"tekton_pipelinerun_sample": `
+apiVersion: tekton.dev/v1
+kind: PipelineRun
+metadata:
+  name: release
+spec:
+  pipelineSpec:
+    tasks:
+    - name: test
+      taskSpec:
+        steps:
+        - name: unit
+          image: golang:1.22
+    - name: referenced
+      taskRef:
+        name: build
+    finally:
+    - name: notify
+      taskSpec:
+        steps:
+        - name: slack
+          image: curlimages/curl:8.5.0
+`,
+	"job_sample": `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: wait-for-db
+        image: busybox:1.28
+      containers:
+      - name: migrate
+        image: flyway/flyway:10
+      restartPolicy: Never
+`,
+	"cronjob_sample": `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  schedule: "0 2 * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+          - name: prepare
+            image: busybox:1.28
+          containers:
+          - name: backup
+            image: postgres:16
+          restartPolicy: OnFailure
+`,
+	"list_sample": `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: web
+  spec:
+    template:
+      spec:
+        initContainers:
+        - name: init
+          image: busybox:1.28
+        containers:
+        - name: web
+          image: nginx:1.14.2
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: cache
+  spec:
+    containers:
+    - name: cache
+      image: redis:6.0
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: web
+`,
+	"rollout_sample": `
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: web
+spec:
+  strategy:
+    canary:
+      steps:
+      - setWeight: 20
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.28
+      containers:
+      - name: web
+        image: nginx:1.14.2
+`,
+	"os_variants_sample": `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent-linux
+spec:
+  template:
+    spec:
+      os:
+        name: linux
+      nodeSelector:
+        kubernetes.io/os: linux
+      containers:
+      - name: agent
+        image: example.com/agent:2.1.0-linux
+      - name: log-shipper
+        image: fluent/fluent-bit:3.0
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent-windows
+spec:
+  template:
+    spec:
+      os:
+        name: windows
+      nodeSelector:
+        kubernetes.io/os: windows
+      containers:
+      - name: agent
+        image: example.com/agent:2.1.0-windows
+      - name: log-shipper
+        image: fluent/fluent-bit:3.0-windows-ltsc2022
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: probe
+spec:
+  containers:
+  - name: probe-linux
+    image: example.com/probe:1.0
+  - name: probe-windows
+    image: example.com/probe:1.0
+`,
+	"crd_without_pod_template_sample": `
+apiVersion: example.com/v1
+kind: ReportTemplate
+metadata:
+  name: weekly
+spec:
+  template:
+    title: Weekly report
+    sections:
+    - name: summary
 `,
 }
 
@@ -106,6 +308,53 @@ func getExpectedImages(manifestType string) map[string]bool {
 			"nginx:1.14.2": true,
 			"redis:6.0":    true,
 		}
+	case "tekton_task_sample":
+		return map[string]bool{
+			"alpine/git:2.43.0":                      true,
+			"golang:1.22":                            true,
+			"gcr.io/kaniko-project/executor:v1.21.0": true,
+			"registry:2":                             true,
+		}
+	case "tekton_pipelinerun_sample":
+		return map[string]bool{
+			"golang:1.22":           true,
+			"curlimages/curl:8.5.0": true,
+		}
+	case "pod_image_volume_sample":
+		return map[string]bool{
+			"nginx:1.14.2":             true,
+			"quay.io/crio/artifact:v1": true,
+		}
+	case "list_sample":
+		return map[string]bool{
+			"nginx:1.14.2": true,
+			"redis:6.0":    true,
+			"busybox:1.28": true,
+		}
+	case "job_sample":
+		return map[string]bool{
+			"busybox:1.28":     true,
+			"flyway/flyway:10": true,
+		}
+	case "cronjob_sample":
+		return map[string]bool{
+			"busybox:1.28": true,
+			"postgres:16":  true,
+		}
+	case "os_variants_sample":
+		// Both OS variants are extracted, the image shared by two containers only once
+		return map[string]bool{
+			"example.com/agent:2.1.0-linux":          true,
+			"example.com/agent:2.1.0-windows":        true,
+			"fluent/fluent-bit:3.0":                  true,
+			"fluent/fluent-bit:3.0-windows-ltsc2022": true,
+			"example.com/probe:1.0":                  true,
+		}
+	case "rollout_sample":
+		return map[string]bool{
+			"busybox:1.28": true,
+			"nginx:1.14.2": true,
+		}
 	default:
 		return map[string]bool{}
 	}
@@ -125,7 +374,7 @@ func processEngineWithManifest(t *testing.T, engine *ImageExtractionEngine, mani
 
 
 func TestSingleImageExtraction(t *testing.T) {
-	verboseLogging = true
+	setVerboseLogging(true)
 	engine := createImageExtractionEngine()
 	engine.Start(1)
 
@@ -142,7 +391,7 @@ func TestSingleImageExtraction(t *testing.T) {
 }
 
 func TestImageExtractionEngine(t *testing.T) {
-	verboseLogging = true
+	setVerboseLogging(true)
 
 	for name, manifest := range sampleManifests {
 		t.Run(name, func(t *testing.T) {
@@ -161,6 +410,125 @@ func TestImageExtractionEngine(t *testing.T) {
 	}
 }
 
+func TestImageExtractionEngineFlagsEmptyTags(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.Start(1)
+
+	manifestPath := createTempManifestFile(t, t.TempDir(), "empty-tag.yaml", `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: "nginx:"
+    - name: sidecar
+      image: busybox:1.36
+`)
+
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	errors := map[string]error{}
+	for _, result := range results {
+		errors[result.Image] = result.Error
+	}
+	assert.Len(t, errors, 2)
+	assert.NoError(t, errors["busybox:1.36"])
+	assert.EqualError(t, errors["nginx:"], `malformed image reference "nginx:": empty tag or digest`)
+}
+
+func TestImageExtractionEngineFlagsMultiLineImages(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.Start(1)
+
+	manifestPath := createTempManifestFile(t, t.TempDir(), "multi-line.yaml", `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: |
+        repository: nginx
+        tag: 1.25
+    - name: sidecar
+      image: busybox:1.36
+`)
+
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	errors := map[string]error{}
+	for _, result := range results {
+		errors[result.Image] = result.Error
+	}
+	assert.Len(t, errors, 2)
+	assert.NoError(t, errors["busybox:1.36"])
+	assert.EqualError(t, errors["repository: nginx\ntag: 1.25\n"], `malformed image reference "repository: nginx\ntag: 1.25\n": contains whitespace`)
+}
+
+func TestImageExtractionEngineFlagsUnrenderedTemplates(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.Start(1)
+
+	manifestPath := createTempManifestFile(t, t.TempDir(), "unrendered.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: '{{ .Values.image }}'
+        - name: sidecar
+          image: busybox:1.36
+`)
+
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	errors := map[string]error{}
+	for _, result := range results {
+		errors[result.Image] = result.Error
+	}
+	assert.Len(t, errors, 2)
+	assert.NoError(t, errors["busybox:1.36"])
+	assert.EqualError(t, errors["{{ .Values.image }}"], `render error in image reference "{{ .Values.image }}": unrendered Go template`)
+}
+
+func TestCheckImageReference(t *testing.T) {
+	tests := []struct {
+		image     string
+		malformed bool
+	}{
+		{"nginx", false},
+		{"nginx:1.25", false},
+		{"localhost:5000/nginx", false},
+		{"localhost:5000/nginx:1.25", false},
+		{"nginx@sha256:abc123", false},
+		{"nginx:", true},
+		{"registry.example.com:5000/team/app:", true},
+		{"nginx@", true},
+		{"nginx:@sha256:abc123", true},
+		{"nginx:1.25\nports:\n  - containerPort: 80", true},
+		{"nginx :1.25", true},
+		{"{{.Values.image}}", true},
+		{"nginx:{{ .Values.tag }}", true},
+		{"registry.example.com/{{ include \"lib.repository\" . }}:1.25", true},
+		{"registry.example.com/" + strings.Repeat("a", maxImageReferenceLength), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			err := checkImageReference(tt.image)
+			if tt.malformed {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 
 func TestExtractImageFromManifest(t *testing.T) {
 	tests := []struct {
@@ -198,6 +566,49 @@ func TestExtractImageFromManifest(t *testing.T) {
 				"redis:6.0":    true,
 			},
 		},
+		{
+			name:           "tekton task",
+			manifestType:   "tekton_task_sample",
+			expectedImages: getExpectedImages("tekton_task_sample"),
+		},
+		{
+			name:           "tekton pipelinerun",
+			manifestType:   "tekton_pipelinerun_sample",
+			expectedImages: getExpectedImages("tekton_pipelinerun_sample"),
+		},
+		{
+			name:         "pod with image volume",
+			manifestType: "pod_image_volume_sample",
+			expectedImages: map[string]bool{
+				"nginx:1.14.2":             true,
+				"quay.io/crio/artifact:v1": true,
+			},
+		},
+		{
+			name:           "list",
+			manifestType:   "list_sample",
+			expectedImages: getExpectedImages("list_sample"),
+		},
+		{
+			name:           "job",
+			manifestType:   "job_sample",
+			expectedImages: getExpectedImages("job_sample"),
+		},
+		{
+			name:           "cronjob",
+			manifestType:   "cronjob_sample",
+			expectedImages: getExpectedImages("cronjob_sample"),
+		},
+		{
+			name:           "argo rollout",
+			manifestType:   "rollout_sample",
+			expectedImages: getExpectedImages("rollout_sample"),
+		},
+		{
+			name:           "crd with a template that is not a pod template",
+			manifestType:   "crd_without_pod_template_sample",
+			expectedImages: getExpectedImages("crd_without_pod_template_sample"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -282,6 +693,135 @@ spec:
 	assert.Equal(t, len(expectedImages), len(images))
 }
 
+func TestSplitYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "single document",
+			content:  "kind: Pod\n",
+			expected: []string{"kind: Pod\n"},
+		},
+		{
+			name:     "leading separator",
+			content:  "---\nkind: Pod\n---\nkind: Service\n",
+			expected: []string{"kind: Pod", "kind: Service\n"},
+		},
+		{
+			name:     "separator with trailing whitespace and comment",
+			content:  "kind: Pod\n---  \nkind: Service\n--- # next\nkind: Job",
+			expected: []string{"kind: Pod", "kind: Service", "kind: Job"},
+		},
+		{
+			name:     "CRLF line endings",
+			content:  "---\r\nkind: Pod\r\n---\r\nkind: Service\r\n",
+			expected: []string{"kind: Pod", "kind: Service\n"},
+		},
+		{
+			name:     "comment only documents are dropped",
+			content:  "---\n# Source: chart/templates/empty.yaml\n---\n# Source: chart/templates/pod.yaml\nkind: Pod\n...\n",
+			expected: []string{"# Source: chart/templates/pod.yaml\nkind: Pod"},
+		},
+		{
+			name:     "separator-like content inside a document is kept",
+			content:  "kind: ConfigMap\ndata:\n  script: |\n    echo ---done\n",
+			expected: []string{"kind: ConfigMap\ndata:\n  script: |\n    echo ---done\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, splitYAMLDocuments(tt.content))
+		})
+	}
+}
+
+func TestImageExtractionEngineHandlesSeparatorFormatting(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.errorChan = make(chan ErrorResult, 1)
+	engine.Start(1)
+
+	content := strings.Join([]string{
+		"---",
+		"apiVersion: v1",
+		"kind: Pod",
+		"metadata:",
+		"  name: first",
+		"spec:",
+		"  containers:",
+		"  - name: web",
+		"    image: nginx:1.14.2",
+		"--- ",
+		"apiVersion: v1",
+		"kind: Pod",
+		"metadata: [unclosed",
+		"---",
+		"apiVersion: v1",
+		"kind: Pod",
+		"metadata:",
+		"  name: third",
+		"spec:",
+		"  containers:",
+		"  - name: cache",
+		"    image: redis:6.0",
+		"",
+	}, "\r\n")
+	manifestPath := createTempManifestFile(t, t.TempDir(), "windows.yaml", content)
+
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	assertImageSetMatches(t, map[string]bool{"nginx:1.14.2": true, "redis:6.0": true}, extractImageNames(results), "windows.yaml")
+
+	// The malformed middle document is reported without dropping the others
+	errorResult := <-engine.errorChan
+	assert.Contains(t, errorResult.Error.Error(), "windows.yaml")
+}
+
+func TestImageExtractionEngineReportsInvalidYAML(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.errorChan = make(chan ErrorResult, 1)
+	engine.Start(1)
+
+	// What a broken post-renderer might emit instead of manifests
+	content := "Error: post-renderer failed\n\tkind: [Pod\n{{ .Values }}: :\n"
+	manifestPath := createTempManifestFile(t, t.TempDir(), "garbled.yaml", content)
+
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	assert.Empty(t, results)
+	errorResult := <-engine.errorChan
+	assert.ErrorIs(t, errorResult.Error, errInvalidRenderedYAML)
+	assert.Contains(t, errorResult.Error.Error(), "garbled.yaml: rendered output is not valid YAML")
+}
+
+func TestCheckRenderedYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"empty output", "", true},
+		{"only comments", "# Source: chart/templates/empty.yaml\n", true},
+		{"multiple documents", "---\nkind: Pod\n---\nkind: Service\n...\n", true},
+		{"unclosed flow sequence", "kind: Pod\nmetadata: [unclosed\n", false},
+		{"tab indentation", "kind: Pod\nmetadata:\n\tname: web\n", false},
+		{"invalid document after a valid one", "kind: Pod\n---\nkind: [Service\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRenderedYAML([]byte(tt.content))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errInvalidRenderedYAML)
+			}
+		})
+	}
+}
+
 // TestRemoveDuplicates tests the removeDuplicates helper function
 func TestRemoveDuplicates(t *testing.T) {
 	tests := []struct {
@@ -309,6 +849,11 @@ func TestRemoveDuplicates(t *testing.T) {
 			input:    []string{"a", "a", "a"},
 			expected: []string{"a"},
 		},
+		{
+			name:     "os variants of one repository",
+			input:    []string{"app:1.0-linux", "app:1.0-windows", "app:1.0-linux", "app@sha256:abc123"},
+			expected: []string{"app:1.0-linux", "app:1.0-windows", "app@sha256:abc123"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -478,3 +1023,48 @@ func TestDockerManifestCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestExtractImagesFromBatchWorkloadsWithoutTemplate(t *testing.T) {
+	job, err := extractImagesFromJob(map[string]interface{}{"kind": "Job", "spec": map[string]interface{}{}})
+	assert.NoError(t, err)
+	assert.Empty(t, job)
+
+	cronJob, err := extractImagesFromCronJob(map[string]interface{}{
+		"kind": "CronJob",
+		"spec": map[string]interface{}{"jobTemplate": map[string]interface{}{}},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, cronJob)
+
+	_, err = extractImagesFromJob(map[string]interface{}{"kind": "CronJob"})
+	assert.Error(t, err)
+}
+
+func TestExtractImagesFromNestedList(t *testing.T) {
+	images, err := extractImageFromManifest(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: List
+  items:
+  - apiVersion: v1
+    kind: Pod
+    metadata:
+      name: nested
+    spec:
+      containers:
+      - name: nested
+        image: busybox:1.28
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: web
+  spec:
+    containers:
+    - name: web
+      image: nginx:1.14.2
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"nginx:1.14.2"}, images, "Expected nested Lists to be skipped")
+}
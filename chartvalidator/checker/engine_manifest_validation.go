@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,6 +17,8 @@ type ManifestValidationResult struct {
 	ManifestFile string
 	Chart       ChartRenderParams	
 	Error        error
+	// Output is what kubeconform printed, naming the resources and fields that failed
+	Output       string
 }
 
 type ManifestValidationEngine struct {
@@ -27,9 +30,30 @@ type ManifestValidationEngine struct {
 	executor  CommandExecutor
 	name      string
 	workerWaitGroup sync.WaitGroup
+
+	// counters are exposed on the metrics endpoint
+	counters engineCounters
+
+	// batch collects every manifest and validates them with a single kubeconform invocation
+	batch bool
+
+	// schemaLocations replace defaultSchemaLocations when set
+	schemaLocations []string
+
+	// kubeVersion is the Kubernetes version manifests are validated against, e.g. 1.29.0
+	kubeVersion string
+
+	// skipKinds are resource kinds kubeconform doesn't validate, e.g. CRDs without a schema
+	skipKinds []string
 }
 
 func (engine *ManifestValidationEngine) Start(workerCount int) {
+	if engine.batch {
+		engine.workerWaitGroup.Add(1)
+		go engine.batchWorker()
+		go engine.allDoneWorker()
+		return
+	}
 	for i := 0; i < workerCount; i++ {
 		engine.workerWaitGroup.Add(1)		
 		go func(workerId int) {
@@ -54,15 +78,18 @@ func (engine *ManifestValidationEngine) worker(workerId int) {
 				logEngineDebug(engine.name, workerId, "input closed")
 				return
 			}
+			engine.counters.received.Add(1)
 			result, err := engine.validateManifest(input.Chart,input.ManifestPath, workerId)
+			engine.counters.finish(err)
 			if err != nil {
 				engine.errorChan <- ErrorResult{
 					Chart: input.Chart,
+					Stage: stageValidation,
 					Error:  fmt.Errorf("failed to validate manifest %s: %w", input.ManifestPath, err),
 				}
 				continue
-			} else {
-				engine.resultChan <- *result
+			} else if !sendOrDone(engine.context, engine.resultChan, *result) {
+				return
 			}
 
 		case <-engine.context.Done():
@@ -80,16 +107,14 @@ func (engine *ManifestValidationEngine) validateManifest(chart ChartRenderParams
 		return nil, fmt.Errorf("manifest file does not exist: %s", manifestFile)
 	}
 	// Build kubeconform command
-	args := []string{
-		"-strict",
-		"-summary",
-		"-schema-location", "default",
-		"-schema-location", "https://raw.githubusercontent.com/datreeio/CRDs-catalog/main/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json",
-		"-schema-location", "ci/schemas/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json",
+	args := []string{"-strict", "-summary"}
+	args = append(args, engine.kubeconformSchemaArgs()...)
+	args = append(args, engine.kubeconformOptionArgs()...)
+	args = append(args,
 		"-verbose",
 		"-exit-on-error",
 		manifestFile,
-	}
+	)
 
 	cmd := engine.executor.CommandContext(engine.context, 
 		"kubeconform", args...
@@ -97,10 +122,23 @@ func (engine *ManifestValidationEngine) validateManifest(chart ChartRenderParams
 	cmdStr := fmt.Sprintf("%s %s", filepath.Base(cmd.GetPath()), strings.Join(args, " "))
 	logEngineDebug(engine.name, workerId, fmt.Sprintf("executing: %s", cmdStr))
 	
-	if err := cmd.Run(); err != nil {
-		msg := fmt.Sprintf("kubeconform command failed: %s", err.Error())
+	// kubeconform reports resources on stdout and its own problems on stderr
+	stdout, stderr, err := cmd.SplitOutput()
+	diagnostics := strings.TrimSpace(string(stdout) + "\n" + string(stderr))
+	if err != nil {
+		msg := fmt.Sprintf("kubeconform command failed: %s\nOutput: %s", err.Error(), diagnostics)
 		logEngineWarning(engine.name, workerId, msg)
-		return nil, fmt.Errorf("kubeconform command failed: %w", err)
+		if diagnostics != "" {
+			err = fmt.Errorf("kubeconform command failed: %w\n%s", err, diagnostics)
+		} else {
+			err = fmt.Errorf("kubeconform command failed: %w", err)
+		}
+		return &ManifestValidationResult{
+			ManifestFile: manifestFile,
+			Error: err,
+			Chart: chart,
+			Output: diagnostics,
+		}, err
 	}
 
 	logEngineDebug(engine.name, workerId, fmt.Sprintf("succeeded: %s", cmdStr))
@@ -108,5 +146,153 @@ func (engine *ManifestValidationEngine) validateManifest(chart ChartRenderParams
 		ManifestFile: manifestFile, 
 		Error: nil, 
 		Chart: chart,
+		Output: diagnostics,
 	}, nil
 }
+
+// defaultSchemaLocations are the kubeconform schema locations used when none are configured
+var defaultSchemaLocations = []string{
+	"default",
+	"https://raw.githubusercontent.com/datreeio/CRDs-catalog/main/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json",
+	"ci/schemas/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json",
+}
+
+// kubeconformSchemaArgs returns the schema locations kubeconform validates against
+func (engine *ManifestValidationEngine) kubeconformSchemaArgs() []string {
+	locations := engine.schemaLocations
+	if len(locations) == 0 {
+		locations = defaultSchemaLocations
+	}
+	var args []string
+	for _, location := range locations {
+		args = append(args, "-schema-location", location)
+	}
+	return args
+}
+
+// kubeconformOptionArgs targets the configured Kubernetes version and skips
+// the configured kinds, leaving kubeconform's defaults for whatever isn't set
+func (engine *ManifestValidationEngine) kubeconformOptionArgs() []string {
+	var args []string
+	if engine.kubeVersion != "" {
+		args = append(args, "-kubernetes-version", engine.kubeVersion)
+	}
+	if len(engine.skipKinds) > 0 {
+		args = append(args, "-skip", strings.Join(engine.skipKinds, ","))
+	}
+	return args
+}
+
+// kubeconformResource is a single resource entry of kubeconform's JSON output
+type kubeconformResource struct {
+	Filename string `json:"filename"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Status   string `json:"status"`
+	Msg      string `json:"msg"`
+}
+
+// kubeconformOutput is kubeconform's -output json document
+type kubeconformOutput struct {
+	Resources []kubeconformResource `json:"resources"`
+}
+
+// batchWorker waits for every rendered manifest and then validates them all
+// with one kubeconform invocation, attributing the outcome back to each file
+func (engine *ManifestValidationEngine) batchWorker() {
+	defer engine.workerWaitGroup.Done()
+
+	var inputs []RenderResult
+	collecting := true
+	for collecting {
+		select {
+		case input, ok := <-engine.inputChan:
+			if !ok {
+				collecting = false
+				continue
+			}
+			engine.counters.received.Add(1)
+			inputs = append(inputs, input)
+		case <-engine.context.Done():
+			logEngineDebug(engine.name, -1, "context done")
+			return
+		}
+	}
+	logEngineDebug(engine.name, -1, fmt.Sprintf("input closed, validating %d manifests in one batch", len(inputs)))
+	if len(inputs) == 0 {
+		return
+	}
+
+	failures, batchErr := engine.validateManifestsBatch(inputs)
+	for _, input := range inputs {
+		err := batchErr
+		if err == nil {
+			err = failures[input.ManifestPath]
+		}
+		engine.counters.finish(err)
+		if err != nil {
+			engine.errorChan <- ErrorResult{
+				Chart: input.Chart,
+				Stage: stageValidation,
+				Error: fmt.Errorf("failed to validate manifest %s: %w", input.ManifestPath, err),
+			}
+			continue
+		}
+		result := ManifestValidationResult{
+			ManifestFile: input.ManifestPath,
+			Chart:        input.Chart,
+		}
+		if !sendOrDone(engine.context, engine.resultChan, result) {
+			return
+		}
+	}
+}
+
+// validateManifestsBatch runs kubeconform once over all inputs. It returns the
+// failure for each manifest that did not validate, or an error when the
+// kubeconform output could not be attributed to individual files.
+func (engine *ManifestValidationEngine) validateManifestsBatch(inputs []RenderResult) (map[string]error, error) {
+	args := []string{"-strict"}
+	args = append(args, engine.kubeconformSchemaArgs()...)
+	args = append(args, engine.kubeconformOptionArgs()...)
+	args = append(args,
+		"-output", "json",
+		"-verbose",
+		"-n", fmt.Sprint(getJobCount()),
+	)
+	for _, input := range inputs {
+		args = append(args, input.ManifestPath)
+	}
+
+	cmd := engine.executor.CommandContext(engine.context, "kubeconform", args...)
+	logEngineDebug(engine.name, -1, fmt.Sprintf("executing: kubeconform %s", strings.Join(args, " ")))
+
+	// kubeconform exits non-zero when any resource is invalid, the JSON output tells which
+	output, runErr := cmd.CombinedOutput()
+
+	var parsed kubeconformOutput
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("kubeconform command failed: %w", runErr)
+		}
+		return nil, fmt.Errorf("failed to parse kubeconform output: %w", err)
+	}
+
+	failures := map[string]error{}
+	for _, resource := range parsed.Resources {
+		if resource.Status != "statusInvalid" && resource.Status != "statusError" {
+			continue
+		}
+		msg := fmt.Sprintf("%s %s: %s", resource.Kind, resource.Name, resource.Msg)
+		if previous, found := failures[resource.Filename]; found {
+			failures[resource.Filename] = fmt.Errorf("%v; %s", previous, msg)
+		} else {
+			failures[resource.Filename] = fmt.Errorf("kubeconform validation failed: %s", msg)
+		}
+	}
+	if runErr != nil && len(failures) == 0 {
+		return nil, fmt.Errorf("kubeconform command failed: %w", runErr)
+	}
+	return failures, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,6 +10,7 @@ import (
 func TestManifestValidationEngine(t *testing.T) {
 	mockExecutor := createManifestValidationMockExecutor()
 	engine := createManifestValidationEngine(mockExecutor)
+	engine.schemaLocations = []string{"default", "schemas/{{ .ResourceKind }}.json"}
 	engine.Start(1)
 
 	testManifestFile := "test_data/example.yaml"
@@ -23,14 +25,58 @@ func TestManifestValidationEngine(t *testing.T) {
 	assert.Equal(t, testManifestFile, result.ManifestFile, "Expected correct manifest file path")
 
 	// Verify the command that was executed
-	expectedCommand := "kubeconform -strict -summary -schema-location default -schema-location https://raw.githubusercontent.com/datreeio/CRDs-catalog/main/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json -schema-location ci/schemas/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json -verbose -exit-on-error test_data/example.yaml"
+	expectedCommand := "kubeconform -strict -summary -schema-location default -schema-location schemas/{{ .ResourceKind }}.json -verbose -exit-on-error test_data/example.yaml"
 	assertCommandExecution(t, mockExecutor, expectedCommand)
 
 	close(engine.inputChan)
 }
 
+func TestKubeconformSchemaArgsDefaults(t *testing.T) {
+	engine := &ManifestValidationEngine{}
+
+	assert.Equal(t, []string{
+		"-schema-location", "default",
+		"-schema-location", "https://raw.githubusercontent.com/datreeio/CRDs-catalog/main/{{.Group}}/{{.ResourceKind}}_{{.ResourceAPIVersion}}.json",
+		"-schema-location", "ci/schemas/{{ .ResourceKind }}_{{ .ResourceAPIVersion }}.json",
+	}, engine.kubeconformSchemaArgs())
+}
+
+func TestKubeconformKubernetesVersionOnlyWhenSet(t *testing.T) {
+	mockExecutor := createManifestValidationMockExecutor()
+	engine := createManifestValidationEngine(mockExecutor)
+
+	_, err := engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+	assert.NoError(t, err)
+	assert.NotContains(t, mockExecutor.GetFullCommand(), "-kubernetes-version")
+
+	engine.kubeVersion = "1.29.0"
+	_, err = engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+	assert.NoError(t, err)
+	assert.Contains(t, mockExecutor.GetFullCommand(), "-kubernetes-version 1.29.0 -verbose")
+}
+
+func TestKubeconformSkipKindsOnlyWhenSet(t *testing.T) {
+	mockExecutor := createManifestValidationMockExecutor()
+	engine := createManifestValidationEngine(mockExecutor)
+
+	_, err := engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+	assert.NoError(t, err)
+	unchanged := mockExecutor.GetFullCommand()
+	assert.NotContains(t, unchanged, "-skip")
+
+	engine.skipKinds = []string{"Widget", "Gadget"}
+	_, err = engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+	assert.NoError(t, err)
+	assert.Contains(t, mockExecutor.GetFullCommand(), "-skip Widget,Gadget -verbose")
+
+	engine.skipKinds = []string{}
+	_, err = engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, unchanged, mockExecutor.GetFullCommand())
+}
+
 func TestManifestValidationEngineMultipleFiles(t *testing.T) {
-	verboseLogging = true
+	setVerboseLogging(true)
 
 	testCases := []struct {
 		name         string
@@ -121,4 +167,79 @@ func TestManifestValidationEngineWithError(t *testing.T) {
 
 	close(engine.inputChan)
 	engine.workerWaitGroup.Wait()
-}
\ No newline at end of file
+}
+
+func TestManifestValidationSurfacesKubeconformOutput(t *testing.T) {
+	diagnostics := "test_data/example.yaml - Deployment web is invalid: problem validating schema. Check JSON formatting: jsonschema: '/spec/replicas' does not validate: expected integer, but got string"
+	mockExecutor := createManifestValidationMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		return []byte(diagnostics + "\n"), nil, errors.New("exit status 1")
+	}
+	engine := createManifestValidationEngine(mockExecutor)
+
+	result, err := engine.validateManifest(createTestChart(), "test_data/example.yaml", 0)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "kubeconform command failed: exit status 1")
+	assert.Contains(t, err.Error(), "'/spec/replicas' does not validate")
+	assert.Equal(t, diagnostics, result.Output)
+	assert.Equal(t, err, result.Error)
+
+	// The diagnostics reach the run-checks report through the error result
+	engine.Start(1)
+	sendRenderResultToEngine(engine, "test_data/example.yaml")
+	errResult := <-engine.errorChan
+	assert.Contains(t, errResult.Error.Error(), "'/spec/replicas' does not validate")
+
+	close(engine.inputChan)
+	engine.workerWaitGroup.Wait()
+}
+
+func TestManifestValidationEngineBatch(t *testing.T) {
+	mockExecutor := createManifestValidationMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		return []byte(`{
+  "resources": [
+    {"filename": "test_data/deployment.yaml", "kind": "Deployment", "name": "web", "version": "apps/v1", "status": "statusValid", "msg": ""},
+    {"filename": "test_data/service.yaml", "kind": "Service", "name": "web", "version": "v1", "status": "statusInvalid", "msg": "spec.ports: Invalid type"},
+    {"filename": "test_data/configmap.yaml", "kind": "ConfigMap", "name": "config", "version": "v1", "status": "statusValid", "msg": ""}
+  ]
+}`), assert.AnError
+	}
+
+	engine := createManifestValidationEngine(mockExecutor)
+	engine.batch = true
+	engine.Start(4)
+
+	manifests := []string{"test_data/deployment.yaml", "test_data/service.yaml", "test_data/configmap.yaml"}
+	go func() {
+		for _, manifest := range manifests {
+			engine.inputChan <- RenderResult{ManifestPath: manifest, Chart: ChartRenderParams{ChartName: manifest}}
+		}
+		close(engine.inputChan)
+	}()
+
+	validated := map[string]bool{}
+	failed := map[string]string{}
+	for i := 0; i < len(manifests); i++ {
+		select {
+		case result := <-engine.resultChan:
+			validated[result.ManifestFile] = true
+		case errResult := <-engine.errorChan:
+			failed[errResult.Chart.ChartName] = errResult.Error.Error()
+		}
+	}
+	engine.workerWaitGroup.Wait()
+
+	assert.Equal(t, map[string]bool{"test_data/deployment.yaml": true, "test_data/configmap.yaml": true}, validated)
+	assert.Len(t, failed, 1)
+	assert.Contains(t, failed["test_data/service.yaml"], "Service web: spec.ports: Invalid type")
+
+	// All manifests went through one kubeconform invocation
+	assert.Len(t, mockExecutor.History, 1)
+	expectedPrefix := "kubeconform -strict -schema-location default"
+	actualCommand := mockExecutor.GetFullCommand()
+	assert.Contains(t, actualCommand, expectedPrefix)
+	assert.Contains(t, actualCommand, "-output json -verbose -n ")
+	assert.Contains(t, actualCommand, "test_data/deployment.yaml test_data/service.yaml test_data/configmap.yaml")
+}
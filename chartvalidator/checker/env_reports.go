@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// groupResultsByEnv splits results by the env of their chart, keeping the
+// order they arrived in, and returns the envs in the order they first appeared
+func groupResultsByEnv(results []AppCheckResult) ([]string, map[string][]AppCheckResult) {
+	var envs []string
+	byEnv := map[string][]AppCheckResult{}
+	for _, result := range results {
+		env := result.Chart.Env
+		if _, seen := byEnv[env]; !seen {
+			envs = append(envs, env)
+		}
+		byEnv[env] = append(byEnv[env], result)
+	}
+	return envs, byEnv
+}
+
+// writeEnvReports writes a report per environment to dir: <env>.json in the
+// -format json layout and <env>.txt with the human-readable result lines
+func writeEnvReports(dir string, results []AppCheckResult, incomplete bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory %s: %w", dir, err)
+	}
+
+	envs, byEnv := groupResultsByEnv(results)
+	for _, env := range envs {
+		if env == "" || env != filepath.Base(env) {
+			return fmt.Errorf("cannot write a report file for env %q", env)
+		}
+
+		var jsonReport bytes.Buffer
+		if err := writeJSONReport(&jsonReport, byEnv[env], incomplete); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, env+".json")
+		if err := os.WriteFile(path, jsonReport.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write report %s: %w", path, err)
+		}
+
+		var text bytes.Buffer
+		for _, result := range byEnv[env] {
+			printResult(&text, result)
+		}
+		path = filepath.Join(dir, env+".txt")
+		if err := os.WriteFile(path, text.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write report %s: %w", path, err)
+		}
+	}
+	return nil
+}
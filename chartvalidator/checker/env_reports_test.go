@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteEnvReports(t *testing.T) {
+	dev := createTestChart()
+	prod := createTestChart()
+	prod.Env = "production"
+	results := []AppCheckResult{
+		{Chart: prod, Image: "nginx:1.25"},
+		{Chart: dev, Image: "redis:6.2", Error: fmt.Errorf("docker image does not exist: redis:6.2")},
+		{Chart: prod, Image: "busybox:1.36"},
+	}
+
+	dir := filepath.Join(t.TempDir(), "reports")
+	assert.NoError(t, writeEnvReports(dir, results, false))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"development.json", "development.txt", "production.json", "production.txt"}, names)
+
+	var report jsonReport
+	data, err := os.ReadFile(filepath.Join(dir, "production.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.True(t, report.Passed)
+	assert.Equal(t, []resultRecord{
+		{Env: "production", Chart: "test-chart", ChartVersion: "1.0.0", Image: "nginx:1.25", Status: statusPassed},
+		{Env: "production", Chart: "test-chart", ChartVersion: "1.0.0", Image: "busybox:1.36", Status: statusPassed},
+	}, report.Results)
+
+	data, err = os.ReadFile(filepath.Join(dir, "development.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.False(t, report.Passed)
+	assert.Len(t, report.Results, 1)
+	assert.Equal(t, "redis:6.2", report.Results[0].Image)
+
+	text, err := os.ReadFile(filepath.Join(dir, "development.txt"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(text), ">>> chart test-chart 1.0.0 from env development with image redis:6.2: ✗ Error")
+	assert.NotContains(t, string(text), "production")
+}
+
+func TestWriteEnvReportsRejectsUnsafeEnvNames(t *testing.T) {
+	chart := createTestChart()
+	chart.Env = "../outside"
+
+	err := writeEnvReports(t.TempDir(), []AppCheckResult{{Chart: chart, Image: "nginx:1.25"}}, false)
+	assert.EqualError(t, err, `cannot write a report file for env "../outside"`)
+}
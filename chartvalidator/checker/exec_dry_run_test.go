@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRunCommandSpawnsNoProcess(t *testing.T) {
+	var logs bytes.Buffer
+	logOutput = &logs
+	defer func() { logOutput = os.Stdout }()
+
+	executor := &DryRunCommandExecutor{}
+	cmd := executor.CommandContext(createTestContext(), "command-that-does-not-exist", "--flag", "value")
+	cmd.SetEnv([]string{"DOCKER_CONFIG=/tmp/docker"})
+
+	output, err := cmd.CombinedOutput()
+
+	assert.NoError(t, err, "Expected the missing command not to be started")
+	assert.Empty(t, output)
+	assert.Equal(t, []string{"DOCKER_CONFIG=/tmp/docker command-that-does-not-exist --flag value"}, executor.History)
+	assert.Contains(t, logs.String(), "dry-run: DOCKER_CONFIG=/tmp/docker command-that-does-not-exist --flag value")
+}
+
+func TestAppCheckerDryRunLogsCommandsPerChart(t *testing.T) {
+	var logs bytes.Buffer
+	logOutput = &logs
+	defer func() { logOutput = os.Stdout }()
+
+	valuesDir := t.TempDir()
+	chart := createTestChart()
+	chart.BaseValuesFile = createTempManifestFile(t, valuesDir, "values.yaml", "replicaCount: 1\n")
+	chart.ValuesOverride = createTempManifestFile(t, valuesDir, "override.yaml", "replicaCount: 2\n")
+
+	options := AppCheckerOptions{OutputDir: t.TempDir(), DryRun: true}
+	executor := options.commandExecutor().(*DryRunCommandExecutor)
+	engine := NewAppCheckerEngine(createTestContext(), executor, options)
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{chart})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	assert.Len(t, executor.History, 2, "Expected helm and kubeconform to be logged, and no images to check")
+	assert.Contains(t, executor.History[0], "helm template "+chart.ChartName)
+	assert.Contains(t, executor.History[1], "kubeconform ")
+	assert.Contains(t, executor.History[0], "-f "+filepath.Join(valuesDir, "values.yaml"))
+}
+
+func TestAppCheckerDryRunWithConftestPolicy(t *testing.T) {
+	var logs bytes.Buffer
+	logOutput = &logs
+	defer func() { logOutput = os.Stdout }()
+
+	chart := createTestChart()
+	chart.BaseValuesFile = createTempManifestFile(t, t.TempDir(), "values.yaml", "replicaCount: 1\n")
+	chart.ValuesOverride = ""
+
+	options := AppCheckerOptions{OutputDir: t.TempDir(), DryRun: true, ConftestPolicy: t.TempDir()}
+	executor := options.commandExecutor().(*DryRunCommandExecutor)
+	engine := NewAppCheckerEngine(createTestContext(), executor, options)
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{chart})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Error, "Expected conftest's dry-run output to parse as a report without findings")
+	assert.Contains(t, logs.String(), "dry-run: conftest test --policy")
+}
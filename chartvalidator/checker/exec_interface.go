@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 )
@@ -15,7 +18,12 @@ type CommandExecutor interface {
 // Command interface wraps exec.Cmd for testing
 type Command interface {
 	SetDir(dir string)
+	SetStdin(stdin io.Reader)
+	// SetEnv adds KEY=value variables to the environment the command inherits
+	SetEnv(env []string)
 	CombinedOutput() ([]byte, error)
+	// SplitOutput runs the command and returns stdout and stderr separately
+	SplitOutput() (stdout []byte, stderr []byte, err error)
 	Run() error
 	GetPath() string
 	GetArgs() []string
@@ -37,10 +45,26 @@ func (r *RealCommand) SetDir(dir string) {
 	r.cmd.Dir = dir
 }
 
+func (r *RealCommand) SetStdin(stdin io.Reader) {
+	r.cmd.Stdin = stdin
+}
+
+func (r *RealCommand) SetEnv(env []string) {
+	r.cmd.Env = append(os.Environ(), env...)
+}
+
 func (r *RealCommand) CombinedOutput() ([]byte, error) {
 	return r.cmd.CombinedOutput()
 }
 
+func (r *RealCommand) SplitOutput() ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	r.cmd.Stdout = &stdout
+	r.cmd.Stderr = &stderr
+	err := r.cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
 func (r *RealCommand) Run() error {
 	return r.cmd.Run()
 }
@@ -55,5 +79,12 @@ func (r *RealCommand) GetArgs() []string {
 
 func (r *RealCommandExecutor) FileExists(path string) bool {
 	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+	if os.IsNotExist(err) {
+		// Stat follows symlinks, so a link that exists itself points nowhere
+		if _, lerr := os.Lstat(path); lerr == nil {
+			logEngineWarning("Executor", -1, fmt.Sprintf("%s is a broken symlink", path))
+		}
+		return false
+	}
+	return true
 }
\ No newline at end of file
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"io"
 	"strings"
+	"sync"
 )
 
 // MockCommandExecutor captures command execution for testing
@@ -10,14 +12,32 @@ type MockCommandExecutor struct {
 	LastCommand string
 	LastArgs    []string
 	Output      []byte
+	// Stderr is what the command writes to stderr in addition to Output
+	Stderr      []byte
 	Error       error
 	BehaviorOnRun func() error
+	BehaviorOnCombinedOutput func() ([]byte, error)
+	BehaviorOnSplitOutput func() ([]byte, []byte, error)
 	FileExistsMap  map[string]bool
+
+	// History records every command line created, in order
+	History     []string
+	// Stdins records what was passed to SetStdin, in order
+	Stdins      []string
+	// Envs records what was passed to SetEnv, in order
+	Envs        [][]string
+	// LastContext is the context the last command was created with
+	LastContext context.Context
+	historyLock sync.Mutex
 }
 
 func (m *MockCommandExecutor) CommandContext(ctx context.Context, name string, args ...string) Command {
+	m.historyLock.Lock()
+	m.History = append(m.History, name+" "+strings.Join(args, " "))
+	m.historyLock.Unlock()
 	m.LastCommand = name
 	m.LastArgs = args
+	m.LastContext = ctx
 	return &MockCommand{
 		executor: m,
 		output:   m.Output,
@@ -44,8 +64,31 @@ func (m *MockCommand) SetDir(dir string) {
 	m.dir = dir
 }
 
+func (m *MockCommand) SetStdin(stdin io.Reader) {
+	data, _ := io.ReadAll(stdin)
+	m.executor.historyLock.Lock()
+	m.executor.Stdins = append(m.executor.Stdins, string(data))
+	m.executor.historyLock.Unlock()
+}
+
+func (m *MockCommand) SetEnv(env []string) {
+	m.executor.historyLock.Lock()
+	m.executor.Envs = append(m.executor.Envs, env)
+	m.executor.historyLock.Unlock()
+}
+
 func (m *MockCommand) CombinedOutput() ([]byte, error) {
-	return m.output, m.err
+	if m.executor.BehaviorOnCombinedOutput != nil {
+		return m.executor.BehaviorOnCombinedOutput()
+	}
+	return append(append([]byte{}, m.output...), m.executor.Stderr...), m.err
+}
+
+func (m *MockCommand) SplitOutput() ([]byte, []byte, error) {
+	if m.executor.BehaviorOnSplitOutput != nil {
+		return m.executor.BehaviorOnSplitOutput()
+	}
+	return m.output, m.executor.Stderr, m.err
 }
 
 func (m *MockCommand) Run() error {
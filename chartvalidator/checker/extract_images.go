@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runExtractImagesCommand(args []string) {
+	fs := flag.NewFlagSet("extract-images", flag.ExitOnError)
+
+	var (
+		dir      = fs.String("dir", "", "Directory of rendered manifests to extract images from, searched recursively for .yaml and .yml files.")
+		output   = fs.String("output", "images", "Directory to write one JSON image list per manifest to. It is cleared first, so it has to be empty or written by an earlier extract-images run.")
+		symlinks = fs.Bool("follow-symlinks", false, "Follow symlinked directories when discovering manifests.")
+		verbose  = fs.Bool("v", false, "Enable verbose logging.")
+	)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: run-manifest-checks extract-images -dir <manifests> [flags]")
+		fmt.Println("")
+		fmt.Println("Writes the Docker images referenced by each rendered manifest in a directory to a JSON file, then prints every image found, one per line.")
+		fmt.Println("")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	setVerboseLogging(*verbose)
+	followSymlinks = *symlinks
+
+	if err := extractImagesOfDir(*dir, *output, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error extracting images: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// imageListsMarker marks an output directory as written by extract-images,
+// which makes it safe to clear on the next run
+const imageListsMarker = ".extract-images"
+
+// extractImagesOfDir writes the per-manifest JSON image lists of manifestDir to
+// outputDir and prints the sorted, deduplicated union of their images
+func extractImagesOfDir(manifestDir, outputDir string, w io.Writer) error {
+	// The output dir is cleared, which must never take the manifests with it
+	absManifests, err := filepath.Abs(manifestDir)
+	if err == nil {
+		absManifests, err = resolveSymlinks(absManifests)
+	}
+	if err != nil {
+		return err
+	}
+	absOutput, err := filepath.Abs(outputDir)
+	if err == nil {
+		absOutput, err = resolveSymlinks(absOutput)
+	}
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absOutput, absManifests); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output directory %s contains the manifest directory %s", outputDir, manifestDir)
+	}
+	if err := checkImageListsDir(outputDir); err != nil {
+		return err
+	}
+
+	if err := extractDockerImages(manifestDir, outputDir, -1); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, imageListsMarker), nil, 0644); err != nil {
+		return fmt.Errorf("failed to mark output directory: %w", err)
+	}
+
+	jsonFiles, err := findJSONFiles(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to find image lists in %s: %w", outputDir, err)
+	}
+	images, err := extractAllImagesFromJSONFiles(jsonFiles)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range deduplicateImages(images) {
+		fmt.Fprintln(w, image)
+	}
+	return nil
+}
+
+// checkImageListsDir returns an error for an output directory that has files
+// but wasn't written by extract-images, so that clearing it can't delete
+// anything else
+func checkImageListsDir(outputDir string) error {
+	entries, err := os.ReadDir(outputDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, imageListsMarker)); err == nil {
+		return nil
+	}
+	return fmt.Errorf("output directory %s is not empty and wasn't written by extract-images, refusing to clear it", outputDir)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractImagesOfDir(t *testing.T) {
+	manifestDir := t.TempDir()
+	createTempManifestFile(t, manifestDir, "web.yaml", widgetManifest)
+	createTempManifestFile(t, filepath.Join(manifestDir, "nested"), "debug.yaml", imagesOfManifest)
+	outputDir := filepath.Join(t.TempDir(), "images")
+
+	var out bytes.Buffer
+	err := extractImagesOfDir(manifestDir, outputDir, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "busybox:1.36\nnginx:1.20\nnginx:1.25\nregistry.example.com/migrate:1.0\n", out.String())
+	jsonFiles, err := findJSONFiles(outputDir)
+	assert.NoError(t, err)
+	assert.Len(t, jsonFiles, 2, "Expected one JSON image list per manifest")
+}
+
+func TestExtractImagesOfDirRefusesToClearTheManifests(t *testing.T) {
+	manifestDir := t.TempDir()
+	manifest := createTempManifestFile(t, manifestDir, "web.yaml", widgetManifest)
+
+	err := extractImagesOfDir(manifestDir, manifestDir, &bytes.Buffer{})
+
+	assert.Error(t, err)
+	assert.FileExists(t, manifest)
+}
+
+func TestExtractImagesOfDirRefusesToClearAParentOfTheManifests(t *testing.T) {
+	workDir := t.TempDir()
+	manifest := createTempManifestFile(t, filepath.Join(workDir, "manifests"), "web.yaml", widgetManifest)
+
+	err := extractImagesOfDir(filepath.Join(workDir, "manifests"), workDir, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "contains the manifest directory")
+	assert.FileExists(t, manifest)
+}
+
+func TestExtractImagesOfDirRefusesToClearOtherDirectories(t *testing.T) {
+	manifestDir := t.TempDir()
+	createTempManifestFile(t, manifestDir, "web.yaml", widgetManifest)
+	outputDir := t.TempDir()
+	other := createTempManifestFile(t, outputDir, "notes.txt", "keep me")
+
+	err := extractImagesOfDir(manifestDir, outputDir, &bytes.Buffer{})
+
+	assert.ErrorContains(t, err, "wasn't written by extract-images")
+	assert.FileExists(t, other)
+}
+
+func TestExtractImagesOfDirClearsItsOwnOutput(t *testing.T) {
+	manifestDir := t.TempDir()
+	createTempManifestFile(t, manifestDir, "web.yaml", widgetManifest)
+	outputDir := filepath.Join(t.TempDir(), "images")
+
+	assert.NoError(t, extractImagesOfDir(manifestDir, outputDir, &bytes.Buffer{}))
+	var out bytes.Buffer
+	assert.NoError(t, extractImagesOfDir(manifestDir, outputDir, &out), "Expected a second run to reuse the output directory")
+	assert.NotEmpty(t, out.String())
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// dependencyBuild makes sure each chart directory has its dependencies built at most once
+type dependencyBuild struct {
+	once sync.Once
+	err  error
+}
+
+// chartDependencies tracks the helm dependency builds performed by a rendering engine
+type chartDependencies struct {
+	builds map[string]*dependencyBuild
+	lock   sync.Mutex
+}
+
+// ensure runs helm dependency build for a vendored chart the first time it is
+// called for that chart directory, so umbrella charts have their charts/ populated
+func (d *chartDependencies) ensure(ctx context.Context, executor CommandExecutor, chartPath string) error {
+	d.lock.Lock()
+	if d.builds == nil {
+		d.builds = map[string]*dependencyBuild{}
+	}
+	build, ok := d.builds[chartPath]
+	if !ok {
+		build = &dependencyBuild{}
+		d.builds[chartPath] = build
+	}
+	d.lock.Unlock()
+
+	build.once.Do(func() {
+		cmd := executor.CommandContext(ctx, "helm", "dependency", "build", chartPath)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			build.err = fmt.Errorf("helm dependency build for %s failed: %w: %s", chartPath, err, strings.TrimSpace(string(output)))
+		}
+	})
+	return build.err
+}
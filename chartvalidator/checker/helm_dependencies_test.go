@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderBuildsVendoredChartDependenciesOnce(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		buildDeps: true,
+	}
+	chart := createTestChart()
+	chart.ChartName = "charts/umbrella"
+	chart.RepoURL = ""
+	chart.BaseValuesFile = ""
+	chart.ValuesOverride = ""
+
+	_, err := engine.renderSingleChart(chart, 0)
+	assert.NoError(t, err)
+	_, err = engine.renderSingleChart(chart, 1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"helm dependency build charts/umbrella",
+		"helm template umbrella charts/umbrella --include-crds",
+		"helm template umbrella charts/umbrella --include-crds",
+	}, mockExecutor.History)
+}
+
+func TestRenderStopsWhenDependencyBuildFails(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		return []byte("Error: no repository definition for https://example.com/charts"), errors.New("exit status 1")
+	}
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		buildDeps: true,
+	}
+	chart := createTestChart()
+	chart.ChartName = "charts/umbrella"
+	chart.RepoURL = ""
+
+	_, err := engine.renderSingleChart(chart, 0)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "helm dependency build for charts/umbrella failed")
+	assert.Contains(t, err.Error(), "no repository definition")
+	assert.Equal(t, []string{"helm dependency build charts/umbrella"}, mockExecutor.History)
+}
+
+func TestRenderSkipsDependencyBuildForRemoteCharts(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		buildDeps: true,
+	}
+
+	_, err := engine.renderSingleChart(createTestChart(), 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, mockExecutor.History, 1)
+	assert.Contains(t, mockExecutor.History[0], "helm template test-chart")
+}
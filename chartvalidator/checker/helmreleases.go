@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// helmRepository is the subset of a Flux HelmRepository needed to resolve chart sources
+type helmRepository struct {
+	Name      string
+	Namespace string
+	URL       string
+}
+
+// findChartsInHelmReleases scans Flux HelmRelease manifests and extracts chart information
+func findChartsInHelmReleases(envDir string, selectedEnvs []string) ([]ChartRenderParams, error) {
+	fmt.Fprintln(logOutput, "Scanning environments for HelmReleases in", envDir)
+
+	return forEachEnvironment(envDir, selectedEnvs, processFluxEnvironment)
+}
+
+// processFluxEnvironment extracts charts from every HelmRelease found below the environment directory
+func processFluxEnvironment(envName, envPath string) ([]ChartRenderParams, error) {
+	files, err := findYAMLFiles(envPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []map[string]any
+	repositories := map[string]helmRepository{}
+
+	for _, f := range files {
+		docs, err := readFluxDocuments(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range docs {
+			switch str(doc["kind"]) {
+			case "HelmRelease":
+				releases = append(releases, doc)
+			case "HelmRepository":
+				repo := extractHelmRepository(doc)
+				repositories[repo.key()] = repo
+			}
+		}
+	}
+
+	var charts []ChartRenderParams
+	for _, release := range releases {
+		chart, err := extractHelmReleaseChart(release, envName, repositories)
+		if err != nil {
+			logEngineWarning("FluxDiscovery", -1, err.Error())
+			continue
+		}
+		charts = append(charts, chart)
+	}
+	return charts, nil
+}
+
+// fluxKind matches the kind line of a HelmRelease or HelmRepository, to tell
+// whether a document that doesn't parse is one of them
+var fluxKind = regexp.MustCompile(`(?m)^kind:\s*["']?(HelmRelease|HelmRepository)["']?\s*$`)
+
+// readFluxDocuments returns the documents of file that are YAML maps. An env
+// holds more than Flux objects, such as list-shaped values files or templated
+// YAML, so other documents are skipped with a warning. Only a HelmRelease or
+// HelmRepository that doesn't parse is an error.
+func readFluxDocuments(file string) ([]map[string]any, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var docs []map[string]any
+	for i, text := range splitYAMLDocuments(string(content)) {
+		var value any
+		if err := yaml.Unmarshal([]byte(text), &value); err != nil {
+			if kind := fluxKind.FindStringSubmatch(text); kind != nil {
+				return nil, fmt.Errorf("failed to parse %s in %s: %w", kind[1], file, err)
+			}
+			logEngineWarning("FluxDiscovery", -1, fmt.Sprintf("skipping document %d of %s, it isn't valid YAML: %v", i+1, file, err))
+			continue
+		}
+		switch doc := value.(type) {
+		case nil:
+		case map[string]any:
+			docs = append(docs, doc)
+		default:
+			logEngineWarning("FluxDiscovery", -1, fmt.Sprintf("skipping document %d of %s, it isn't a YAML map", i+1, file))
+		}
+	}
+	return docs, nil
+}
+
+// key identifies a HelmRepository by namespace and name, as a sourceRef does
+func (repo helmRepository) key() string {
+	return repo.Namespace + "/" + repo.Name
+}
+
+// extractHelmRepository extracts the name and URL of a Flux HelmRepository
+func extractHelmRepository(doc map[string]any) helmRepository {
+	metadata, _ := doc["metadata"].(map[string]any)
+	spec, _ := doc["spec"].(map[string]any)
+	return helmRepository{
+		Name:      str(metadata["name"]),
+		Namespace: str(metadata["namespace"]),
+		URL:       str(spec["url"]),
+	}
+}
+
+// extractHelmReleaseChart maps a HelmRelease onto ChartRenderParams, resolving
+// the chart repository through the HelmRepository named in spec.chart.spec.sourceRef.
+// Like Flux, the sourceRef defaults to the namespace of the HelmRelease and the
+// release is named spec.releaseName, falling back to the HelmRelease's name.
+func extractHelmReleaseChart(doc map[string]any, env string, repositories map[string]helmRepository) (ChartRenderParams, error) {
+	metadata, _ := doc["metadata"].(map[string]any)
+	name := str(metadata["name"])
+	namespace := str(metadata["namespace"])
+
+	spec, _ := doc["spec"].(map[string]any)
+	chart, _ := spec["chart"].(map[string]any)
+	chartSpec, _ := chart["spec"].(map[string]any)
+	if chartSpec == nil {
+		return ChartRenderParams{}, fmt.Errorf("HelmRelease %s has no spec.chart.spec", name)
+	}
+
+	sourceRef, _ := chartSpec["sourceRef"].(map[string]any)
+	if kind := str(sourceRef["kind"]); kind != "HelmRepository" {
+		return ChartRenderParams{}, fmt.Errorf("HelmRelease %s uses unsupported sourceRef kind %q", name, kind)
+	}
+	ref := helmRepository{Namespace: str(sourceRef["namespace"]), Name: str(sourceRef["name"])}
+	if ref.Namespace == "" {
+		ref.Namespace = namespace
+	}
+	repo, found := repositories[ref.key()]
+	if !found {
+		return ChartRenderParams{}, fmt.Errorf("HelmRelease %s references unknown HelmRepository %s", name, ref.key())
+	}
+
+	if _, found := spec["valuesFrom"]; found {
+		logEngineWarning("FluxDiscovery", -1, fmt.Sprintf("HelmRelease %s uses valuesFrom, which cannot be resolved and is ignored", name))
+	}
+
+	params := ChartRenderParams{
+		Env:          env,
+		ChartName:    str(chartSpec["chart"]),
+		RepoURL:      repo.URL,
+		ChartVersion: str(chartSpec["version"]),
+		ReleaseName:  str(spec["releaseName"]),
+	}
+	if params.ReleaseName == "" {
+		params.ReleaseName = name
+	}
+
+	if values, ok := spec["values"].(map[string]any); ok && len(values) > 0 {
+		data, err := yaml.Marshal(values)
+		if err != nil {
+			return ChartRenderParams{}, fmt.Errorf("HelmRelease %s has unserialisable values: %w", name, err)
+		}
+		params.InlineValues = string(data)
+	}
+
+	return params, nil
+}
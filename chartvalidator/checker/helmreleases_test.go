@@ -0,0 +1,207 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleHelmRelease = `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: podinfo
+  namespace: flux-system
+spec:
+  url: https://stefanprodan.github.io/podinfo
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: podinfo
+  namespace: apps
+spec:
+  chart:
+    spec:
+      chart: podinfo
+      version: 6.5.4
+      sourceRef:
+        kind: HelmRepository
+        name: podinfo
+        namespace: flux-system
+  values:
+    replicaCount: 2
+`
+
+func TestFindChartsInHelmReleases(t *testing.T) {
+	envDir := t.TempDir()
+	createTempManifestFile(t, envDir, "staging/apps/podinfo.yaml", sampleHelmRelease)
+
+	charts, err := findChartsInHelmReleases(envDir, nil)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+
+	chart := charts[0]
+	assert.Equal(t, "staging", chart.Env)
+	assert.Equal(t, "podinfo", chart.ChartName)
+	assert.Equal(t, "6.5.4", chart.ChartVersion)
+	assert.Equal(t, "https://stefanprodan.github.io/podinfo", chart.RepoURL)
+	assert.Equal(t, "replicaCount: 2\n", chart.InlineValues)
+	assert.Empty(t, chart.BaseValuesFile)
+	assert.Empty(t, chart.ValuesOverride)
+}
+
+func TestFindChartsWithFluxSource(t *testing.T) {
+	envDir := t.TempDir()
+	createTempManifestFile(t, envDir, "staging/podinfo.yaml", sampleHelmRelease)
+
+	charts, err := findCharts(createTestContext(), DiscoveryOptions{Source: sourceFlux, EnvDir: envDir, SingleEnv: "staging"})
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+
+	_, err = findCharts(createTestContext(), DiscoveryOptions{Source: "unknown", EnvDir: envDir})
+	assert.Error(t, err)
+}
+
+func TestHelmReleaseWithUnknownRepositoryIsSkipped(t *testing.T) {
+	envDir := t.TempDir()
+	release := sampleHelmRelease[strings.Index(sampleHelmRelease, "---\n")+len("---\n"):]
+	createTempManifestFile(t, envDir, "staging/podinfo.yaml", release)
+
+	charts, err := findChartsInHelmReleases(envDir, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, charts)
+}
+
+func TestFluxDiscoverySkipsDocumentsThatArentMaps(t *testing.T) {
+	envDir := t.TempDir()
+	createTempManifestFile(t, envDir, "staging/podinfo.yaml", sampleHelmRelease)
+	createTempManifestFile(t, envDir, "staging/hosts.yaml", "- web.example.com\n- api.example.com\n")
+	createTempManifestFile(t, envDir, "staging/templated.yaml", "replicas: {{ .Values.replicas }\n---\nkind: ConfigMap\n")
+
+	charts, err := findChartsInHelmReleases(envDir, nil)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+}
+
+func TestFluxDiscoveryFailsOnHelmReleaseThatDoesntParse(t *testing.T) {
+	envDir := t.TempDir()
+	createTempManifestFile(t, envDir, "staging/podinfo.yaml", "kind: HelmRelease\nmetadata:\n  name: [podinfo\n")
+
+	_, err := findChartsInHelmReleases(envDir, nil)
+	assert.ErrorContains(t, err, "failed to parse HelmRelease in")
+}
+
+func TestRenderWithInlineValues(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	outputDir := t.TempDir()
+	engine := &ChartRenderingEngine{
+		outputDir: outputDir,
+		context:   createTestContext(),
+		executor:  mockExecutor,
+	}
+
+	chart := ChartRenderParams{
+		Env:          "staging",
+		ChartName:    "podinfo",
+		RepoURL:      "https://stefanprodan.github.io/podinfo",
+		ChartVersion: "6.5.4",
+		InlineValues: "replicaCount: 2\n",
+	}
+
+	_, err := engine.renderSingleChart(chart, 0)
+	assert.NoError(t, err)
+
+	args := mockExecutor.LastArgs
+	assert.Equal(t, []string{"template", "podinfo", "--release-name", "podinfo", "--repo", "https://stefanprodan.github.io/podinfo", "-f"}, args[:7])
+	assert.Equal(t, []string{"--version", "6.5.4", "--include-crds"}, args[8:])
+
+	values, err := os.ReadFile(args[7])
+	assert.NoError(t, err)
+	assert.Equal(t, chart.InlineValues, string(values))
+	assert.Equal(t, filepath.Join(outputDir, "values"), filepath.Dir(args[7]))
+}
+
+func TestHelmReleaseResolvesRepositoryByNamespace(t *testing.T) {
+	const releases = `apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: charts
+  namespace: team-a
+spec:
+  url: https://team-a.example.com/charts
+---
+apiVersion: source.toolkit.fluxcd.io/v1
+kind: HelmRepository
+metadata:
+  name: charts
+  namespace: team-b
+spec:
+  url: https://team-b.example.com/charts
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: web
+  namespace: team-a
+spec:
+  chart:
+    spec:
+      chart: web
+      version: 1.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: charts
+---
+apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: api
+  namespace: team-a
+spec:
+  releaseName: team-b-api
+  chart:
+    spec:
+      chart: api
+      version: 2.0.0
+      sourceRef:
+        kind: HelmRepository
+        name: charts
+        namespace: team-b
+`
+	envDir := t.TempDir()
+	createTempManifestFile(t, envDir, "staging/releases.yaml", releases)
+
+	charts, err := findChartsInHelmReleases(envDir, nil)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 2)
+
+	// The sourceRef defaults to the namespace of the HelmRelease
+	assert.Equal(t, "web", charts[0].ChartName)
+	assert.Equal(t, "https://team-a.example.com/charts", charts[0].RepoURL)
+	assert.Equal(t, "web", charts[0].ReleaseName, "Expected the release to be named after the HelmRelease")
+
+	assert.Equal(t, "api", charts[1].ChartName)
+	assert.Equal(t, "https://team-b.example.com/charts", charts[1].RepoURL)
+	assert.Equal(t, "team-b-api", charts[1].ReleaseName)
+}
+
+func TestHelmReleaseNameFallsBackToMetadataName(t *testing.T) {
+	envDir := t.TempDir()
+	release := strings.Replace(sampleHelmRelease, "  name: podinfo\n  namespace: apps", "  name: frontend\n  namespace: apps", 1)
+	createTempManifestFile(t, envDir, "staging/podinfo.yaml", release)
+
+	charts, err := findChartsInHelmReleases(envDir, nil)
+	assert.NoError(t, err)
+	assert.Len(t, charts, 1)
+	assert.Equal(t, "podinfo", charts[0].ChartName)
+	assert.Equal(t, "frontend", charts[0].ReleaseName)
+
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{outputDir: t.TempDir(), context: createTestContext(), executor: mockExecutor}
+	_, err = engine.renderSingleChart(charts[0], 0)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(mockExecutor.History[0], "helm template frontend --release-name podinfo "), "Expected helm to render release frontend, got %s", mockExecutor.History[0])
+}
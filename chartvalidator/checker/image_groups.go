@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// imageGroup is an image together with every chart whose check of it had the
+// same outcome, so an image shared by many charts is reported once
+type imageGroup struct {
+	// Result is the first of the grouped results
+	Result AppCheckResult
+	Charts []ChartRenderParams
+}
+
+// groupResultsByImage collapses the image results with the same image and
+// outcome into one group each, in the order the images were first reported.
+// Results without an image, e.g. render failures, are returned unchanged.
+func groupResultsByImage(results []AppCheckResult) ([]imageGroup, []AppCheckResult) {
+	var groups []imageGroup
+	var chartResults []AppCheckResult
+	index := map[string]int{}
+
+	for _, result := range results {
+		if result.Image == "" {
+			chartResults = append(chartResults, result)
+			continue
+		}
+		// Registry policies and mutable tag rules depend on the env, so the
+		// same image can pass for one chart and fail for another
+		key := strings.Join([]string{result.Image, result.OriginalImage, resultStatus(result), fmt.Sprint(result.Error)}, "\x00")
+		i, found := index[key]
+		if !found {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, imageGroup{Result: result})
+		}
+		groups[i].Charts = append(groups[i].Charts, result.Chart)
+	}
+	return groups, chartResults
+}
+
+// aggregateImageGroups summarizes grouped results, counting each group once
+// overall and once in every environment one of its charts belongs to
+func aggregateImageGroups(groups []imageGroup, chartResults []AppCheckResult) Summary {
+	summary := Aggregate(chartResults)
+	images := map[string]bool{}
+	for _, image := range summary.Images {
+		images[image] = true
+	}
+	charts := map[ChartRenderParams]bool{}
+	for _, result := range chartResults {
+		charts[chartKey(result.Chart)] = true
+	}
+
+	for _, group := range groups {
+		status := resultStatus(group.Result)
+		summary.add(status)
+		summary.Failures.add(group.Result)
+		summary.ImageChecks++
+
+		counted := map[string]bool{}
+		for _, chart := range group.Charts {
+			charts[chartKey(chart)] = true
+			if counted[chart.Env] {
+				continue
+			}
+			counted[chart.Env] = true
+			env := summary.Environments[chart.Env]
+			env.add(status)
+			summary.Environments[chart.Env] = env
+		}
+
+		if !images[group.Result.Image] {
+			images[group.Result.Image] = true
+			summary.Images = append(summary.Images, group.Result.Image)
+		}
+	}
+	sort.Strings(summary.Images)
+	summary.Charts = len(charts)
+	return summary
+}
+
+// printImageGroups writes the results without an image as usual, followed by
+// one line per image group naming the charts that reference the image
+func printImageGroups(w io.Writer, groups []imageGroup, chartResults []AppCheckResult) {
+	for _, result := range chartResults {
+		printResult(w, result)
+	}
+	for _, group := range groups {
+		result := group.Result
+		image := result.Image
+		if result.OriginalImage != "" {
+			image = fmt.Sprintf("%s (rewritten from %s)", result.Image, result.OriginalImage)
+		}
+
+		charts := make([]string, 0, len(group.Charts))
+		for _, chart := range group.Charts {
+			charts = append(charts, fmt.Sprintf("%s %s from env %s", chart.ChartName, chart.ChartVersion, chart.Env))
+		}
+
+		var outcome string
+		switch {
+		case result.AccessDenied:
+			outcome = fmt.Sprintf("✗ Access denied: %v", result.Error)
+		case result.Unverifiable:
+			outcome = fmt.Sprintf("✗ Could not verify: %v", result.Error)
+		case result.Warning:
+			outcome = fmt.Sprintf("⚠ Warning: %v", result.Error)
+		case result.Error != nil:
+			outcome = fmt.Sprintf("✗ Error: %v", result.Error)
+		default:
+			outcome = "✓ All checks passed"
+		}
+		fmt.Fprintf(w, ">>> image %s in %d chart(s) (%s): %s\n", image, len(group.Charts), strings.Join(charts, ", "), outcome)
+	}
+}
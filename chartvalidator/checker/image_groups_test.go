@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupResultsByImage(t *testing.T) {
+	web := ChartRenderParams{Env: "dev", ChartName: "web", ChartVersion: "1.0.0"}
+	api := ChartRenderParams{Env: "dev", ChartName: "api", ChartVersion: "2.0.0"}
+	worker := ChartRenderParams{Env: "prod", ChartName: "worker", ChartVersion: "3.0.0"}
+	broken := ChartRenderParams{Env: "prod", ChartName: "broken", ChartVersion: "0.1.0"}
+	results := []AppCheckResult{
+		{Chart: web, Image: "base:1.0"},
+		{Chart: api, Image: "base:1.0"},
+		{Chart: api, Image: "api:2.0", Error: fmt.Errorf("docker image does not exist: api:2.0")},
+		{Chart: worker, Image: "base:1.0"},
+		{Chart: broken, Error: fmt.Errorf("helm command failed")},
+	}
+
+	groups, chartResults := groupResultsByImage(results)
+
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "base:1.0", groups[0].Result.Image)
+	assert.Equal(t, []ChartRenderParams{web, api, worker}, groups[0].Charts)
+	assert.Equal(t, []ChartRenderParams{api}, groups[1].Charts)
+	assert.Equal(t, []AppCheckResult{results[4]}, chartResults)
+
+	var out bytes.Buffer
+	printImageGroups(&out, groups, chartResults)
+	assert.Equal(t, ">>> chart broken 0.1.0 from env prod with image : ✗ Error: helm command failed\n"+
+		">>> image base:1.0 in 3 chart(s) (web 1.0.0 from env dev, api 2.0.0 from env dev, worker 3.0.0 from env prod): ✓ All checks passed\n"+
+		">>> image api:2.0 in 1 chart(s) (api 2.0.0 from env dev): ✗ Error: docker image does not exist: api:2.0\n", out.String())
+
+	summary := aggregateImageGroups(groups, chartResults)
+	assert.Equal(t, StatusCounts{Passed: 1, Failed: 2}, summary.StatusCounts)
+	assert.Equal(t, StatusCounts{Passed: 1, Failed: 1}, summary.Environments["dev"])
+	assert.Equal(t, StatusCounts{Passed: 1, Failed: 1}, summary.Environments["prod"])
+	assert.Equal(t, []string{"api:2.0", "base:1.0"}, summary.Images)
+}
+
+func TestGroupResultsByImageKeepsDifferentOutcomesApart(t *testing.T) {
+	results := []AppCheckResult{
+		{Chart: ChartRenderParams{Env: "dev", ChartName: "web"}, Image: "redis:latest", Warning: true, Error: fmt.Errorf("mutable tag in image redis:latest")},
+		{Chart: ChartRenderParams{Env: "prod", ChartName: "web"}, Image: "redis:latest", Error: fmt.Errorf("mutable tag in image redis:latest")},
+	}
+
+	groups, _ := groupResultsByImage(results)
+
+	assert.Len(t, groups, 2, "Expected a warning and a failure of the same image to be reported separately")
+}
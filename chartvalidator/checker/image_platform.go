@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// imagePlatform is an os/architecture[/variant] an image must be available for
+type imagePlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (platform imagePlatform) String() string {
+	text := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		text += "/" + platform.Variant
+	}
+	return text
+}
+
+// parsePlatform parses a -require-platform value such as linux/arm64 or linux/arm/v7
+func parsePlatform(text string) (*imagePlatform, error) {
+	parts := strings.Split(text, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform %q, expected os/architecture[/variant], e.g. linux/arm64", text)
+	}
+	platform := &imagePlatform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// matches reports whether an image's platform satisfies the required one. A
+// variant is only compared when the required platform names one.
+func (platform imagePlatform) matches(other imagePlatform) bool {
+	return platform.OS == other.OS && platform.Architecture == other.Architecture &&
+		(platform.Variant == "" || platform.Variant == other.Variant)
+}
+
+// inspectedManifest is the part of the registry tool's output naming the
+// image's platforms, the manifest list or OCI index of a multi-platform image
+type inspectedManifest struct {
+	Manifests []struct {
+		Platform imagePlatform `json:"platform"`
+	} `json:"manifests"`
+}
+
+// imagePlatforms lists the platforms named by a registry tool's inspect output
+func imagePlatforms(output []byte) ([]imagePlatform, error) {
+	var manifest inspectedManifest
+	if err := json.Unmarshal(output, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	var platforms []imagePlatform
+	for _, entry := range manifest.Manifests {
+		platforms = append(platforms, entry.Platform)
+	}
+	return platforms, nil
+}
+
+// checkImagePlatform fails an image whose inspect output has no manifest for
+// the required platform, including single manifests that don't name one
+func checkImagePlatform(image string, output []byte, required imagePlatform) error {
+	platforms, err := imagePlatforms(output)
+	if err != nil {
+		return fmt.Errorf("cannot check the platforms of %s: %w", image, err)
+	}
+	if len(platforms) == 0 {
+		return fmt.Errorf("image %s is not a multi-platform manifest list, so %s cannot be confirmed", image, required)
+	}
+	var available []string
+	for _, platform := range platforms {
+		if required.matches(platform) {
+			return nil
+		}
+		available = append(available, platform.String())
+	}
+	return fmt.Errorf("image %s has no %s manifest, only %s", image, required, strings.Join(available, ", "))
+}
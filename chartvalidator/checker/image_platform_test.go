@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// multiPlatformManifest is docker manifest inspect output for a manifest list
+const multiPlatformManifest = `{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+  "manifests": [
+    {"digest": "sha256:aaa", "platform": {"architecture": "amd64", "os": "linux"}},
+    {"digest": "sha256:bbb", "platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}},
+    {"digest": "sha256:ccc", "platform": {"architecture": "arm", "os": "linux", "variant": "v7"}}
+  ]
+}`
+
+func TestParsePlatform(t *testing.T) {
+	platform, err := parsePlatform("linux/arm/v7")
+	assert.NoError(t, err)
+	assert.Equal(t, &imagePlatform{OS: "linux", Architecture: "arm", Variant: "v7"}, platform)
+
+	for _, text := range []string{"linux", "linux/", "/arm64", "linux/arm/v7/extra"} {
+		_, err := parsePlatform(text)
+		assert.Error(t, err, text)
+	}
+}
+
+func TestCheckImagePlatform(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		platform      imagePlatform
+		expectedError string
+	}{
+		{
+			name:     "manifest list has platform",
+			output:   multiPlatformManifest,
+			platform: imagePlatform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:     "manifest list has platform variant",
+			output:   multiPlatformManifest,
+			platform: imagePlatform{OS: "linux", Architecture: "arm", Variant: "v7"},
+		},
+		{
+			name:          "manifest list lacks platform",
+			output:        multiPlatformManifest,
+			platform:      imagePlatform{OS: "linux", Architecture: "s390x"},
+			expectedError: "image app:1.0 has no linux/s390x manifest, only linux/amd64, linux/arm64/v8, linux/arm/v7",
+		},
+		{
+			name:          "manifest list lacks platform variant",
+			output:        multiPlatformManifest,
+			platform:      imagePlatform{OS: "linux", Architecture: "arm", Variant: "v6"},
+			expectedError: "image app:1.0 has no linux/arm/v6 manifest",
+		},
+		{
+			name:     "skopeo inspect --raw OCI index",
+			output:   `{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [{"digest": "sha256:aaa", "platform": {"architecture": "amd64", "os": "linux"}}, {"digest": "sha256:bbb", "platform": {"architecture": "arm64", "os": "linux"}}]}`,
+			platform: imagePlatform{OS: "linux", Architecture: "arm64"},
+		},
+		{
+			name:          "single manifest",
+			output:        `{"schemaVersion": 2, "config": {"digest": "sha256:aaa"}}`,
+			platform:      imagePlatform{OS: "linux", Architecture: "arm64"},
+			expectedError: "image app:1.0 is not a multi-platform manifest list, so linux/arm64 cannot be confirmed",
+		},
+		{
+			name:          "unparseable output",
+			output:        "not json",
+			platform:      imagePlatform{OS: "linux", Architecture: "arm64"},
+			expectedError: "cannot check the platforms of app:1.0: failed to parse manifest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkImagePlatform("app:1.0", []byte(tt.output), tt.platform)
+
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestDockerValidationRequiresPlatform(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(multiPlatformManifest)
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.platform = &imagePlatform{OS: "linux", Architecture: "s390x"}
+
+	result := engine.validateSingleDockerImage(createTestChart(), "app:1.0", 0)
+
+	assert.True(t, result.Exists)
+	assert.Error(t, result.Error)
+	assert.Contains(t, result.Error.Error(), "has no linux/s390x manifest")
+
+	engine.platform = &imagePlatform{OS: "linux", Architecture: "arm64"}
+	result = engine.validateSingleDockerImage(createTestChart(), "app:2.0", 0)
+
+	assert.True(t, result.Exists)
+	assert.NoError(t, result.Error)
+}
+
+func TestDockerValidationRequiresPlatformWithSkopeo(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(multiPlatformManifest)
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.registryTool = skopeoRegistryTool{}
+
+	engine.validateSingleDockerImage(createTestChart(), "app:1.0", 0)
+	assert.Equal(t, []string{"inspect", "docker://app:1.0"}, mockExecutor.LastArgs)
+
+	// Without --raw skopeo only describes the image for the host platform
+	engine.platform = &imagePlatform{OS: "linux", Architecture: "arm64"}
+	result := engine.validateSingleDockerImage(createTestChart(), "app:2.0", 0)
+
+	assert.Equal(t, []string{"inspect", "--raw", "docker://app:2.0"}, mockExecutor.LastArgs)
+	assert.True(t, result.Exists)
+	assert.NoError(t, result.Error)
+}
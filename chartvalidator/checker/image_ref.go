@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// imageRef is an image reference split into its components, as written:
+// Registry is empty for Docker Hub images that don't name it and Tag is empty
+// when the reference has none, e.g. for host:5000/team/app@sha256:abc
+type imageRef struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageRef splits an image reference of the form
+// [registry/]repository[:tag][@digest]. The first path segment is only taken
+// as the registry when it has a dot or a port, or is localhost, which is how
+// docker tells host:5000/repo apart from a Docker Hub namespace.
+func parseImageRef(image string) (imageRef, error) {
+	var ref imageRef
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Digest = name[:i], name[i+1:]
+		algorithm, hex, found := strings.Cut(ref.Digest, ":")
+		if !found || algorithm == "" || hex == "" {
+			return imageRef{}, fmt.Errorf("invalid image reference %q: digest must be algorithm:hex, e.g. sha256:...", image)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:i], name[i+1:]
+		if ref.Tag == "" {
+			return imageRef{}, fmt.Errorf("invalid image reference %q: empty tag", image)
+		}
+	}
+	if registry, repository, found := strings.Cut(name, "/"); found && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		ref.Registry, name = registry, repository
+	}
+	if name == "" || strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//") {
+		return imageRef{}, fmt.Errorf("invalid image reference %q: empty repository", image)
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// checkImageDigest returns an error for an image that isn't pinned to a
+// digest, so a later push to its tag can't change what gets deployed
+func checkImageDigest(image string) error {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return err
+	}
+	if ref.Digest == "" {
+		return fmt.Errorf("image %s is not pinned to a digest: reference it as %s@sha256:... for reproducible deploys", image, image)
+	}
+	return nil
+}
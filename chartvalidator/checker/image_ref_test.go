@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseImageRef(t *testing.T) {
+	digest := "sha256:0d3d2b2f0a9e6cbd4a4c2cf16a8d5f8d8f3b38a3e6a16fcd1e3c6cbb4f0c2a11"
+
+	tests := []struct {
+		image    string
+		expected imageRef
+	}{
+		{"nginx", imageRef{Repository: "nginx"}},
+		{"nginx:1.25", imageRef{Repository: "nginx", Tag: "1.25"}},
+		{"bitnami/redis:7.2", imageRef{Repository: "bitnami/redis", Tag: "7.2"}},
+		{"nginx@" + digest, imageRef{Repository: "nginx", Digest: digest}},
+		{"nginx:1.25@" + digest, imageRef{Repository: "nginx", Tag: "1.25", Digest: digest}},
+		{"ghcr.io/org/app:v1", imageRef{Registry: "ghcr.io", Repository: "org/app", Tag: "v1"}},
+		{"ghcr.io/org/team/app", imageRef{Registry: "ghcr.io", Repository: "org/team/app"}},
+		{"host:5000/repo", imageRef{Registry: "host:5000", Repository: "repo"}},
+		{"host:5000/repo:tag", imageRef{Registry: "host:5000", Repository: "repo", Tag: "tag"}},
+		{"host:5000/team/repo:tag@" + digest, imageRef{Registry: "host:5000", Repository: "team/repo", Tag: "tag", Digest: digest}},
+		{"localhost/app:dev", imageRef{Registry: "localhost", Repository: "app", Tag: "dev"}},
+		{"localhost:5000/app", imageRef{Registry: "localhost:5000", Repository: "app"}},
+		{"docker.io/library/nginx:1.25", imageRef{Registry: "docker.io", Repository: "library/nginx", Tag: "1.25"}},
+		{"10.0.0.1:5000/app@" + digest, imageRef{Registry: "10.0.0.1:5000", Repository: "app", Digest: digest}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			ref, err := parseImageRef(tt.image)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ref)
+		})
+	}
+}
+
+func TestParseImageRefErrors(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"", "empty repository"},
+		{"ghcr.io/", "empty repository"},
+		{"ghcr.io//app", "empty repository"},
+		{":1.0", "empty repository"},
+		{"nginx:", "empty tag"},
+		{"host:5000/repo:", "empty tag"},
+		{"nginx@", "digest must be algorithm:hex"},
+		{"nginx@sha256", "digest must be algorithm:hex"},
+		{"nginx@sha256:", "digest must be algorithm:hex"},
+		{"nginx@:abc", "digest must be algorithm:hex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			_, err := parseImageRef(tt.image)
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestCheckImageDigest(t *testing.T) {
+	assert.NoError(t, checkImageDigest("host:5000/repo@sha256:abc"))
+	assert.NoError(t, checkImageDigest("nginx:1.25@sha256:abc"))
+	assert.EqualError(t, checkImageDigest("host:5000/repo:1.0"),
+		"image host:5000/repo:1.0 is not pinned to a digest: reference it as host:5000/repo:1.0@sha256:... for reproducible deploys")
+}
+
+func TestAppCheckerWarnsAboutImagesWithoutDigest(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+    - name: proxy
+      image: envoy@sha256:abc
+`)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:     t.TempDir(),
+		RequireDigest: true,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 2)
+	for _, result := range results {
+		if result.Image == "nginx:1.25" {
+			assert.True(t, result.Warning)
+			assert.Contains(t, result.Error.Error(), "is not pinned to a digest")
+		} else {
+			assert.Equal(t, "envoy@sha256:abc", result.Image)
+			assert.NoError(t, result.Error)
+		}
+	}
+}
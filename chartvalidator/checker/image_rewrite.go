@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// imageRewriteRule replaces the parts of an image reference matching Match with Replace
+type imageRewriteRule struct {
+	Match   string `yaml:"match"`
+	Replace string `yaml:"replace"`
+
+	pattern *regexp.Regexp
+}
+
+// imageRewriteFile is the layout of the -image-rewrite mapping file:
+//
+//	rewrites:
+//	  - match: "^docker.io/"
+//	    replace: "mirror.internal/"
+type imageRewriteFile struct {
+	Rewrites []imageRewriteRule `yaml:"rewrites"`
+}
+
+// loadImageRewriteRules reads and compiles the rewrite rules from a mapping file
+func loadImageRewriteRules(path string) ([]imageRewriteRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image rewrite file: %w", err)
+	}
+
+	var file imageRewriteFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse image rewrite file %s: %w", path, err)
+	}
+
+	for i := range file.Rewrites {
+		pattern, err := regexp.Compile(file.Rewrites[i].Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid image rewrite pattern %q: %w", file.Rewrites[i].Match, err)
+		}
+		file.Rewrites[i].pattern = pattern
+	}
+	return file.Rewrites, nil
+}
+
+// rewriteImage applies the first rule matching the image. Images matching no rule are returned unchanged.
+func rewriteImage(rules []imageRewriteRule, image string) string {
+	for _, rule := range rules {
+		if rule.pattern.MatchString(image) {
+			return rule.pattern.ReplaceAllString(image, rule.Replace)
+		}
+	}
+	return image
+}
+
+// parseRegistryMirrors parses -registry-mirror values into a map from registry
+// host to its mirror. A value is registry=mirror, or just the mirror, which
+// then mirrors docker.io.
+func parseRegistryMirrors(values []string) (map[string]string, error) {
+	mirrors := map[string]string{}
+	for _, value := range values {
+		registry, mirror, found := strings.Cut(value, "=")
+		if !found {
+			registry, mirror = "docker.io", value
+		}
+		if registry == "index.docker.io" {
+			registry = "docker.io"
+		}
+		mirror = strings.TrimSuffix(mirror, "/")
+		if registry == "" || mirror == "" {
+			return nil, fmt.Errorf("invalid registry mirror %q, expected registry=mirror or a mirror of docker.io", value)
+		}
+		if existing, ok := mirrors[registry]; ok && existing != mirror {
+			return nil, fmt.Errorf("registry %s is mirrored to both %s and %s", registry, existing, mirror)
+		}
+		mirrors[registry] = mirror
+	}
+	return mirrors, nil
+}
+
+// mirrorImage replaces the registry of an image with its mirror, e.g.
+// nginx:1.20 with a docker.io mirror becomes mirror.internal/library/nginx:1.20.
+// Images from registries without a mirror are returned unchanged.
+func mirrorImage(mirrors map[string]string, image string) string {
+	mirror, ok := mirrors[imageRegistry(image)]
+	if !ok {
+		return image
+	}
+	_, path, _ := strings.Cut(normalizeImageReference(image), "/")
+	return mirror + "/" + path
+}
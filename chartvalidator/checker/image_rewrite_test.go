@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const sampleImageRewrites = `rewrites:
+  - match: "^docker.io/"
+    replace: "mirror.internal/"
+  - match: "^(nginx|redis):"
+    replace: "mirror.internal/library/$1:"
+`
+
+func TestRewriteImage(t *testing.T) {
+	tempDir := t.TempDir()
+	rulesFile := createTempManifestFile(t, tempDir, "rewrites.yaml", sampleImageRewrites)
+
+	rules, err := loadImageRewriteRules(rulesFile)
+	assert.NoError(t, err)
+	assert.Len(t, rules, 2)
+
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{image: "docker.io/library/postgres:13", expected: "mirror.internal/library/postgres:13"},
+		{image: "nginx:1.20", expected: "mirror.internal/library/nginx:1.20"},
+		{image: "ghcr.io/example/app:v1.0.0", expected: "ghcr.io/example/app:v1.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, rewriteImage(rules, tt.image))
+		})
+	}
+}
+
+func TestLoadImageRewriteRulesInvalidPattern(t *testing.T) {
+	rulesFile := createTempManifestFile(t, t.TempDir(), "rewrites.yaml", "rewrites:\n  - match: \"([\"\n    replace: x\n")
+
+	_, err := loadImageRewriteRules(rulesFile)
+	assert.Error(t, err)
+}
+
+func TestAppCheckerValidatesRewrittenImage(t *testing.T) {
+	rulesFile := createTempManifestFile(t, t.TempDir(), "rewrites.yaml", sampleImageRewrites)
+	rules, err := loadImageRewriteRules(rulesFile)
+	assert.NoError(t, err)
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(sampleManifests["pod_sample"])
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:     t.TempDir(),
+		ImageRewrites: rules,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	assert.Equal(t, "mirror.internal/library/nginx:1.14.2", results[0].Image)
+	assert.Equal(t, "nginx:1.14.2", results[0].OriginalImage)
+	assert.Contains(t, mockExecutor.History, "docker manifest inspect mirror.internal/library/nginx:1.14.2")
+}
+
+func TestMirrorImage(t *testing.T) {
+	mirrors, err := parseRegistryMirrors([]string{"mirror.internal", "ghcr.io=ghcr-mirror.internal:5000/"})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx:1.20", "mirror.internal/library/nginx:1.20"},
+		{"nginx", "mirror.internal/library/nginx:latest"},
+		{"bitnami/redis:7.2", "mirror.internal/bitnami/redis:7.2"},
+		{"docker.io/library/nginx:1.20", "mirror.internal/library/nginx:1.20"},
+		{"index.docker.io/nginx@sha256:abc", "mirror.internal/library/nginx@sha256:abc"},
+		{"ghcr.io/org/app:v1", "ghcr-mirror.internal:5000/org/app:v1"},
+		{"quay.io/org/app:v1", "quay.io/org/app:v1"},
+		{"mirror.internal/library/nginx:1.20", "mirror.internal/library/nginx:1.20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mirrorImage(mirrors, tt.image))
+		})
+	}
+}
+
+func TestParseRegistryMirrorsErrors(t *testing.T) {
+	_, err := parseRegistryMirrors([]string{"=mirror.internal"})
+	assert.Error(t, err)
+	_, err = parseRegistryMirrors([]string{"ghcr.io="})
+	assert.Error(t, err)
+	_, err = parseRegistryMirrors([]string{"mirror.internal", "index.docker.io=other.internal"})
+	assert.EqualError(t, err, "registry docker.io is mirrored to both mirror.internal and other.internal")
+}
+
+func TestAppCheckerValidatesImageThroughRegistryMirror(t *testing.T) {
+	mirrors, err := parseRegistryMirrors([]string{"mirror.internal"})
+	assert.NoError(t, err)
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(sampleManifests["pod_sample"])
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:       t.TempDir(),
+		RegistryMirrors: mirrors,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.NoError(t, results[0].Error)
+	assert.Equal(t, "mirror.internal/library/nginx:1.14.2", results[0].Image)
+	assert.Equal(t, "nginx:1.14.2", results[0].OriginalImage)
+	assert.Contains(t, mockExecutor.History, "docker manifest inspect mirror.internal/library/nginx:1.14.2")
+}
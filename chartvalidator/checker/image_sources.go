@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImageSource is where a rendered manifest references an image
+type ImageSource struct {
+	Image string `json:"image"`
+	// Kind and Resource are the kind and name of the resource using the image
+	Kind     string `json:"kind,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	// Container names the container, step or volume using the image, and
+	// Field what it is, e.g. container, initContainer, volume or step. For
+	// custom image paths Field is the path and Container is empty.
+	Container string `json:"container,omitempty"`
+	Field     string `json:"field,omitempty"`
+}
+
+// String describes the source as e.g. "container web in Deployment api", or
+// "spec.image in Widget gizmo" for a custom image path
+func (source ImageSource) String() string {
+	resource := strings.TrimSpace(source.Kind + " " + source.Resource)
+	var user string
+	switch {
+	case source.Container != "":
+		field := source.Field
+		if field == "" {
+			field = "container"
+		}
+		user = fmt.Sprintf("%s %s", field, source.Container)
+	case source.Field != "":
+		user = source.Field
+	default:
+		return resource
+	}
+	if resource == "" {
+		return user
+	}
+	return fmt.Sprintf("%s in %s", user, resource)
+}
+
+// imageNames returns the image of every source, in order
+func imageNames(sources []ImageSource) []string {
+	images := make([]string, 0, len(sources))
+	for _, source := range sources {
+		images = append(images, source.Image)
+	}
+	return images
+}
+
+// sourcesOf returns the sources that reference image
+func sourcesOf(sources []ImageSource, image string) []ImageSource {
+	var matching []ImageSource
+	for _, source := range sources {
+		if source.Image == image {
+			matching = append(matching, source)
+		}
+	}
+	return matching
+}
+
+// describeImageSources joins the descriptions of every source for reports
+func describeImageSources(sources []ImageSource) string {
+	descriptions := make([]string, 0, len(sources))
+	for _, source := range sources {
+		if description := source.String(); description != "" {
+			descriptions = append(descriptions, description)
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
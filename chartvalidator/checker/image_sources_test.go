@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractImageSourcesFromManifest(t *testing.T) {
+	sources, err := extractImageSourcesFromManifest(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: api-migrations:1.0
+      containers:
+        - name: api
+          image: api:1.0
+      volumes:
+        - name: models
+          image:
+            reference: models:2024
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ImageSource{
+		{Image: "api:1.0", Kind: "Deployment", Resource: "api", Container: "api", Field: "container"},
+		{Image: "api-migrations:1.0", Kind: "Deployment", Resource: "api", Container: "migrate", Field: "initContainer"},
+		{Image: "models:2024", Kind: "Deployment", Resource: "api", Container: "models", Field: "volume"},
+	}, sources)
+}
+
+func TestExtractImageSourcesNamesListItems(t *testing.T) {
+	sources, err := extractImageSourcesFromManifest(`apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: web
+  spec:
+    containers:
+    - name: server
+      image: nginx:1.14.2
+`, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ImageSource{{Image: "nginx:1.14.2", Kind: "Pod", Resource: "web", Container: "server", Field: "container"}}, sources)
+}
+
+func TestImageExtractionKeepsEverySourceOfAnImage(t *testing.T) {
+	engine := createImageExtractionEngine()
+	engine.errorChan = make(chan ErrorResult, 10)
+	engine.Start(1)
+
+	manifestPath := createTempManifestFile(t, t.TempDir(), "manifest.yaml", imagesOfManifest)
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	assert.Len(t, results, 3, "Expected one result per unique image")
+	for _, result := range results {
+		if result.Image != "nginx:1.25" {
+			continue
+		}
+		assert.Equal(t, []ImageSource{
+			{Image: "nginx:1.25", Kind: "Deployment", Resource: "web", Container: "web", Field: "container"},
+			{Image: "nginx:1.25", Kind: "Pod", Resource: "debug", Container: "web", Field: "container"},
+		}, result.Sources)
+	}
+}
+
+func TestImageSourceString(t *testing.T) {
+	assert.Equal(t, "container web in Deployment api", ImageSource{Kind: "Deployment", Resource: "api", Container: "web", Field: "container"}.String())
+	assert.Equal(t, "initContainer migrate in Job db", ImageSource{Kind: "Job", Resource: "db", Container: "migrate", Field: "initContainer"}.String())
+	assert.Equal(t, "container web", ImageSource{Container: "web"}.String())
+	assert.Equal(t, "Widget gizmo", ImageSource{Kind: "Widget", Resource: "gizmo"}.String())
+	assert.Equal(t, "spec.image in Widget gizmo", ImageSource{Kind: "Widget", Resource: "gizmo", Field: "spec.image"}.String())
+}
+
+func TestPrintResultNamesWhereAFailedImageIsUsed(t *testing.T) {
+	var out bytes.Buffer
+	printResult(&out, AppCheckResult{
+		Chart: createTestChart(),
+		Image: "nginx:1.25",
+		Sources: []ImageSource{
+			{Image: "nginx:1.25", Kind: "Deployment", Resource: "web", Container: "web", Field: "container"},
+			{Image: "nginx:1.25", Kind: "Pod", Resource: "debug", Container: "web", Field: "container"},
+		},
+		Error: fmt.Errorf("docker image does not exist: nginx:1.25"),
+	})
+
+	assert.Equal(t, ">>> chart test-chart 1.0.0 from env development with image nginx:1.25 referenced by container web in Deployment web, container web in Pod debug: ✗ Error: docker image does not exist: nginx:1.25\n", out.String())
+}
+
+func TestAppCheckerCarriesImageSourcesToResults(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(imagesOfManifest)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{OutputDir: t.TempDir()})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	sources := map[string][]ImageSource{}
+	for _, result := range results {
+		sources[result.Image] = result.Sources
+	}
+	assert.Equal(t, []ImageSource{
+		{Image: "registry.example.com/migrate:1.0", Kind: "Deployment", Resource: "web", Container: "migrate", Field: "initContainer"},
+	}, sources["registry.example.com/migrate:1.0"])
+	assert.Len(t, sources["nginx:1.25"], 2)
+}
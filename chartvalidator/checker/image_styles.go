@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// normalizeImageReference expands a Docker image reference to its fully
+// qualified form, e.g. nginx:1.20 becomes docker.io/library/nginx:1.20
+func normalizeImageReference(image string) string {
+	name, suffix := image, ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, suffix = name[:i], name[i:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, suffix = name[:i], name[i:]+suffix
+	}
+	if suffix == "" {
+		suffix = ":latest"
+	}
+
+	registry, path, found := strings.Cut(name, "/")
+	if !found || (!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		registry, path = "docker.io", name
+	}
+	if registry == "index.docker.io" {
+		registry = "docker.io"
+	}
+	if registry == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return registry + "/" + path + suffix
+}
+
+// imageStyleInconsistency lists the different ways one image is referenced,
+// mapping each reference as written to the charts using it
+type imageStyleInconsistency struct {
+	Image      string
+	References map[string][]string
+}
+
+// findImageStyleInconsistencies returns the images referenced in more than
+// one style across the results, e.g. with and without their registry prefix,
+// sorted by image. References are taken as written in the charts, before any rewrite.
+func findImageStyleInconsistencies(results []AppCheckResult) []imageStyleInconsistency {
+	references := map[string]map[string][]string{}
+	for _, result := range results {
+		image := result.Image
+		if result.OriginalImage != "" {
+			image = result.OriginalImage
+		}
+		if image == "" || result.Skipped {
+			continue
+		}
+
+		canonical := normalizeImageReference(image)
+		if references[canonical] == nil {
+			references[canonical] = map[string][]string{}
+		}
+		chart := result.Chart.Env + "/" + result.Chart.ChartName
+		if !slices.Contains(references[canonical][image], chart) {
+			references[canonical][image] = append(references[canonical][image], chart)
+		}
+	}
+
+	var out []imageStyleInconsistency
+	for canonical, styles := range references {
+		if len(styles) < 2 {
+			continue
+		}
+		for _, charts := range styles {
+			sort.Strings(charts)
+		}
+		out = append(out, imageStyleInconsistency{Image: canonical, References: styles})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Image < out[j].Image })
+	return out
+}
+
+// printImageStyleReport writes the images referenced in inconsistent styles, if any
+func printImageStyleReport(w io.Writer, inconsistencies []imageStyleInconsistency) {
+	if len(inconsistencies) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Images referenced in inconsistent styles (consider using one form across charts):")
+	for _, inconsistency := range inconsistencies {
+		fmt.Fprintf(w, "  %s is referenced as:\n", inconsistency.Image)
+		references := make([]string, 0, len(inconsistency.References))
+		for reference := range inconsistency.References {
+			references = append(references, reference)
+		}
+		sort.Strings(references)
+		for _, reference := range references {
+			fmt.Fprintf(w, "    %s in %s\n", reference, strings.Join(inconsistency.References[reference], ", "))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeImageReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "docker.io/library/nginx:latest"},
+		{"nginx:1.20", "docker.io/library/nginx:1.20"},
+		{"library/nginx:1.20", "docker.io/library/nginx:1.20"},
+		{"docker.io/library/nginx:1.20", "docker.io/library/nginx:1.20"},
+		{"index.docker.io/nginx:1.20", "docker.io/library/nginx:1.20"},
+		{"bitnami/redis:7.2", "docker.io/bitnami/redis:7.2"},
+		{"ghcr.io/org/app:v1", "ghcr.io/org/app:v1"},
+		{"localhost/app", "localhost/app:latest"},
+		{"localhost:5000/app:v1", "localhost:5000/app:v1"},
+		{"nginx@sha256:abc123", "docker.io/library/nginx@sha256:abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeImageReference(tt.image))
+		})
+	}
+}
+
+func TestImageStyleInconsistencies(t *testing.T) {
+	web := createTestChart()
+	web.ChartName = "web"
+	api := createTestChart()
+	api.ChartName = "api"
+
+	inconsistencies := findImageStyleInconsistencies([]AppCheckResult{
+		{Chart: web, Image: "nginx:1.20"},
+		{Chart: api, Image: "docker.io/library/nginx:1.20"},
+		{Chart: api, Image: "ghcr.io/org/api:v1"},
+		{Chart: web, Image: "ghcr.io/org/api:v1"},
+	})
+	assert.Equal(t, []imageStyleInconsistency{{
+		Image: "docker.io/library/nginx:1.20",
+		References: map[string][]string{
+			"nginx:1.20":                   {"development/web"},
+			"docker.io/library/nginx:1.20": {"development/api"},
+		},
+	}}, inconsistencies)
+
+	var out bytes.Buffer
+	printImageStyleReport(&out, inconsistencies)
+	assert.Equal(t, `Images referenced in inconsistent styles (consider using one form across charts):
+  docker.io/library/nginx:1.20 is referenced as:
+    docker.io/library/nginx:1.20 in development/api
+    nginx:1.20 in development/web
+`, out.String())
+}
+
+func TestImageStyleReportEmptyWhenConsistent(t *testing.T) {
+	results := []AppCheckResult{
+		{Chart: createTestChart(), Image: "nginx:1.20"},
+		{Chart: createTestChart(), Image: "nginx:1.20"},
+	}
+
+	var out bytes.Buffer
+	printImageStyleReport(&out, findImageStyleInconsistencies(results))
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runImagesOfCommand(args []string) {
+	fs := flag.NewFlagSet("images-of", flag.ExitOnError)
+
+	var (
+		file        = fs.String("file", "", "Rendered manifest file to extract images from.")
+		extractOnly = fs.Bool("extract-only", false, "Only list the images, do not check that they exist in the registry.")
+		verbose     = fs.Bool("v", false, "Enable verbose logging.")
+	)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: run-manifest-checks images-of -file <yaml> [flags]")
+		fmt.Println("")
+		fmt.Println("Lists the Docker images referenced by a single rendered manifest file and validates that each exists in the registry.")
+		fmt.Println("")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *file == "" {
+		fmt.Fprintln(os.Stderr, "Error: -file is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	setVerboseLogging(*verbose)
+
+	ok, err := imagesOfFile(context.Background(), &RealCommandExecutor{}, *file, !*extractOnly, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing images: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// imagesOfFile prints the images found in a rendered manifest file, validating
+// each one with docker when validate is set. It reports whether all images exist.
+func imagesOfFile(ctx context.Context, executor CommandExecutor, file string, validate bool, w io.Writer) (bool, error) {
+	if _, err := os.Stat(file); err != nil {
+		return false, err
+	}
+
+	extractor := ImageExtractionEngine{name: "ImageExtractor"}
+	sources, err := extractor.extractImagesFromFile(file, -1)
+	if err != nil {
+		return false, err
+	}
+	images := removeDuplicates(imageNames(sources))
+
+	if !validate {
+		for _, image := range images {
+			fmt.Fprintln(w, image)
+		}
+		return true, nil
+	}
+
+	// Print in extraction order so the output is stable
+	return printImageValidation(w, images, validateImages(ctx, executor, images)), nil
+}
+
+// printImageValidation prints whether each image exists, in the given order,
+// and reports whether all of them do
+func printImageValidation(w io.Writer, images []string, results map[string]DockerImageValidationResult) bool {
+	allExist := true
+	for _, image := range images {
+		result := results[image]
+		switch {
+		case result.AccessDenied:
+			fmt.Fprintf(w, "%s: ✗ Access denied: %v\n", image, result.Error)
+			allExist = false
+		case result.Error != nil:
+			fmt.Fprintf(w, "%s: ✗ Error: %v\n", image, result.Error)
+			allExist = false
+		case !result.Exists:
+			fmt.Fprintf(w, "%s: ✗ does not exist\n", image)
+			allExist = false
+		default:
+			fmt.Fprintf(w, "%s: ✓ exists\n", image)
+		}
+	}
+	return allExist
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const imagesOfManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: registry.example.com/migrate:1.0
+      containers:
+        - name: web
+          image: nginx:1.25
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - name: shell
+      image: busybox:1.36
+    - name: web
+      image: nginx:1.25
+`
+
+func TestImagesOfFile(t *testing.T) {
+	manifestFile := createTempManifestFile(t, t.TempDir(), "manifest.yaml", imagesOfManifest)
+
+	mockExecutor := createMockExecutor()
+	var out bytes.Buffer
+	ok, err := imagesOfFile(createTestContext(), mockExecutor, manifestFile, true, &out)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "nginx:1.25: ✓ exists\nregistry.example.com/migrate:1.0: ✓ exists\nbusybox:1.36: ✓ exists\n", out.String())
+	assert.ElementsMatch(t, []string{
+		"docker manifest inspect nginx:1.25",
+		"docker manifest inspect registry.example.com/migrate:1.0",
+		"docker manifest inspect busybox:1.36",
+	}, mockExecutor.History)
+}
+
+func TestImagesOfFileReportsMissingImages(t *testing.T) {
+	manifestFile := createTempManifestFile(t, t.TempDir(), "manifest.yaml", imagesOfManifest)
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("manifest unknown")
+	mockExecutor.Error = errors.New("exit status 1")
+	var out bytes.Buffer
+	ok, err := imagesOfFile(createTestContext(), mockExecutor, manifestFile, true, &out)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Contains(t, out.String(), "busybox:1.36: ✗ does not exist\n")
+}
+
+func TestImagesOfFileExtractOnly(t *testing.T) {
+	manifestFile := createTempManifestFile(t, t.TempDir(), "manifest.yaml", imagesOfManifest)
+
+	mockExecutor := createMockExecutor()
+	var out bytes.Buffer
+	ok, err := imagesOfFile(createTestContext(), mockExecutor, manifestFile, false, &out)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "nginx:1.25\nregistry.example.com/migrate:1.0\nbusybox:1.36\n", out.String())
+	assert.Empty(t, mockExecutor.History)
+}
+
+func TestImagesOfFileMissingFile(t *testing.T) {
+	_, err := imagesOfFile(createTestContext(), createMockExecutor(), "does-not-exist.yaml", true, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// junitTestSuites is the root of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the checks of one environment
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is one chart and image check
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// timeSpan tracks the wall clock covered by a set of results. Charts are
+// checked concurrently, so summing their durations would overstate the time taken.
+type timeSpan struct {
+	start, end time.Time
+}
+
+func (span *timeSpan) add(result AppCheckResult) {
+	if result.Started.IsZero() {
+		return
+	}
+	if span.start.IsZero() || result.Started.Before(span.start) {
+		span.start = result.Started
+	}
+	if end := result.Started.Add(result.Duration); end.After(span.end) {
+		span.end = end
+	}
+}
+
+func (span timeSpan) seconds() string {
+	return junitSeconds(span.end.Sub(span.start))
+}
+
+func junitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
+
+// newJUnitReport groups results into one test suite per environment. An
+// incomplete run gets a failed test case of its own, so CI doesn't show the
+// partial results as a pass.
+func newJUnitReport(results []AppCheckResult, incomplete bool) junitTestSuites {
+	byEnv := map[string][]AppCheckResult{}
+	for _, result := range results {
+		byEnv[result.Chart.Env] = append(byEnv[result.Chart.Env], result)
+	}
+	envs := make([]string, 0, len(byEnv))
+	for env := range byEnv {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	report := junitTestSuites{Name: "chart-checks"}
+	var total timeSpan
+	for _, env := range envs {
+		suite := junitTestSuite{Name: env}
+		var span timeSpan
+		for _, result := range byEnv[env] {
+			span.add(result)
+			total.add(result)
+			testCase := newJUnitTestCase(result)
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+			if testCase.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suite.Time = span.seconds()
+		if !span.start.IsZero() {
+			suite.Timestamp = span.start.UTC().Format(time.RFC3339)
+		}
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+	if incomplete {
+		message := "the run stopped before every chart was checked, the results are incomplete"
+		report.Suites = append(report.Suites, junitTestSuite{
+			Name:     "run",
+			Tests:    1,
+			Failures: 1,
+			Time:     junitSeconds(0),
+			Cases: []junitTestCase{{
+				Name:      "all charts checked",
+				Classname: "run",
+				Time:      junitSeconds(0),
+				Failure:   &junitFailure{Message: message, Text: message},
+			}},
+		})
+		report.Tests++
+		report.Failures++
+	}
+	report.Time = total.seconds()
+	return report
+}
+
+func newJUnitTestCase(result AppCheckResult) junitTestCase {
+	name := fmt.Sprintf("%s %s", result.Chart.ChartName, result.Chart.ChartVersion)
+	if result.Image != "" {
+		name = fmt.Sprintf("%s image %s", name, result.Image)
+	}
+	testCase := junitTestCase{
+		Name:      name,
+		Classname: fmt.Sprintf("%s.%s", result.Chart.Env, result.Chart.ChartName),
+		Time:      junitSeconds(result.Duration),
+	}
+	switch {
+	case result.Warning:
+		testCase.SystemOut = "warning: " + result.Error.Error()
+	case result.Error != nil:
+		testCase.Failure = &junitFailure{Message: result.Error.Error(), Text: result.Error.Error()}
+	case result.Skipped:
+		testCase.Skipped = &junitSkipped{Message: "unchanged from baseline"}
+	}
+	return testCase
+}
+
+// writeJUnitReport writes the results as a JUnit XML file for CI systems to display
+func writeJUnitReport(path string, results []AppCheckResult, incomplete bool) error {
+	data, err := xml.MarshalIndent(newJUnitReport(results, incomplete), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteJUnitReport(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+
+	results := []AppCheckResult{
+		{Chart: development, Image: "nginx:1.25", Started: started, Duration: 2 * time.Second},
+		{Chart: development, Image: "redis:6.2", Error: fmt.Errorf("docker image does not exist: redis:6.2"), Started: started, Duration: 3 * time.Second},
+		{Chart: production, Skipped: true, Started: started.Add(time.Second), Duration: 500 * time.Millisecond},
+	}
+
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	assert.NoError(t, writeJUnitReport(path, results, false))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var report junitTestSuites
+	assert.NoError(t, xml.Unmarshal(data, &report))
+
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, "3.000", report.Time, "Concurrent checks must not be summed")
+
+	assert.Len(t, report.Suites, 2)
+	developmentSuite := report.Suites[0]
+	assert.Equal(t, "development", developmentSuite.Name)
+	assert.Equal(t, 2, developmentSuite.Tests)
+	assert.Equal(t, 1, developmentSuite.Failures)
+	assert.Equal(t, "3.000", developmentSuite.Time)
+	assert.Equal(t, "2024-05-01T12:00:00Z", developmentSuite.Timestamp)
+	assert.Len(t, developmentSuite.Cases, 2)
+	assert.Equal(t, "test-chart 1.0.0 image nginx:1.25", developmentSuite.Cases[0].Name)
+	assert.Equal(t, "development.test-chart", developmentSuite.Cases[0].Classname)
+	assert.Equal(t, "2.000", developmentSuite.Cases[0].Time)
+	assert.Nil(t, developmentSuite.Cases[0].Failure)
+	assert.Equal(t, "docker image does not exist: redis:6.2", developmentSuite.Cases[1].Failure.Text)
+
+	productionSuite := report.Suites[1]
+	assert.Equal(t, "production", productionSuite.Name)
+	assert.Equal(t, 1, productionSuite.Tests)
+	assert.Equal(t, 1, productionSuite.Skipped)
+	assert.Equal(t, "0.500", productionSuite.Time)
+	assert.NotNil(t, productionSuite.Cases[0].Skipped)
+}
+
+func TestWriteJUnitReportOfIncompleteRun(t *testing.T) {
+	report := newJUnitReport([]AppCheckResult{{Chart: createTestChart(), Image: "nginx:1.25"}}, true)
+
+	assert.Equal(t, 2, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Len(t, report.Suites, 2)
+	assert.Equal(t, "run", report.Suites[1].Name)
+	assert.NotNil(t, report.Suites[1].Cases[0].Failure)
+}
+
+func TestAppCheckerStampsResultTiming(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.FileExistsMap = map[string]bool{"values.yaml": false}
+
+	before := time.Now()
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.False(t, results[0].Started.Before(before))
+	assert.False(t, results[0].Started.After(time.Now()))
+	assert.Less(t, results[0].Duration, time.Since(before)+time.Millisecond)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lockFileName is created in the output directory for the duration of a run
+const lockFileName = ".chart-checker.lock"
+
+// ErrOutputDirLocked is returned when another run holds the output directory lock
+var ErrOutputDirLocked = errors.New("output directory is locked by another run")
+
+// acquireOutputLock creates the lockfile in outputDir so that a concurrent run
+// using the same directory fails instead of clobbering it. The returned
+// function removes the lockfile again.
+func acquireOutputLock(outputDir string) (func() error, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	lockPath := filepath.Join(outputDir, lockFileName)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		holder, _ := os.ReadFile(lockPath)
+		return nil, fmt.Errorf("%w: %s is held by %s (remove it if no other run is active, or use -no-lock)", ErrOutputDirLocked, lockPath, strings.TrimSpace(string(holder)))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lockfile: %w", err)
+	}
+	fmt.Fprintf(f, "pid %d since %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write lockfile: %w", err)
+	}
+
+	return func() error {
+		return os.Remove(lockPath)
+	}, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireOutputLock(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "manifests")
+
+	release, err := acquireOutputLock(outputDir)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(outputDir, lockFileName))
+
+	_, err = acquireOutputLock(outputDir)
+	assert.ErrorIs(t, err, ErrOutputDirLocked)
+	assert.Contains(t, err.Error(), "pid ")
+
+	assert.NoError(t, release())
+	assert.NoFileExists(t, filepath.Join(outputDir, lockFileName))
+
+	release, err = acquireOutputLock(outputDir)
+	assert.NoError(t, err, "Expected the lock to be available again after release")
+	assert.NoError(t, release())
+}
+
+func TestRecreateOutputDirKeepsLock(t *testing.T) {
+	outputDir := t.TempDir()
+	release, err := acquireOutputLock(outputDir)
+	assert.NoError(t, err)
+	defer release()
+
+	staleManifest := createTempManifestFile(t, outputDir, "old/chart.yaml", "kind: Pod")
+
+	assert.NoError(t, recreateOutputDir(outputDir))
+	assert.NoFileExists(t, staleManifest)
+	assert.FileExists(t, filepath.Join(outputDir, lockFileName))
+
+	entries, err := os.ReadDir(outputDir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log formats of -log-format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logLevel is the lowest level of engine log messages that get written
+var logLevel = new(slog.LevelVar)
+
+// logger receives the engine log messages. It writes to stderr so stdout
+// only carries results.
+var logger = slog.New(newColorHandler(os.Stderr, logLevel, colorEnabled(os.Stderr)))
+
+// noColor is set by -no-color to write text logs without ANSI colors
+var noColor bool
+
+// isTerminal reports whether w is a terminal rather than a file or pipe
+var isTerminal = func(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled reports whether text logs written to w are colored: only on a
+// terminal, and never with -no-color or the NO_COLOR environment variable set
+func colorEnabled(w io.Writer) bool {
+	return !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(w)
+}
+
+// parseLogLevel parses a -log-level value: debug, info, warning or error
+func parseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warning", "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected debug, info, warning or error", name)
+}
+
+// configureLogging sets the level of the engine log messages and writes them
+// to w as colored text or, for CI, as one JSON object per line
+func configureLogging(level, format string, w io.Writer) error {
+	parsed, err := parseLogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	var handler slog.Handler
+	switch format {
+	case logFormatText:
+		handler = newColorHandler(w, logLevel, colorEnabled(w))
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: logLevel})
+	default:
+		return fmt.Errorf("unknown log format %q, expected %s or %s", format, logFormatText, logFormatJSON)
+	}
+	logLevel.Set(parsed)
+	logger = slog.New(handler)
+	return nil
+}
+
+// setVerboseLogging enables debug messages for the commands that only have -v
+func setVerboseLogging(verbose bool) {
+	if verbose {
+		logLevel.Set(slog.LevelDebug)
+	}
+}
+
+// colorHandler writes log records as lines, colored by level when color is
+// set, with the engine and worker attributes in their own columns:
+//
+//	[WARNING]	[ImageExtraction Worker 2]	message
+type colorHandler struct {
+	lock  *sync.Mutex
+	w     io.Writer
+	level slog.Leveler
+	color bool
+	attrs []slog.Attr
+}
+
+func newColorHandler(w io.Writer, level slog.Leveler, color bool) *colorHandler {
+	return &colorHandler{lock: &sync.Mutex{}, w: w, level: level, color: color}
+}
+
+func (h *colorHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *colorHandler) Handle(_ context.Context, record slog.Record) error {
+	var engineName string
+	var workerId int64
+	var extra []string
+	addAttr := func(attr slog.Attr) bool {
+		switch attr.Key {
+		case "engine":
+			engineName = attr.Value.String()
+		case "worker":
+			workerId = attr.Value.Int64()
+		default:
+			extra = append(extra, fmt.Sprintf("%s=%v", attr.Key, attr.Value))
+		}
+		return true
+	}
+	for _, attr := range h.attrs {
+		addAttr(attr)
+	}
+	record.Attrs(addAttr)
+
+	var color, label string
+	switch {
+	case record.Level >= slog.LevelError:
+		color, label = colorRed, "ERROR"
+	case record.Level >= slog.LevelWarn:
+		color, label = colorYellow, "WARNING"
+	case record.Level >= slog.LevelInfo:
+		color, label = colorReset, "INFO"
+	default:
+		color, label = colorCyan, "DEBUG"
+	}
+	reset := colorReset
+	if !h.color {
+		color, reset = "", ""
+	}
+
+	// Additional lines of a message are indented past the prefix columns
+	lines := strings.Split(record.Message, "\n")
+	if len(extra) > 0 {
+		lines[0] += " " + strings.Join(extra, " ")
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s[%s]\t[%s Worker %d]\t%s%s\n", color, label, engineName, workerId, lines[0], reset)
+	for _, line := range lines[1:] {
+		fmt.Fprintf(&text, "\t\t%s\n", line)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	_, err := io.WriteString(h.w, text.String())
+	return err
+}
+
+func (h *colorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr{}, h.attrs...), attrs...)
+	return &clone
+}
+
+// WithGroup returns the handler unchanged, the engines don't group attributes
+func (h *colorHandler) WithGroup(name string) slog.Handler {
+	return h
+}
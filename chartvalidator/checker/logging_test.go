@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureLogs sends engine log messages at level and above to the returned
+// buffer for the rest of the test
+func captureLogs(t *testing.T, level string) *bytes.Buffer {
+	var logs bytes.Buffer
+	assert.NoError(t, configureLogging(level, logFormatText, &logs))
+	t.Cleanup(func() { configureLogging("info", logFormatText, os.Stderr) })
+	return &logs
+}
+
+func TestLogLevelsFilterMessages(t *testing.T) {
+	tests := []struct {
+		level    string
+		expected []string
+		filtered []string
+	}{
+		{"debug", []string{"debug message", "warning message", "error message"}, nil},
+		{"info", []string{"warning message", "error message"}, []string{"debug message"}},
+		{"warning", []string{"warning message", "error message"}, []string{"debug message"}},
+		{"error", []string{"error message"}, []string{"debug message", "warning message"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			logs := captureLogs(t, tt.level)
+
+			logEngineDebug("TestEngine", 1, "debug message")
+			logEngineWarning("TestEngine", 1, "warning message")
+			logEngineError("TestEngine", 1, "error message")
+
+			for _, message := range tt.expected {
+				assert.Contains(t, logs.String(), message)
+			}
+			for _, message := range tt.filtered {
+				assert.NotContains(t, logs.String(), message)
+			}
+		})
+	}
+}
+
+func TestColorHandlerFormat(t *testing.T) {
+	var logs bytes.Buffer
+	handler := newColorHandler(&logs, slog.LevelDebug, true)
+
+	slog.New(handler).Warn("first line\nsecond line", "engine", "TestEngine", "worker", 3)
+
+	assert.Equal(t, colorYellow+"[WARNING]\t[TestEngine Worker 3]\tfirst line"+colorReset+"\n\t\tsecond line\n", logs.String())
+}
+
+// forceTerminal makes every log output count as a terminal for the rest of the test
+func forceTerminal(t *testing.T) {
+	detect := isTerminal
+	isTerminal = func(w io.Writer) bool { return true }
+	t.Cleanup(func() { isTerminal = detect })
+}
+
+func TestLogColors(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal bool
+		noColor  bool
+		env      string
+		colored  bool
+	}{
+		{name: "terminal", terminal: true, colored: true},
+		{name: "not a terminal"},
+		{name: "NO_COLOR set", terminal: true, env: "1"},
+		{name: "-no-color", terminal: true, noColor: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.terminal {
+				forceTerminal(t)
+			}
+			t.Setenv("NO_COLOR", tt.env)
+			noColor = tt.noColor
+			defer func() { noColor = false }()
+			logs := captureLogs(t, "info")
+
+			logEngineWarning("TestEngine", 1, "registry unreachable")
+
+			assert.Contains(t, logs.String(), "[WARNING]\t[TestEngine Worker 1]\tregistry unreachable")
+			assert.Equal(t, tt.colored, strings.Contains(logs.String(), "\033["))
+		})
+	}
+}
+
+func TestJSONLogFormat(t *testing.T) {
+	var logs bytes.Buffer
+	assert.NoError(t, configureLogging("warning", logFormatJSON, &logs))
+	defer configureLogging("info", logFormatText, os.Stderr)
+
+	logEngineDebug("TestEngine", 2, "hidden")
+	logEngineError("TestEngine", 2, "helm failed")
+
+	var record map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &record))
+	assert.Equal(t, "ERROR", record["level"])
+	assert.Equal(t, "helm failed", record["msg"])
+	assert.Equal(t, "TestEngine", record["engine"])
+	assert.Equal(t, float64(2), record["worker"])
+}
+
+func TestConfigureLoggingErrors(t *testing.T) {
+	assert.EqualError(t, configureLogging("verbose", logFormatText, os.Stderr), `unknown log level "verbose", expected debug, info, warning or error`)
+	assert.EqualError(t, configureLogging("info", "xml", os.Stderr), `unknown log format "xml", expected text or json`)
+}
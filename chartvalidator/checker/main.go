@@ -4,12 +4,20 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"text/template"
+	"time"
 )
 
-var srcPrefix string = "../"
-var verboseLogging bool = false
+// defaultSrcPrefix is where values file paths in ApplicationSets are resolved
+// from when running from the checker directory of the repository
+const defaultSrcPrefix = "../"
+
+var valuesRoot string = defaultSrcPrefix
 
 func main() {
 	if len(os.Args) < 2 {
@@ -25,6 +33,16 @@ func main() {
 		runChartChecksCommand(args)
 	case "render-only":
 		runRenderOnlyCommand(args)
+	case "images-of":
+		runImagesOfCommand(args)
+	case "recheck":
+		runRecheckCommand(args)
+	case "validate-manifests":
+		runValidateManifestsCommand(args)
+	case "extract-images":
+		runExtractImagesCommand(args)
+	case "validate-images":
+		runValidateImagesCommand(args)
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -40,6 +58,11 @@ func printUsage() {
 	fmt.Println("Commands:")
 	fmt.Println("  run-checks    Runs all available checks on the charts for given environment.")
 	fmt.Println("  render-only   Renders the charts for the given environment without performing validations.")
+	fmt.Println("  images-of     Lists and validates the images referenced by a single rendered manifest file.")
+	fmt.Println("  recheck       Re-validates only the images reported missing in a prior NDJSON report.")
+	fmt.Println("  validate-manifests  Validates a directory of pre-rendered manifests with kubeconform.")
+	fmt.Println("  extract-images      Lists the images referenced by a directory of rendered manifests.")
+	fmt.Println("  validate-images     Validates that the images in a directory of JSON image lists exist.")
 	fmt.Println("  help          Displays this help message.")
 	fmt.Println("")
 	fmt.Println("Use 'run-manifest-checks <command> -h' to see command-specific flags.")
@@ -52,11 +75,82 @@ func runChartChecksCommand(args []string) {
 
 	var (
 		singleEnv = fs.String("env", "", "Only process this environment (folder name under -envdir).")
+		envList   = fs.String("envs", "", "Only process these environments, comma-separated (e.g. dev,staging).")
+		skipBad   = fs.Bool("skip-bad-envs", false, "Warn about and skip environments named in -env/-envs that don't exist instead of failing.")
 		envDir    = fs.String("envdir", "../env", "Base directory containing environment folders.")
+		source    = fs.String("source", sourceAppsets, "Where charts are declared: appsets (Argo CD ApplicationSets) or flux (HelmReleases).")
+		strict    = fs.Bool("strict-appset", false, "Fail when ApplicationSet list elements contain unknown keys, e.g. misspelled chartVersion.")
+		minCharts = fs.Int("min-charts", 0, "Fail when discovery finds fewer charts than this, e.g. because -envdir points at the wrong directory.")
+		apps      = fs.Bool("include-applications", false, "Also read standalone Argo CD Applications (kind: Application) from every .yaml file in the appsets folders.")
+		fieldMap  = fs.String("field-map", "", "YAML file naming the ApplicationSet element keys that hold chartName, repoURL, chartVersion, baseValuesFile, valuesOverride and namespace, for elements that use other keys.")
 		outputDir = fs.String("output", "manifests", "Output directory for rendered charts.")
-		verbose   = fs.Bool("v", false, "Enable verbose logging.")
+		noLock    = fs.Bool("no-lock", false, "Don't take the lockfile in the output directory that stops concurrent runs from clobbering each other.")
+		baseline  = fs.String("baseline", "", "Output directory of an earlier complete run-checks, moved out of -output since that is cleared first. Charts rendering identically reuse its results instead of being checked again.")
+		prefix    = fs.String("src-prefix", defaultSrcPrefix, "Prefix for the values file paths and vendored chart paths (charts without a repoURL) in ApplicationSets and Applications, usually the repository root relative to the working directory.")
+		root      = fs.String("values-root", "", "Values files referenced by ApplicationSets must resolve within this directory (default the -src-prefix directory).")
+		secrets   = fs.Bool("detect-secrets", false, "Fail charts whose rendered Secrets or env values contain literal credentials.")
+		secCtx    = fs.Bool("require-security-context", false, "Fail charts with containers that are privileged, run as root, or do not set runAsNonRoot: true and allowPrivilegeEscalation: false.")
+		required  = fs.String("required-values", "", "Fail charts whose values leave these dotted paths unset or empty (e.g. image.tag,ingress.host), or whose manifests render REPLACE_ME style placeholders.")
+		suffixLen = fs.Int("suffix-length", defaultSuffixLength, "Length of the suffix added to rendered manifest filenames, a hash of the chart that stays the same between runs. At least 4, charts whose shortened hashes collide get the full hash.")
+		nameTmpl  = fs.String("manifest-name-template", defaultManifestNameTemplate, "Go template for rendered manifest filenames, with .Env, .Chart, .Version and the .Suffix hash of the chart. The .yaml extension is added.")
+		serialIO  = fs.Bool("serial-writes", false, "Write rendered manifests from a single goroutine to avoid disk contention under high concurrency.")
+		dryRun    = fs.Bool("dry-run", false, "Log the helm, kubeconform and registry commands for each chart instead of running them. Charts render to empty manifests.")
+		ociAuth   = fs.String("oci-auth", "", "YAML file configuring token or registry-config authentication per OCI chart registry host.")
+		buildDeps = fs.Bool("build-deps", false, "Run helm dependency build once for each vendored chart directory before rendering it.")
+		renderTO  = fs.Duration("render-timeout", defaultRenderTimeout, "Give up on a helm template command after this long (e.g. 5m), reporting the chart as failed. Zero disables the limit.")
+		conftest  = fs.String("conftest-policy", "", "Directory of Rego policies every rendered manifest is tested against with conftest. deny rules fail the chart, warn rules are reported as warnings.")
+		maxRender = fs.Duration("max-render-duration", 0, "Fail charts that take longer than this to render (e.g. 30s). Zero disables the check.")
+		kcBatch   = fs.Bool("kubeconform-batch", false, "Validate all rendered manifests with a single kubeconform invocation instead of one per chart.")
+		kubeVer   = fs.String("kube-version", "", "Kubernetes version to validate manifests against (e.g. 1.29.0), kubeconform's default when empty.")
+		skipKinds = fs.String("skip-kinds", "", "Comma-separated resource kinds kubeconform should not validate, e.g. CRDs without a published schema.")
+		indexOut  = fs.String("index-out", "", "Write a JSON index mapping each rendered manifest to its chart, env and extracted images.")
+		metrics   = fs.String("metrics-addr", "", "Serve engine counters on /metrics and a liveness check on /healthz at this address (e.g. :9090) while checks run.")
+		appVers   = fs.Bool("app-versions", false, "Look up each chart's appVersion with helm show chart and include it in the results.")
+		cacheFile = fs.String("cache-file", "", "JSON file that keeps image validation results across runs.")
+		cacheTTL  = fs.Duration("cache-ttl", defaultCacheTTL, "How long results in -cache-file are trusted before an image is checked again.")
+		regBatch  = fs.Bool("batch-registry-lookups", false, "Check images over the registry HTTP API instead of with -registry-tool, collecting every image first so that each registry host is asked for one token covering all of its images. Credentials are read from the -docker-config directory or the default docker config.")
+		shared    = fs.Bool("shared-cache", false, "Share -cache-file with concurrent runs, e.g. CI matrix jobs on a network volume: results are merged into it under a lockfile as soon as they are known, so an image is inspected by one job only.")
+		regTool   = fs.String("registry-tool", "docker", "Tool that checks images exist in their registry: docker (manifest inspect), skopeo (inspect) or crane (manifest). skopeo and crane need no Docker daemon.")
+		maxRegCon = fs.Int("max-registry-concurrency", 0, "Look up at most this many images in their registry at once, to stay within registry rate limits. Zero leaves it to the number of workers.")
+		noAuthErr = fs.Bool("ignore-auth-errors", false, "Report images the registry denies access to as warnings instead of failures.")
+		mutTags   = fs.String("mutable-tags", "", "Tags that are flagged as mutable besides latest and no tag, comma-separated (e.g. main,stable).")
+		noMutable = fs.String("disallow-mutable-tags", "", "Environments where images with mutable tags fail the check instead of warning, comma-separated (e.g. production).")
+		reqDigest = fs.Bool("require-digest", false, "Warn about images referenced by tag only, which could be pinned to a digest for reproducible deploys.")
+		dockerCfg = fs.String("docker-config", "", "Directory with the config.json holding registry credentials for image validation, passed as DOCKER_CONFIG. Point it at a directory per set of registries to use different credentials in ephemeral CI.")
+		platform  = fs.String("require-platform", "", "Fail images whose manifest list has no entry for this platform, e.g. linux/arm64 or linux/arm/v7.")
+		verifySig = fs.Bool("verify-signatures", false, "Verify the signature of every image that exists with cosign verify, failing unsigned or invalid images.")
+		cosignKey = fs.String("cosign-key", "", "Public key (path or KMS URI) that image signatures are verified against.")
+		cosignId  = fs.String("cosign-identity", "", "Certificate identity that keyless image signatures must be made by, used with -cosign-issuer.")
+		cosignIss = fs.String("cosign-issuer", "", "OIDC issuer of keyless image signatures, used with -cosign-identity.")
+		imgPaths  = fs.String("image-paths", "", "YAML file mapping custom resource kinds to their image fields and pod template paths.")
+		selfCheck = fs.Bool("self-verify", false, "Debug the image extractor: also deep scan every rendered manifest for image fields and warn about images found by only one of the two passes.")
+		rewrites  = fs.String("image-rewrite", "", "YAML file of regex rewrites applied to extracted images before validation.")
+		ndjson    = fs.Bool("ndjson-stdout", false, "Write each result as a JSON line to stdout and send human-readable output to stderr.")
+		junit     = fs.String("junit", "", "Write a JUnit XML report to this path, one test suite per environment.")
+		csvOut    = fs.String("csv-out", "", "Write a CSV report to this path with env, chart, version, image, status and error columns, one row per result.")
+		reportDir = fs.String("report-dir", "", "Write <env>.json and <env>.txt reports to this directory, each with only the results of that environment.")
+		failFast  = fs.Bool("fail-fast", false, "Stop at the first failed check, cancelling the running helm, kubeconform and registry commands, for quick local iteration. The reports are still written from the results so far, the JSON and JUnit reports marked incomplete.")
+		sorted    = fs.Bool("sorted", false, "Print the results once all checks are done, sorted by env, chart and image, for output that can be diffed between runs. Interactive runs are better off streaming results as they come in.")
+		grouped   = fs.Bool("group-by-image", false, "Print each image once with the charts referencing it, after all checks are done, instead of a line per chart and image. Machine-readable reports keep a result per chart.")
+		format    = fs.String("format", formatText, "Report format: text, or json to write one JSON report to stdout and send human-readable output to stderr.")
+		verbose   = fs.Bool("v", false, "Enable verbose logging, the same as -log-level debug.")
+		logLvl    = fs.String("log-level", "info", "Lowest level of engine log messages written to stderr: debug, info, warning or error.")
+		logFmt    = fs.String("log-format", logFormatText, "Format of engine log messages: text, colored for terminals, or json, one object per line for CI.")
+		noColors  = fs.Bool("no-color", false, "Write text log messages without ANSI colors, which are also left out when stderr isn't a terminal or NO_COLOR is set.")
+		symlinks  = fs.Bool("follow-symlinks", false, "Follow symlinked directories when discovering manifests.")
+		config    = fs.String("config", "", "YAML file mapping flag names to values. Command line flags and CHART_CHECKER_<FLAG> environment variables take precedence.")
+		printCfg  = fs.Bool("print-config", false, "Print the effective configuration as YAML and exit.")
 	)	
 
+	var helmArgs stringListFlag
+	fs.Var(&helmArgs, "helm-arg", "Extra argument for helm template, passed on as given and repeatable, one argument per use (e.g. -helm-arg --kube-version=1.29 -helm-arg \"--set=motd=hello world\").")
+	var mirrors stringListFlag
+	fs.Var(&mirrors, "registry-mirror", "Validate the images of a registry against its mirror, as registry=mirror (e.g. ghcr.io=ghcr-mirror.internal) or just the mirror of docker.io, repeatable. Results keep the image as written in the chart.")
+	var allowRegs stringListFlag
+	fs.Var(&allowRegs, "allowed-registries", "Registry (e.g. ghcr.io) or registry path prefix (e.g. ghcr.io/myorg) images may come from, repeatable. Images from any other registry fail, checked before -registry-mirror applies. A .yaml value is a file listing the registries by default and per environment.")
+	var schemaLocs stringListFlag
+	fs.Var(&schemaLocs, "schema-location", "Kubeconform schema location, repeatable. When given, replaces the built-in default, datreeio CRDs-catalog and ci/schemas locations.")
+
 	fs.Usage = func() {
 		fmt.Println("Usage: run-manifest-checks run-checks [flags]")
 		fmt.Println("")
@@ -77,9 +171,155 @@ func runChartChecksCommand(args []string) {
 		os.Exit(1)
 	}
 
-	verboseLogging = *verbose
+	if err := applyConfigSources(fs, *config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *printCfg {
+		if err := printEffectiveConfig(os.Stdout, fs, "config", "print-config"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing configuration: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	noColor = *noColors
+	configureLoggingOrExit(*logLvl, *logFmt, *verbose)
+	valuesRoot = *root
+	if valuesRoot == "" {
+		valuesRoot = *prefix
+	}
+	followSymlinks = *symlinks
+	appsetFields = loadAppsetFieldMapOrExit(*fieldMap)
+
+	options := AppCheckerOptions{
+		OutputDir:              *outputDir,
+		BaselineDir:            *baseline,
+		DetectSecrets:          *secrets,
+		RequireSecurityContext: *secCtx,
+		RequiredValues:         parseCommaList(*required),
+		SuffixLength:           checkSuffixLengthOrExit(*suffixLen),
+		ManifestNameTemplate:   parseManifestNameTemplateOrExit(*nameTmpl),
+		SerialWrites:           *serialIO,
+		DryRun:                 *dryRun,
+		HelmArgs:               []string(helmArgs),
+		BuildDeps:              *buildDeps,
+		RenderTimeout:          *renderTO,
+		SchemaLocations:        []string(schemaLocs),
+		KubeVersion:            *kubeVer,
+		SkipKinds:              parseCommaList(*skipKinds),
+		MaxRenderDuration:      *maxRender,
+		ConftestPolicy:         *conftest,
+		KubeconformBatch:       *kcBatch,
+		OCIAuth:                loadOCIAuthOrExit(*ociAuth),
+		IndexOut:               *indexOut,
+		NoLock:                 *noLock,
+		AppVersions:            *appVers,
+		MetricsAddr:            *metrics,
+		CacheFile:              *cacheFile,
+		CacheTTL:               *cacheTTL,
+		BatchRegistryLookups:   *regBatch,
+		SharedCache:            *shared,
+		IgnoreAuthErrors:       *noAuthErr,
+		DockerConfig:           *dockerCfg,
+		SelfVerify:             *selfCheck,
+		RequireDigest:          *reqDigest,
+		MaxRegistryConcurrency: *maxRegCon,
+		MutableTags: &mutableTagPolicy{
+			Denylist:     parseCommaList(*mutTags),
+			DisallowEnvs: parseCommaList(*noMutable),
+		},
+	}
+
+	registryMirrors, err := parseRegistryMirrors(mirrors)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.RegistryMirrors = registryMirrors
+
+	if *rewrites != "" {
+		rules, err := loadImageRewriteRules(*rewrites)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading image rewrites: %v\n", err)
+			os.Exit(1)
+		}
+		options.ImageRewrites = rules
+	}
+
+	if *imgPaths != "" {
+		paths, err := loadCustomImagePaths(*imgPaths)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading image paths: %v\n", err)
+			os.Exit(1)
+		}
+		customImagePaths = paths
+	}
+
+	if *dockerCfg != "" {
+		if info, err := os.Stat(*dockerCfg); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: -docker-config %s is not a directory\n", *dockerCfg)
+			os.Exit(1)
+		}
+	}
+
+	tool, err := newRegistryTool(*regTool)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	options.RegistryTool = tool
+
+	if *platform != "" {
+		required, err := parsePlatform(*platform)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		options.RequirePlatform = required
+	}
+
+	if *verifySig {
+		verifier, err := newSignatureVerifier(*cosignKey, *cosignId, *cosignIss)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error configuring signature verification: %v\n", err)
+			os.Exit(1)
+		}
+		options.SignatureVerifier = verifier
+	}
+
+	policy, err := registryPolicyFromFlags(allowRegs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading allowed registries: %v\n", err)
+		os.Exit(1)
+	}
+	options.RegistryPolicy = policy
+
+	discovery := DiscoveryOptions{
+		Source:              *source,
+		EnvDir:              *envDir,
+		SrcPrefix:           *prefix,
+		SingleEnv:           *singleEnv,
+		Envs:                parseCommaList(*envList),
+		SkipBadEnvs:         *skipBad,
+		StrictAppsets:       *strict,
+		IncludeApplications: *apps,
+		MinCharts:           *minCharts,
+	}
+
+	report := ReportOptions{
+		NDJSONStdout: *ndjson,
+		Format:       *format,
+		JUnitPath:    *junit,
+		CSVPath:      *csvOut,
+		ReportDir:    *reportDir,
+		GroupByImage: *grouped,
+		Sorted:       *sorted,
+		FailFast:     *failFast,
+	}
 
-	if err := runAllChartChecks(*singleEnv, *envDir, *outputDir); err != nil {
+	if err := runAllChartChecks(discovery, options, report); err != nil {
 		fmt.Fprintf(os.Stderr, "Error running chart checks: %v\n", err)
 		os.Exit(1)
 	}
@@ -91,11 +331,37 @@ func runRenderOnlyCommand(args []string) {
 
 	var (
 		singleEnv = fs.String("env", "", "Only process this environment (folder name under -envdir).")
+		envList   = fs.String("envs", "", "Only process these environments, comma-separated (e.g. dev,staging).")
+		skipBad   = fs.Bool("skip-bad-envs", false, "Warn about and skip environments named in -env/-envs that don't exist instead of failing.")
 		envDir    = fs.String("envdir", "../env", "Base directory containing environment folders.")
+		source    = fs.String("source", sourceAppsets, "Where charts are declared: appsets (Argo CD ApplicationSets) or flux (HelmReleases).")
+		strict    = fs.Bool("strict-appset", false, "Fail when ApplicationSet list elements contain unknown keys, e.g. misspelled chartVersion.")
+		minCharts = fs.Int("min-charts", 0, "Fail when discovery finds fewer charts than this, e.g. because -envdir points at the wrong directory.")
+		apps      = fs.Bool("include-applications", false, "Also read standalone Argo CD Applications (kind: Application) from every .yaml file in the appsets folders.")
+		fieldMap  = fs.String("field-map", "", "YAML file naming the ApplicationSet element keys that hold chartName, repoURL, chartVersion, baseValuesFile, valuesOverride and namespace, for elements that use other keys.")
 		outputDir = fs.String("output", "manifests", "Output directory for rendered charts.")
-		verbose   = fs.Bool("v", false, "Enable verbose logging.")
+		noLock    = fs.Bool("no-lock", false, "Don't take the lockfile in the output directory that stops concurrent runs from clobbering each other.")
+		prefix    = fs.String("src-prefix", defaultSrcPrefix, "Prefix for the values file paths and vendored chart paths (charts without a repoURL) in ApplicationSets and Applications, usually the repository root relative to the working directory.")
+		root      = fs.String("values-root", "", "Values files referenced by ApplicationSets must resolve within this directory (default the -src-prefix directory).")
+		suffixLen = fs.Int("suffix-length", defaultSuffixLength, "Length of the suffix added to rendered manifest filenames, a hash of the chart that stays the same between runs. At least 4, charts whose shortened hashes collide get the full hash.")
+		nameTmpl  = fs.String("manifest-name-template", defaultManifestNameTemplate, "Go template for rendered manifest filenames, with .Env, .Chart, .Version and the .Suffix hash of the chart. The .yaml extension is added.")
+		serialIO  = fs.Bool("serial-writes", false, "Write rendered manifests from a single goroutine to avoid disk contention under high concurrency.")
+		dryRun    = fs.Bool("dry-run", false, "Log the helm, kubeconform and registry commands for each chart instead of running them. Charts render to empty manifests.")
+		ociAuth   = fs.String("oci-auth", "", "YAML file configuring token or registry-config authentication per OCI chart registry host.")
+		buildDeps = fs.Bool("build-deps", false, "Run helm dependency build once for each vendored chart directory before rendering it.")
+		renderTO  = fs.Duration("render-timeout", defaultRenderTimeout, "Give up on a helm template command after this long (e.g. 5m), reporting the chart as failed. Zero disables the limit.")
+		symlinks  = fs.Bool("follow-symlinks", false, "Follow symlinked directories when discovering manifests.")
+		verbose   = fs.Bool("v", false, "Enable verbose logging, the same as -log-level debug.")
+		logLvl    = fs.String("log-level", "info", "Lowest level of engine log messages written to stderr: debug, info, warning or error.")
+		logFmt    = fs.String("log-format", logFormatText, "Format of engine log messages: text, colored for terminals, or json, one object per line for CI.")
+		noColors  = fs.Bool("no-color", false, "Write text log messages without ANSI colors, which are also left out when stderr isn't a terminal or NO_COLOR is set.")
+		config    = fs.String("config", "", "YAML file mapping flag names to values. Command line flags and CHART_CHECKER_<FLAG> environment variables take precedence.")
+		printCfg  = fs.Bool("print-config", false, "Print the effective configuration as YAML and exit.")
 	)	
 
+	var helmArgs stringListFlag
+	fs.Var(&helmArgs, "helm-arg", "Extra argument for helm template, passed on as given and repeatable, one argument per use (e.g. -helm-arg --kube-version=1.29 -helm-arg \"--set=motd=hello world\").")
+
 	fs.Usage = func() {
 		fmt.Println("Usage: run-manifest-checks render-only [flags]")
 		fmt.Println("")
@@ -108,9 +374,54 @@ func runRenderOnlyCommand(args []string) {
 		os.Exit(1)
 	}
 
-	verboseLogging = *verbose
+	if err := applyConfigSources(fs, *config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *printCfg {
+		if err := printEffectiveConfig(os.Stdout, fs, "config", "print-config"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing configuration: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	noColor = *noColors
+	configureLoggingOrExit(*logLvl, *logFmt, *verbose)
+	valuesRoot = *root
+	if valuesRoot == "" {
+		valuesRoot = *prefix
+	}
+	followSymlinks = *symlinks
+	appsetFields = loadAppsetFieldMapOrExit(*fieldMap)
+
+	discovery := DiscoveryOptions{
+		Source:              *source,
+		EnvDir:              *envDir,
+		SrcPrefix:           *prefix,
+		SingleEnv:           *singleEnv,
+		Envs:                parseCommaList(*envList),
+		SkipBadEnvs:         *skipBad,
+		StrictAppsets:       *strict,
+		IncludeApplications: *apps,
+		MinCharts:           *minCharts,
+	}
+
+	options := AppCheckerOptions{
+		OutputDir:    *outputDir,
+		SuffixLength: checkSuffixLengthOrExit(*suffixLen),
+		ManifestNameTemplate: parseManifestNameTemplateOrExit(*nameTmpl),
+		SerialWrites: *serialIO,
+		DryRun:       *dryRun,
+		HelmArgs:     []string(helmArgs),
+		BuildDeps:    *buildDeps,
+		RenderTimeout: *renderTO,
+		OCIAuth:      loadOCIAuthOrExit(*ociAuth),
+		NoLock:       *noLock,
+	}
 
-	if err := runAllChartRenders(*singleEnv, *envDir, *outputDir); err != nil {
+	if err := runAllChartRenders(discovery, options); err != nil {
 		fmt.Fprintf(os.Stderr, "Error running chart renders: %v\n", err)
 		os.Exit(1)
 	}
@@ -118,100 +429,304 @@ func runRenderOnlyCommand(args []string) {
 }
 
 
-func runAllChartRenders(singleEnv, envDir, outputDir string) error {
+func runAllChartRenders(discovery DiscoveryOptions, options AppCheckerOptions) error {
+	// SIGINT and SIGTERM cancel the context, which kills the running helm,
+	// kubeconform and docker commands and lets the engines wind down
+	context, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	fmt.Println("Starting chart renders...")
-	params, err := findChartsInAppsets(envDir, singleEnv)
+	params, err := findCharts(context, discovery)
 	if err != nil {
-		return fmt.Errorf("failed to find charts in ApplicationSets: %w", err)
+		return fmt.Errorf("failed to find charts: %w", err)
 	}
 	
 	fmt.Printf("Found %d charts to process.\n", len(params))
 
-	context := context.Background()
+	if err := checkManifestNamesUnique(options.ManifestNameTemplate, params); err != nil {
+		return err
+	}
+	executor := options.commandExecutor()
+	if err := checkValuesFilesExist(executor, params); err != nil {
+		return err
+	}
+
+	if !options.NoLock {
+		release, err := acquireOutputLock(options.OutputDir)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 
-	// Delete output dir if it exists
-	if err := os.RemoveAll(outputDir); err != nil {
+	// Clear the output dir if it exists
+	if err := recreateOutputDir(options.OutputDir); err != nil {
 		return fmt.Errorf("failed to clear output directory: %w", err)
 	}
 
 	renderer := ChartRenderingEngine{
 		context:    context,
-		executor:   &RealCommandExecutor{},
-		outputDir:  outputDir,
+		executor:   executor,
+		helmArgs:   options.HelmArgs,
+		buildDeps:  options.BuildDeps,
+		renderTimeout: options.RenderTimeout,
+		outputDir:  options.OutputDir,
+		suffixLength: options.SuffixLength,
+		nameTemplate: options.ManifestNameTemplate,
+		serialWrites: options.SerialWrites,
+		ociAuth:    options.OCIAuth,
 		inputChan:  make(chan ChartRenderParams),
 		resultChan: make(chan RenderResult),
 		name:       "ChartRenderer",
 		errorChan: make(chan ErrorResult),
 		workerWaitGroup: sync.WaitGroup{},
 	}
+	renderer.planSuffixes(params)
 	renderer.Start(10)
 
 	go func() {
 		for _, p := range params {
-			renderer.inputChan <- p
+			if !sendOrDone(context, renderer.inputChan, p) {
+				break
+			}
 		}
 		close(renderer.inputChan)
 	}()
 
-	busy := true
-	for busy {
-		select {
-		case renderResult, ok := <-renderer.resultChan:
-			if !ok {
-				fmt.Println("No more render results.")
-				busy = false
-			}
-			fmt.Printf(">>> chart %s %s from env %s: ✓ Rendered successfully to %s\n", renderResult.Chart.ChartName, renderResult.Chart.ChartVersion, renderResult.Chart.Env, renderResult.ManifestPath)
-		case renderErr := <-renderer.errorChan:
-			fmt.Printf(">>> chart %s %s from env %s: ✗ Error: %v\n", renderErr.Chart.ChartName, renderErr.Chart.ChartVersion, renderErr.Chart.Env, renderErr.Error)
-		}
+	// Only the renderer sends errors here, so they end with its workers
+	go func() {
+		renderer.workerWaitGroup.Wait()
+		close(renderer.errorChan)
+	}()
+
+	reportRenderResults(os.Stdout, renderer.resultChan, renderer.errorChan)
+	if context.Err() != nil {
+		return fmt.Errorf("chart renders interrupted")
 	}
 	fmt.Printf("Done")
 	return nil
 }
 
-func runAllChartChecks(singleEnv, envDir, outputDir string) error {
-	fmt.Println("Starting chart checks...")
-	params, err := findChartsInAppsets(envDir, singleEnv)
+func runAllChartChecks(discovery DiscoveryOptions, options AppCheckerOptions, report ReportOptions) error {
+	if err := report.validate(); err != nil {
+		return err
+	}
+
+	setProgressOutput(report.NDJSONStdout || report.Format == formatJSON)
+	var ndjsonOut io.Writer
+	if report.NDJSONStdout {
+		ndjsonOut = os.Stdout
+	}
+
+	// -fail-fast cancels the run at the first failure the same way
+	run, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+
+	// SIGINT and SIGTERM cancel the context, which kills the running helm,
+	// kubeconform and docker commands and lets the engines wind down
+	context, stop := signal.NotifyContext(run, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	fmt.Fprintln(logOutput, "Starting chart checks...")
+	params, err := findCharts(context, discovery)
 	if err != nil {
-		return fmt.Errorf("failed to find charts in ApplicationSets: %w", err)
+		return fmt.Errorf("failed to find charts: %w", err)
 	}
 	
-	fmt.Printf("Found %d charts to process.\n", len(params))
+	fmt.Fprintf(logOutput, "Found %d charts to process.\n", len(params))
+
+	if err := checkManifestNamesUnique(options.ManifestNameTemplate, params); err != nil {
+		return err
+	}
+	executor := options.commandExecutor()
+	if err := checkValuesFilesExist(executor, params); err != nil {
+		return err
+	}
+	if err := checkBaselineDir(options.BaselineDir, options.OutputDir); err != nil {
+		return err
+	}
 
-	context := context.Background()
+	if !options.NoLock {
+		release, err := acquireOutputLock(options.OutputDir)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
 
-	// Delete output dir if it exists
-	if err := os.RemoveAll(outputDir); err != nil {
+	// Clear the output dir if it exists
+	if err := recreateOutputDir(options.OutputDir); err != nil {
 		return fmt.Errorf("failed to clear output directory: %w", err)
 	}
 
-	appChecker := NewAppCheckerEngine(context, outputDir)
+	appChecker := NewAppCheckerEngine(context, executor, options)
+	appChecker.ChartRenderingEngine.planSuffixes(params)
+	if options.MetricsAddr != "" {
+		server, err := serveMetrics(options.MetricsAddr, appChecker)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		fmt.Fprintln(logOutput, "Serving engine metrics on", options.MetricsAddr)
+	}
 	appChecker.Start(10)
 
 	go func() {
 		for _, p := range params {
-			appChecker.inputChan <- AppCheckInstruction{Chart: p}
+			if !sendOrDone(context, appChecker.inputChan, AppCheckInstruction{Chart: p}) {
+				break
+			}
 		}
 		close(appChecker.inputChan)
 	}()
 
-	success := true
+	human := logOutput
+	if report.GroupByImage {
+		// Results are printed grouped by image once they are all in
+		human = io.Discard
+	}
+	var stream <-chan AppCheckResult = appChecker.resultChan
+	if report.FailFast {
+		stream = stopAtFirstFailure(stream, cancelRun)
+	}
+	if report.Sorted {
+		stream = sortedResults(stream)
+	}
+	var results []AppCheckResult
+	passed := reportResults(teeResults(stream, &results), human, ndjsonOut)
+	summary := Aggregate(results)
+	if report.GroupByImage {
+		groups, chartResults := groupResultsByImage(results)
+		printImageGroups(logOutput, groups, chartResults)
+		summary = aggregateImageGroups(groups, chartResults)
+	}
+	summary.Elapsed = time.Since(started)
+	printSummary(logOutput, summary)
+	// The reports are written for incomplete runs too, marked as such, so
+	// that -fail-fast and interrupted runs still leave something for CI to read
+	incomplete := run.Err() != nil || context.Err() != nil
+	if err := writeReports(report, results, incomplete); err != nil {
+		return err
+	}
+	if incomplete {
+		// Charts cut off mid-check have partial results, which a later
+		// baseline would reuse as if they were complete
+		logEngineWarning("AppChecker", -1, fmt.Sprintf("not writing %s for an incomplete run, %s can't serve as a -baseline", baselineResultsFile, options.OutputDir))
+	}
+	if run.Err() != nil {
+		return fmt.Errorf("stopped at the first failed check (-fail-fast), the results above are incomplete")
+	}
+	if context.Err() != nil {
+		return fmt.Errorf("chart checks interrupted, the results above are incomplete")
+	}
+	printImageStyleReport(logOutput, findImageStyleInconsistencies(results))
+	if err := writeBaselineResults(options.OutputDir, results); err != nil {
+		return err
+	}
 
-	for result := range appChecker.resultChan {
-		if result.Error != nil {
-			fmt.Printf(">>> chart %s %s from env %s with image %s: ✗ Error: %v\n", result.Chart.ChartName, result.Chart.ChartVersion, result.Chart.Env, result.Image, result.Error)
-			success = false
-		} else {
-			fmt.Printf(">>> chart %s %s from env %s with image %s: ✓ All checks passed\n", result.Chart.ChartName, result.Chart.ChartVersion, result.Chart.Env, result.Image)
+	if options.IndexOut != "" {
+		if err := writeManifestIndex(options.IndexOut, appChecker.ImageExtractionEngine.index); err != nil {
+			return err
 		}
+		fmt.Fprintln(logOutput, "Wrote manifest index to", options.IndexOut)
 	}
 
-	if success {
-		fmt.Println("All chart checks completed successfully.")
+	if passed {
+		fmt.Fprintln(logOutput, "All chart checks completed successfully.")
 		return nil
 	} else {
-		fmt.Println("Some chart checks failed. See above for details.")
+		fmt.Fprintln(logOutput, "Some chart checks failed. See above for details.")
 		return fmt.Errorf("one or more chart checks failed")
 	}
-}
\ No newline at end of file
+}
+
+// writeReports writes the -format json, -junit, -csv-out and -report-dir
+// reports. The JSON and JUnit reports of an incomplete run say so, the CSV
+// report has no room for it.
+func writeReports(report ReportOptions, results []AppCheckResult, incomplete bool) error {
+	note := ""
+	if incomplete {
+		note = " of an incomplete run"
+	}
+	if report.Format == formatJSON {
+		if err := writeJSONReport(os.Stdout, results, incomplete); err != nil {
+			return err
+		}
+	}
+	if report.JUnitPath != "" {
+		if err := writeJUnitReport(report.JUnitPath, results, incomplete); err != nil {
+			return err
+		}
+		fmt.Fprintf(logOutput, "Wrote JUnit report%s to %s\n", note, report.JUnitPath)
+	}
+	if report.CSVPath != "" {
+		if err := writeCSVReport(report.CSVPath, results); err != nil {
+			return err
+		}
+		fmt.Fprintf(logOutput, "Wrote CSV report%s to %s\n", note, report.CSVPath)
+	}
+	if report.ReportDir != "" {
+		if err := writeEnvReports(report.ReportDir, results, incomplete); err != nil {
+			return err
+		}
+		fmt.Fprintf(logOutput, "Wrote per-environment reports%s to %s\n", note, report.ReportDir)
+	}
+	return nil
+}
+
+// checkSuffixLengthOrExit checks -suffix-length is long enough to keep rendered filenames apart
+func checkSuffixLengthOrExit(length int) int {
+	if length < minSuffixLength {
+		fmt.Fprintf(os.Stderr, "Error: -suffix-length must be at least %d\n", minSuffixLength)
+		os.Exit(1)
+	}
+	return length
+}
+
+// parseManifestNameTemplateOrExit parses -manifest-name-template
+func parseManifestNameTemplateOrExit(text string) *template.Template {
+	tmpl, err := parseManifestNameTemplate(text)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in manifest name template: %v\n", err)
+		os.Exit(1)
+	}
+	return tmpl
+}
+
+// loadAppsetFieldMapOrExit loads the -field-map file, an empty path meaning the default keys
+func loadAppsetFieldMapOrExit(path string) appsetFieldMap {
+	if path == "" {
+		return defaultAppsetFieldMap
+	}
+	fields, err := loadAppsetFieldMap(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading field map: %v\n", err)
+		os.Exit(1)
+	}
+	return fields
+}
+
+// configureLoggingOrExit applies -log-level and -log-format, -v meaning -log-level debug
+func configureLoggingOrExit(level, format string, verbose bool) {
+	if verbose {
+		level = "debug"
+	}
+	if err := configureLogging(level, format, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring logging: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadOCIAuthOrExit loads the -oci-auth file, an empty path meaning no OCI auth
+func loadOCIAuthOrExit(path string) map[string]ociRegistryAuth {
+	if path == "" {
+		return nil
+	}
+	auth, err := loadOCIAuth(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading OCI auth: %v\n", err)
+		os.Exit(1)
+	}
+	return auth
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// manifestIndexEntry describes one rendered manifest file and the images extracted from it
+type manifestIndexEntry struct {
+	Env          string   `json:"env"`
+	Chart        string   `json:"chart"`
+	ChartVersion string   `json:"chartVersion"`
+	AppVersion   string   `json:"appVersion,omitempty"`
+	Images       []string `json:"images"`
+}
+
+// manifestIndex maps rendered manifest files to their extracted images. It is
+// filled concurrently by the image extraction workers.
+type manifestIndex struct {
+	entries map[string]manifestIndexEntry
+	lock    sync.Mutex
+}
+
+func newManifestIndex() *manifestIndex {
+	return &manifestIndex{entries: map[string]manifestIndexEntry{}}
+}
+
+// record adds the images extracted from a manifest file, a nil index records nothing
+func (index *manifestIndex) record(manifestFile string, chart ChartRenderParams, images []string) {
+	if index == nil {
+		return
+	}
+	if images == nil {
+		images = []string{}
+	}
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	index.entries[manifestFile] = manifestIndexEntry{
+		Env:          chart.Env,
+		Chart:        chart.ChartName,
+		ChartVersion: chart.ChartVersion,
+		AppVersion:   chart.AppVersion,
+		Images:       images,
+	}
+}
+
+// writeManifestIndex writes the index as a JSON object keyed by manifest file
+func writeManifestIndex(path string, index *manifestIndex) error {
+	index.lock.Lock()
+	jsonData, err := json.MarshalIndent(index.entries, "", "  ")
+	index.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest index: %w", err)
+	}
+	if err := os.WriteFile(path, jsonData, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest index %s: %w", path, err)
+	}
+	return nil
+}
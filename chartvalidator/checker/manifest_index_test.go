@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestIndex(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+        - name: proxy
+          image: envoyproxy/envoy:v1.30
+`)
+
+	outputDir := t.TempDir()
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: outputDir,
+		IndexOut:  filepath.Join(t.TempDir(), "index.json"),
+	})
+	engine.Start(2)
+
+	devChart := createTestChart()
+	prodChart := createTestChart()
+	prodChart.Env = "production"
+	prodChart.ChartName = "other-chart"
+	prodChart.ChartVersion = "2.0.0"
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{devChart, prodChart})
+	collectAppCheckResults(engine)
+
+	indexFile := engine.options.IndexOut
+	assert.NoError(t, writeManifestIndex(indexFile, engine.ImageExtractionEngine.index))
+
+	data, err := os.ReadFile(indexFile)
+	assert.NoError(t, err)
+
+	var index map[string]manifestIndexEntry
+	assert.NoError(t, json.Unmarshal(data, &index))
+	assert.Len(t, index, 2)
+
+	byChart := map[string]manifestIndexEntry{}
+	for manifestFile, entry := range index {
+		assert.True(t, strings.HasPrefix(manifestFile, outputDir), "Expected %s to be a rendered manifest", manifestFile)
+		assert.True(t, strings.HasPrefix(filepath.Base(manifestFile), entry.Env+"_"+entry.Chart+"_"), "Expected %s to belong to chart %s", manifestFile, entry.Chart)
+		byChart[entry.Chart] = entry
+	}
+
+	expectedImages := []string{"nginx:1.25", "envoyproxy/envoy:v1.30"}
+	assert.Equal(t, manifestIndexEntry{Env: "development", Chart: "test-chart", ChartVersion: "1.0.0", Images: expectedImages}, byChart["test-chart"])
+	assert.Equal(t, manifestIndexEntry{Env: "production", Chart: "other-chart", ChartVersion: "2.0.0", Images: expectedImages}, byChart["other-chart"])
+}
+
+func TestManifestIndexRecordsManifestsWithoutImages(t *testing.T) {
+	index := newManifestIndex()
+	index.record("manifests/config.yaml", createTestChart(), nil)
+
+	indexFile := filepath.Join(t.TempDir(), "index.json")
+	assert.NoError(t, writeManifestIndex(indexFile, index))
+
+	data, err := os.ReadFile(indexFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"images": []`)
+}
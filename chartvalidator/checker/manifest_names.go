@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// defaultManifestNameTemplate names rendered manifests <env>_<chart>_<suffix>.yaml.
+// The env is part of the name because the same chart is usually deployed to several envs.
+const defaultManifestNameTemplate = "{{if .Env}}{{.Env}}_{{end}}{{.Chart}}_{{.Suffix}}"
+
+var defaultManifestName = template.Must(template.New("manifest-name").Parse(defaultManifestNameTemplate))
+
+// safeManifestName matches filenames that are safe on any filesystem and stay inside the output dir
+var safeManifestName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// manifestNameData is what -manifest-name-template has access to
+type manifestNameData struct {
+	Env     string
+	Chart   string
+	Version string
+	// Suffix is a hash of the chart, so names using it never collide
+	Suffix string
+}
+
+// parseManifestNameTemplate parses a -manifest-name-template and checks it
+// produces a usable name for a sample chart
+func parseManifestNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("manifest-name").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest name template: %w", err)
+	}
+	sample := ChartRenderParams{Env: "env", ChartName: "chart", ChartVersion: "1.0.0"}
+	if _, err := manifestName(tmpl, sample, "suffix"); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// manifestName builds the rendered manifest filename of a chart, adding the .yaml extension
+func manifestName(tmpl *template.Template, chart ChartRenderParams, suffix string) (string, error) {
+	if tmpl == nil {
+		tmpl = defaultManifestName
+	}
+	var name strings.Builder
+	data := manifestNameData{Env: chart.Env, Chart: chart.ChartName, Version: chart.ChartVersion, Suffix: suffix}
+	if data.Chart != "" {
+		// Vendored charts are named after their directory rather than their path
+		data.Chart = filepath.Base(data.Chart)
+	}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to build manifest name for chart %s: %w", chart.ChartName, err)
+	}
+	if !safeManifestName.MatchString(name.String()) {
+		return "", fmt.Errorf("manifest name %q for chart %s is not a safe filename, use letters, digits, '.', '_' and '-'", name.String(), chart.ChartName)
+	}
+	return name.String() + ".yaml", nil
+}
+
+// chartSuffix hashes everything that identifies a chart into a hex suffix of
+// length characters, at most 64. Occurrences after the first of the same chart
+// hash differently. The release name is only hashed when set, so charts
+// without one keep the suffix they always had.
+func chartSuffix(chart ChartRenderParams, occurrence, length int) string {
+	hash := sha256.New()
+	for _, field := range []string{chart.Env, chart.ChartName, chart.RepoURL, chart.ChartVersion, chart.BaseValuesFile, chart.ValuesOverride, chart.Namespace, chart.InlineValues} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	if chart.ReleaseName != "" {
+		fmt.Fprintf(hash, "release=%s", chart.ReleaseName)
+		hash.Write([]byte{0})
+	}
+	if occurrence > 0 {
+		fmt.Fprintf(hash, "%d", occurrence)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:min(length, sha256.Size*2)]
+}
+
+// checkManifestNamesUnique fails when two charts would be written to the same
+// file. Templates using the suffix aren't checked: a shortened hash can
+// collide, but the renderer then falls back to the full hash.
+func checkManifestNamesUnique(tmpl *template.Template, charts []ChartRenderParams) error {
+	if len(charts) == 0 {
+		return nil
+	}
+	first, err := manifestName(tmpl, charts[0], "a")
+	if err != nil {
+		return err
+	}
+	second, err := manifestName(tmpl, charts[0], "b")
+	if err != nil {
+		return err
+	}
+	if first != second {
+		return nil
+	}
+
+	seen := map[string]ChartRenderParams{}
+	for _, chart := range charts {
+		name, err := manifestName(tmpl, chart, "")
+		if err != nil {
+			return err
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("charts %s %s from env %s and %s %s from env %s would both be rendered to %s, include more of env, chart and version in the manifest name template",
+				other.ChartName, other.ChartVersion, other.Env, chart.ChartName, chart.ChartVersion, chart.Env, name)
+		}
+		seen[name] = chart
+	}
+	return nil
+}
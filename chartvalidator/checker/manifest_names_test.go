@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifestNameDefault(t *testing.T) {
+	chart := createTestChart()
+	name, err := manifestName(nil, chart, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "development_test-chart_abc123.yaml", name)
+
+	chart.Env = ""
+	name, err = manifestName(nil, chart, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-chart_abc123.yaml", name)
+
+	chart.ChartName = "charts/web"
+	name, err = manifestName(nil, chart, "abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, "web_abc123.yaml", name)
+}
+
+func TestRenderWithManifestNameTemplate(t *testing.T) {
+	tmpl, err := parseManifestNameTemplate("{{.Env}}-{{.Chart}}-{{.Version}}")
+	assert.NoError(t, err)
+
+	outputDir := t.TempDir()
+	engine := &ChartRenderingEngine{
+		outputDir:    outputDir,
+		context:      context.Background(),
+		executor:     createMockExecutor(),
+		nameTemplate: tmpl,
+	}
+
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+	production.ChartVersion = "2.0.0"
+
+	first, err := engine.renderSingleChart(development, 0)
+	assert.NoError(t, err)
+	second, err := engine.renderSingleChart(production, 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(outputDir, "development-test-chart-1.0.0.yaml"), first.ManifestPath)
+	assert.Equal(t, filepath.Join(outputDir, "production-test-chart-2.0.0.yaml"), second.ManifestPath)
+	assert.FileExists(t, first.ManifestPath)
+}
+
+func TestParseManifestNameTemplateRejectsUnsafeNames(t *testing.T) {
+	for _, text := range []string{
+		"{{.Env}}/{{.Chart}}",
+		"../{{.Chart}}",
+		"{{.Chart}} {{.Version}}",
+		"",
+		"{{.Namespace}}",
+		"{{.Chart",
+	} {
+		_, err := parseManifestNameTemplate(text)
+		assert.Error(t, err, "Expected %q to be rejected", text)
+	}
+}
+
+func TestCheckManifestNamesUnique(t *testing.T) {
+	development := createTestChart()
+	production := createTestChart()
+	production.Env = "production"
+	charts := []ChartRenderParams{development, production}
+
+	byChart, err := parseManifestNameTemplate("{{.Chart}}")
+	assert.NoError(t, err)
+	assert.EqualError(t, checkManifestNamesUnique(byChart, charts),
+		"charts test-chart 1.0.0 from env development and test-chart 1.0.0 from env production would both be rendered to test-chart.yaml, include more of env, chart and version in the manifest name template")
+
+	byEnvAndChart, err := parseManifestNameTemplate("{{.Env}}_{{.Chart}}")
+	assert.NoError(t, err)
+	assert.NoError(t, checkManifestNamesUnique(byEnvAndChart, charts))
+
+	withSuffix, err := parseManifestNameTemplate("{{.Chart}}_{{.Suffix}}")
+	assert.NoError(t, err)
+	assert.NoError(t, checkManifestNamesUnique(withSuffix, charts), "The suffix keeps names apart")
+	assert.NoError(t, checkManifestNamesUnique(nil, charts))
+}
+
+func TestChartSuffix(t *testing.T) {
+	chart := createTestChart()
+	other := chart
+	other.ChartVersion = "2.0.0"
+
+	assert.Len(t, chartSuffix(chart, 0, 6), 6)
+	assert.Equal(t, chartSuffix(chart, 0, 6), chartSuffix(chart, 0, 6))
+	assert.NotEqual(t, chartSuffix(chart, 0, 6), chartSuffix(other, 0, 6))
+	assert.NotEqual(t, chartSuffix(chart, 0, 6), chartSuffix(chart, 1, 6))
+	assert.Len(t, chartSuffix(chart, 0, 100), 64, "Expected the suffix to be capped at the length of the hash")
+
+	released := chart
+	released.ReleaseName = "web-canary"
+	assert.NotEqual(t, chartSuffix(chart, 0, 6), chartSuffix(released, 0, 6), "Expected releases of one chart to get distinct suffixes")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readYAMLDocuments decodes every document of a (possibly multi-document) YAML file
+func readYAMLDocuments(file string) ([]map[string]any, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var docs []map[string]any
+	decoder := yaml.NewDecoder(f)
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			return nil, err
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+}
+
+// podSpec returns the pod spec of a Pod or of the pod template of a workload, or nil
+func podSpec(doc map[string]any) map[string]any {
+	spec, _ := doc["spec"].(map[string]any)
+	switch str(doc["kind"]) {
+	case "Pod":
+		return spec
+	case "CronJob":
+		jobTemplate, _ := spec["jobTemplate"].(map[string]any)
+		jobSpec, _ := jobTemplate["spec"].(map[string]any)
+		template, _ := jobSpec["template"].(map[string]any)
+		podSpec, _ := template["spec"].(map[string]any)
+		return podSpec
+	default:
+		template, _ := spec["template"].(map[string]any)
+		podSpec, _ := template["spec"].(map[string]any)
+		return podSpec
+	}
+}
+
+// podContainers returns the containers and initContainers of a Pod or of the
+// pod template of a workload
+func podContainers(doc map[string]any) []map[string]any {
+	spec := podSpec(doc)
+
+	var containers []map[string]any
+	for _, field := range []string{"containers", "initContainers"} {
+		list, _ := spec[field].([]any)
+		for _, c := range list {
+			if container, ok := c.(map[string]any); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// engineCounters count the items flowing through an engine. Workers update
+// them while the metrics endpoint reads them, so they are atomic.
+type engineCounters struct {
+	received  atomic.Int64
+	processed atomic.Int64
+	failed    atomic.Int64
+}
+
+// finish counts an item as processed, or as failed when err is set
+func (counters *engineCounters) finish(err error) {
+	if err != nil {
+		counters.failed.Add(1)
+		return
+	}
+	counters.processed.Add(1)
+}
+
+// engineMetrics is a point in time copy of an engine's counters
+type engineMetrics struct {
+	Engine    string
+	Received  int64
+	Processed int64
+	Failed    int64
+}
+
+// InFlight is the number of items an engine has taken in but not finished
+func (metrics engineMetrics) InFlight() int64 {
+	return metrics.Received - metrics.Processed - metrics.Failed
+}
+
+func (counters *engineCounters) snapshot(engine string) engineMetrics {
+	return engineMetrics{
+		Engine:    engine,
+		Received:  counters.received.Load(),
+		Processed: counters.processed.Load(),
+		Failed:    counters.failed.Load(),
+	}
+}
+
+// metrics returns the counters of every engine in the pipeline, in pipeline order
+func (engine *AppCheckerEngine) metrics() []engineMetrics {
+	return []engineMetrics{
+		engine.ChartRenderingEngine.counters.snapshot(engine.ChartRenderingEngine.name),
+		engine.ManifestValidationEngine.counters.snapshot(engine.ManifestValidationEngine.name),
+		engine.ImageExtractionEngine.counters.snapshot(engine.ImageExtractionEngine.name),
+		engine.DockerValidationEngine.counters.snapshot(engine.DockerValidationEngine.name),
+	}
+}
+
+// writePrometheusMetrics writes the engine counters in the Prometheus text format
+func writePrometheusMetrics(w io.Writer, metrics []engineMetrics) {
+	series := []struct {
+		name, kind, help string
+		value            func(engineMetrics) int64
+	}{
+		{"chart_checker_engine_received_total", "counter", "Items taken in by the engine.", func(m engineMetrics) int64 { return m.Received }},
+		{"chart_checker_engine_processed_total", "counter", "Items the engine finished successfully.", func(m engineMetrics) int64 { return m.Processed }},
+		{"chart_checker_engine_failed_total", "counter", "Items the engine finished with an error.", func(m engineMetrics) int64 { return m.Failed }},
+		{"chart_checker_engine_in_flight", "gauge", "Items taken in by the engine and not finished yet.", func(m engineMetrics) int64 { return m.InFlight() }},
+	}
+	for _, s := range series {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", s.name, s.help, s.name, s.kind)
+		for _, m := range metrics {
+			fmt.Fprintf(w, "%s{engine=%q} %d\n", s.name, m.Engine, s.value(m))
+		}
+	}
+}
+
+// newMetricsHandler serves the engine counters on /metrics and a liveness check on /healthz
+func newMetricsHandler(engine *AppCheckerEngine) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePrometheusMetrics(w, engine.metrics())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
+
+// serveMetrics exposes the engine counters on addr until the returned server is closed
+func serveMetrics(addr string, engine *AppCheckerEngine) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+	server := &http.Server{Handler: newMetricsHandler(engine)}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logEngineError("Metrics", -1, fmt.Sprintf("metrics server stopped: %v", err))
+		}
+	}()
+	return server, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppCheckerCountsItemsPerEngine(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+	mockExecutor.FileExistsMap = map[string]bool{"missing.yaml": false}
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir: t.TempDir(),
+	})
+	engine.Start(1)
+
+	broken := createTestChart()
+	broken.ChartName = "broken"
+	broken.BaseValuesFile = "missing.yaml"
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart(), broken})
+	collectAppCheckResults(engine)
+
+	metrics := engine.metrics()
+	assert.Equal(t, engineMetrics{Engine: "ChartRenderer", Received: 2, Processed: 1, Failed: 1}, metrics[0])
+	assert.Equal(t, engineMetrics{Engine: "ManifestValidator", Received: 1, Processed: 1}, metrics[1])
+	assert.Equal(t, engineMetrics{Engine: "ImageExtractor", Received: 1, Processed: 1}, metrics[2])
+	assert.Equal(t, engineMetrics{Engine: "DockerValidator"}, metrics[3])
+	for _, m := range metrics {
+		assert.Zero(t, m.InFlight(), "Expected nothing in flight for %s once the run is done", m.Engine)
+	}
+}
+
+func TestDockerValidationCountsFailures(t *testing.T) {
+	engine := &DockerImageValidationEngine{outputChan: make(chan DockerImageValidationResult, 2)}
+
+	engine.send(DockerImageValidationResult{Image: "nginx:1.25", Exists: true})
+	engine.send(DockerImageValidationResult{Image: "nginx:", Error: fmt.Errorf("malformed image reference")})
+
+	assert.Equal(t, engineMetrics{Engine: "DockerValidator", Processed: 1, Failed: 1}, engine.counters.snapshot("DockerValidator"))
+}
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	var out bytes.Buffer
+	writePrometheusMetrics(&out, []engineMetrics{{Engine: "ChartRenderer", Received: 5, Processed: 3, Failed: 1}})
+
+	assert.Contains(t, out.String(), "# TYPE chart_checker_engine_received_total counter\n")
+	assert.Contains(t, out.String(), `chart_checker_engine_received_total{engine="ChartRenderer"} 5`)
+	assert.Contains(t, out.String(), `chart_checker_engine_processed_total{engine="ChartRenderer"} 3`)
+	assert.Contains(t, out.String(), `chart_checker_engine_failed_total{engine="ChartRenderer"} 1`)
+	assert.Contains(t, out.String(), `chart_checker_engine_in_flight{engine="ChartRenderer"} 1`)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	engine := NewAppCheckerEngine(createTestContext(), createMockExecutor(), AppCheckerOptions{OutputDir: t.TempDir()})
+	engine.ChartRenderingEngine.counters.received.Add(2)
+
+	server := httptest.NewServer(newMetricsHandler(engine))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/metrics")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, string(body), `chart_checker_engine_in_flight{engine="ChartRenderer"} 2`)
+
+	response, err = http.Get(server.URL + "/healthz")
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "ok\n", string(body))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// mutableTagPolicy flags images whose tag can be moved to a different image
+type mutableTagPolicy struct {
+	// Denylist are tags treated as mutable besides latest, e.g. main or stable
+	Denylist []string
+	// DisallowEnvs are the environments where a mutable tag fails the check instead of warning
+	DisallowEnvs []string
+}
+
+// imageTag returns the tag of an image reference, empty when it has none or
+// doesn't parse. digested is set for references pinned to a digest.
+func imageTag(image string) (tag string, digested bool) {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return "", false
+	}
+	return ref.Tag, ref.Digest != ""
+}
+
+// isMutableTag reports whether image floats: it has no tag, which means
+// latest, or is tagged latest. References pinned to a digest never float.
+func isMutableTag(image string) bool {
+	return isMutableTagIn(image, nil)
+}
+
+// isMutableTagIn is isMutableTag with extra tags that count as mutable
+func isMutableTagIn(image string, denylist []string) bool {
+	tag, digested := imageTag(image)
+	if digested {
+		return false
+	}
+	return tag == "" || tag == "latest" || slices.Contains(denylist, tag)
+}
+
+// check returns an error for an image with a mutable tag. A nil policy only flags latest.
+func (policy *mutableTagPolicy) check(image string) error {
+	var denylist []string
+	if policy != nil {
+		denylist = policy.Denylist
+	}
+	if !isMutableTagIn(image, denylist) {
+		return nil
+	}
+	return fmt.Errorf("mutable tag in image %s: pin a version tag or digest", image)
+}
+
+// disallowed reports whether a mutable tag fails the check in env rather than warning
+func (policy *mutableTagPolicy) disallowed(env string) bool {
+	return policy != nil && slices.Contains(policy.DisallowEnvs, env)
+}
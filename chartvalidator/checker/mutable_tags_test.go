@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMutableTag(t *testing.T) {
+	tests := []struct {
+		image   string
+		mutable bool
+	}{
+		{"nginx", true},
+		{"nginx:latest", true},
+		{"nginx:1.2.3", false},
+		{"nginx@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31", false},
+		{"nginx:latest@sha256:0d17b565c37bcbd895e9d92315a05c1c3c9a29f762b011a10c54a66cd53c9b31", false},
+		{"localhost:5000/team/app", true},
+		{"localhost:5000/team/app:2.0", false},
+		{"ghcr.io/org/app:latest", true},
+		{"host:5000/app", true},
+		{"host:5000/app:latest", true},
+		{"host:5000/app:1.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.mutable, isMutableTag(tt.image))
+		})
+	}
+}
+
+func TestMutableTagPolicy(t *testing.T) {
+	policy := &mutableTagPolicy{Denylist: []string{"main", "stable"}, DisallowEnvs: []string{"production"}}
+
+	assert.NoError(t, policy.check("app:1.0"))
+	assert.EqualError(t, policy.check("app:main"), "mutable tag in image app:main: pin a version tag or digest")
+	assert.Error(t, policy.check("app"))
+
+	assert.True(t, policy.disallowed("production"))
+	assert.False(t, policy.disallowed("development"))
+
+	// Without a policy only latest is flagged, and never fatally
+	var none *mutableTagPolicy
+	assert.Error(t, none.check("app:latest"))
+	assert.NoError(t, none.check("app:main"))
+	assert.False(t, none.disallowed("production"))
+}
+
+func TestAppCheckerFlagsMutableTags(t *testing.T) {
+	render := []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:latest
+`)
+
+	tests := []struct {
+		env     string
+		warning bool
+	}{
+		{"development", true},
+		{"production", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			mockExecutor := createMockExecutor()
+			mockExecutor.Output = render
+
+			engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+				OutputDir:   t.TempDir(),
+				MutableTags: &mutableTagPolicy{DisallowEnvs: []string{"production"}},
+			})
+			engine.Start(1)
+
+			chart := createTestChart()
+			chart.Env = tt.env
+			sendChartsToAppChecker(engine, []ChartRenderParams{chart})
+			results := collectAppCheckResults(engine)
+
+			assert.Len(t, results, 1)
+			assert.Equal(t, "nginx:latest", results[0].Image)
+			assert.EqualError(t, results[0].Error, "mutable tag in image nginx:latest: pin a version tag or digest")
+			assert.Equal(t, tt.warning, results[0].Warning)
+			assert.Equal(t, !tt.warning, results[0].failed())
+		})
+	}
+}
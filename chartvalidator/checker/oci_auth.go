@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const ociScheme = "oci://"
+
+// ociRegistryAuth configures how helm authenticates to one OCI registry host.
+// Either a token (read from TokenEnv or TokenFile) is used for `helm registry
+// login`, or an existing helm registry config file is passed to helm template.
+type ociRegistryAuth struct {
+	Username       string `yaml:"username"`
+	TokenEnv       string `yaml:"tokenEnv"`
+	TokenFile      string `yaml:"tokenFile"`
+	RegistryConfig string `yaml:"registryConfig"`
+}
+
+// ociAuthFile is the layout of the -oci-auth file:
+//
+//	registries:
+//	  registry.example.com:
+//	    username: ci
+//	    tokenEnv: REGISTRY_TOKEN
+//	  ghcr.io:
+//	    registryConfig: /home/ci/.config/helm/registry/config.json
+type ociAuthFile struct {
+	Registries map[string]ociRegistryAuth `yaml:"registries"`
+}
+
+// loadOCIAuth reads the per-host OCI registry auth configuration
+func loadOCIAuth(path string) (map[string]ociRegistryAuth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI auth file: %w", err)
+	}
+
+	var file ociAuthFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse OCI auth file %s: %w", path, err)
+	}
+	for host, auth := range file.Registries {
+		if auth.RegistryConfig == "" && auth.TokenEnv == "" && auth.TokenFile == "" {
+			return nil, fmt.Errorf("OCI registry %s needs a tokenEnv, tokenFile or registryConfig", host)
+		}
+	}
+	return file.Registries, nil
+}
+
+// token returns the bearer token for the registry
+func (auth ociRegistryAuth) token() (string, error) {
+	if auth.TokenEnv != "" {
+		token := os.Getenv(auth.TokenEnv)
+		if token == "" {
+			return "", fmt.Errorf("environment variable %s is empty", auth.TokenEnv)
+		}
+		return token, nil
+	}
+	data, err := os.ReadFile(auth.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// ociHost returns the registry host of an oci:// repo URL, or "" for other repos
+func ociHost(repoURL string) string {
+	if !strings.HasPrefix(repoURL, ociScheme) {
+		return ""
+	}
+	host, _, _ := strings.Cut(strings.TrimPrefix(repoURL, ociScheme), "/")
+	return host
+}
+
+// ociLogin makes sure each registry host is logged into at most once
+type ociLogin struct {
+	once sync.Once
+	err  error
+}
+
+// ociLogins tracks the registry logins performed by a rendering engine
+type ociLogins struct {
+	logins map[string]*ociLogin
+	lock   sync.Mutex
+}
+
+// ensure logs helm into the registry host the first time it is called for that host
+func (l *ociLogins) ensure(ctx context.Context, executor CommandExecutor, host string, auth ociRegistryAuth) error {
+	l.lock.Lock()
+	if l.logins == nil {
+		l.logins = map[string]*ociLogin{}
+	}
+	login, ok := l.logins[host]
+	if !ok {
+		login = &ociLogin{}
+		l.logins[host] = login
+	}
+	l.lock.Unlock()
+
+	login.once.Do(func() {
+		token, err := auth.token()
+		if err != nil {
+			login.err = fmt.Errorf("no token for OCI registry %s: %w", host, err)
+			return
+		}
+		username := auth.Username
+		if username == "" {
+			username = "token"
+		}
+		cmd := executor.CommandContext(ctx, "helm", "registry", "login", host, "--username", username, "--password-stdin")
+		cmd.SetStdin(strings.NewReader(token))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			login.err = fmt.Errorf("helm registry login to %s failed: %w: %s", host, err, strings.TrimSpace(string(output)))
+		}
+	})
+	return login.err
+}
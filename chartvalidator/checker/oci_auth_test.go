@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createOCITestChart() ChartRenderParams {
+	chart := createTestChart()
+	chart.RepoURL = "oci://registry.example.com/charts"
+	chart.BaseValuesFile = ""
+	chart.ValuesOverride = ""
+	return chart
+}
+
+func TestRenderOCIChartLogsInBeforeTemplate(t *testing.T) {
+	t.Setenv("TEST_OCI_TOKEN", "s3cr3t")
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		ociAuth: map[string]ociRegistryAuth{
+			"registry.example.com": {Username: "ci", TokenEnv: "TEST_OCI_TOKEN"},
+		},
+	}
+
+	_, err := engine.renderSingleChart(createOCITestChart(), 0)
+	assert.NoError(t, err)
+	_, err = engine.renderSingleChart(createOCITestChart(), 0)
+	assert.NoError(t, err)
+
+	template := "helm template test-chart oci://registry.example.com/charts/test-chart --version 1.0.0 --include-crds"
+	assert.Equal(t, []string{
+		"helm registry login registry.example.com --username ci --password-stdin",
+		template,
+		template,
+	}, mockExecutor.History, "Expected a single login before the first template")
+	assert.Equal(t, []string{"s3cr3t"}, mockExecutor.Stdins)
+}
+
+func TestRenderOCIChartWithRegistryConfig(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		ociAuth: map[string]ociRegistryAuth{
+			"registry.example.com": {RegistryConfig: "/etc/helm/registry.json"},
+		},
+	}
+
+	_, err := engine.renderSingleChart(createOCITestChart(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"helm template test-chart oci://registry.example.com/charts/test-chart --registry-config /etc/helm/registry.json --version 1.0.0 --include-crds",
+	}, mockExecutor.History)
+}
+
+func TestRenderOCIChartLoginFailure(t *testing.T) {
+	t.Setenv("TEST_OCI_TOKEN", "s3cr3t")
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		return []byte("unauthorized"), errors.New("exit status 1")
+	}
+	engine := &ChartRenderingEngine{
+		outputDir: t.TempDir(),
+		context:   context.Background(),
+		executor:  mockExecutor,
+		ociAuth: map[string]ociRegistryAuth{
+			"registry.example.com": {TokenEnv: "TEST_OCI_TOKEN"},
+		},
+	}
+
+	_, err := engine.renderSingleChart(createOCITestChart(), 0)
+	assert.EqualError(t, err, "helm registry login to registry.example.com failed: exit status 1: unauthorized")
+	assert.Len(t, mockExecutor.History, 1, "Template must not run after a failed login")
+}
+
+func TestLoadOCIAuth(t *testing.T) {
+	auth, err := loadOCIAuth(createTempManifestFile(t, t.TempDir(), "oci.yaml", `registries:
+  registry.example.com:
+    username: ci
+    tokenEnv: REGISTRY_TOKEN
+  ghcr.io:
+    registryConfig: /etc/helm/registry.json
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ociRegistryAuth{
+		"registry.example.com": {Username: "ci", TokenEnv: "REGISTRY_TOKEN"},
+		"ghcr.io":              {RegistryConfig: "/etc/helm/registry.json"},
+	}, auth)
+
+	_, err = loadOCIAuth(createTempManifestFile(t, t.TempDir(), "oci.yaml", "registries:\n  ghcr.io:\n    username: ci\n"))
+	assert.Error(t, err)
+}
+
+func TestOCIHost(t *testing.T) {
+	assert.Equal(t, "registry.example.com", ociHost("oci://registry.example.com/charts"))
+	assert.Equal(t, "localhost:5000", ociHost("oci://localhost:5000"))
+	assert.Equal(t, "", ociHost("https://example.com/charts"))
+}
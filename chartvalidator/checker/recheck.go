@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runRecheckCommand(args []string) {
+	fs := flag.NewFlagSet("recheck", flag.ExitOnError)
+
+	var (
+		reportFile = fs.String("report", "", "Prior report, as written by run-checks -ndjson-stdout or -format json.")
+		outFile    = fs.String("out", "", "Write the updated report to this file instead of stdout.")
+		verbose    = fs.Bool("v", false, "Enable verbose logging.")
+	)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: run-manifest-checks recheck -report <prior.json> [flags]")
+		fmt.Println("")
+		fmt.Println("Re-validates only the images reported as missing in a prior report and writes an updated report.")
+		fmt.Println("Failures other than missing images are carried over unchanged.")
+		fmt.Println("")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *reportFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: -report is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	setVerboseLogging(*verbose)
+	// The updated report is written to stdout by default
+	setProgressOutput(true)
+
+	records, err := readResultRecords(*reportFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading report: %v\n", err)
+		os.Exit(1)
+	}
+
+	records = recheckMissingImages(context.Background(), &RealCommandExecutor{}, records)
+
+	out := io.Writer(os.Stdout)
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *outFile, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := writeResultRecords(out, records); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, record := range records {
+		if record.Status == statusFailed {
+			fmt.Fprintln(os.Stderr, "Some checks are still failing.")
+			os.Exit(1)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "All previously missing images now exist.")
+}
+
+// readResultRecords reads the result records of a prior report, either the
+// NDJSON of -ndjson-stdout or the JSON document of -format json
+func readResultRecords(file string) ([]resultRecord, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []resultRecord
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		found, err := decodeResultRecords(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		records = append(records, found...)
+	}
+
+	for i, record := range records {
+		if err := record.validate(); err != nil {
+			return nil, fmt.Errorf("invalid result %d in %s: %w", i+1, file, err)
+		}
+	}
+	return records, nil
+}
+
+// decodeResultRecords decodes one JSON value of a report, which is either a
+// single result record or a -format json report holding all of them. Unknown
+// fields are rejected, so that no other JSON passes for an empty report.
+func decodeResultRecords(value json.RawMessage) ([]resultRecord, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(value, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["results"]; ok {
+		var report jsonReport
+		if err := decodeStrict(value, &report); err != nil {
+			return nil, err
+		}
+		return report.Results, nil
+	}
+	var record resultRecord
+	if err := decodeStrict(value, &record); err != nil {
+		return nil, err
+	}
+	return []resultRecord{record}, nil
+}
+
+// decodeStrict decodes JSON into v, failing on fields v doesn't have
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// validate returns an error for a record missing the fields every result has
+func (record resultRecord) validate() error {
+	if record.Env == "" || record.Chart == "" {
+		return errors.New("result has no env or chart")
+	}
+	switch record.Status {
+	case statusPassed, statusFailed, statusSkipped, statusWarning, statusAccessDenied:
+		return nil
+	case "":
+		return fmt.Errorf("result for chart %s in env %s has no status", record.Chart, record.Env)
+	default:
+		return fmt.Errorf("result for chart %s in env %s has unknown status %q", record.Chart, record.Env, record.Status)
+	}
+}
+
+// writeResultRecords writes the records as NDJSON
+func writeResultRecords(w io.Writer, records []resultRecord) error {
+	encoder := json.NewEncoder(w)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMissingImage reports whether a record failed because its image doesn't
+// exist, rather than e.g. the registry policy or a signature check
+func isMissingImage(record resultRecord) bool {
+	return record.Status == statusFailed && record.Missing && record.Image != "" && checkImageReference(record.Image) == nil
+}
+
+// recheckMissingImages validates each image that was missing in the prior
+// records once and returns the records with their status updated
+func recheckMissingImages(ctx context.Context, executor CommandExecutor, records []resultRecord) []resultRecord {
+	var images []string
+	for _, record := range records {
+		if isMissingImage(record) {
+			images = append(images, record.Image)
+		}
+	}
+	images = removeDuplicates(images)
+	fmt.Fprintf(logOutput, "Re-checking %d missing images.\n", len(images))
+
+	results := validateImages(ctx, executor, images)
+
+	updated := make([]resultRecord, len(records))
+	for i, record := range records {
+		if isMissingImage(record) {
+			result := results[record.Image]
+			switch {
+			case result.Error != nil:
+				record.Error = result.Error.Error()
+			case !result.Exists:
+				record.Error = fmt.Sprintf("docker image does not exist: %s", record.Image)
+			default:
+				record.Status = statusPassed
+				record.Error = ""
+				record.Missing = false
+			}
+			fmt.Fprintf(logOutput, ">>> chart %s %s from env %s with image %s: %s\n", record.Chart, record.ChartVersion, record.Env, record.Image, record.Status)
+		}
+		updated[i] = record
+	}
+	return updated
+}
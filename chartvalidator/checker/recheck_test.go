@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const priorReport = `{"env":"dev","chart":"web","chartVersion":"1.0.0","image":"nginx:1.25","status":"passed"}
+{"env":"dev","chart":"web","chartVersion":"1.0.0","image":"registry.example.com/web:2.0","status":"failed","error":"docker image does not exist: registry.example.com/web:2.0","missing":true}
+{"env":"prod","chart":"api","chartVersion":"3.1.0","image":"registry.example.com/api:3.1","status":"failed","error":"docker image does not exist: registry.example.com/api:3.1","missing":true}
+{"env":"prod","chart":"api","chartVersion":"3.1.0","status":"failed","error":"kubeconform validation failed"}
+{"env":"prod","chart":"worker","chartVersion":"0.1.0","image":"busybox:","status":"failed","error":"malformed image reference \"busybox:\": empty tag or digest"}
+{"env":"prod","chart":"worker","chartVersion":"0.1.0","image":"docker.io/library/busybox:1.36","status":"failed","error":"image docker.io/library/busybox:1.36 is from registry docker.io, which is not allowed"}
+`
+
+func TestRecheckMissingImages(t *testing.T) {
+	reportFile := createTempManifestFile(t, t.TempDir(), "prior.json", priorReport)
+	records, err := readResultRecords(reportFile)
+	assert.NoError(t, err)
+	assert.Len(t, records, 6)
+
+	mockExecutor := createMockExecutor()
+	updated := recheckMissingImages(createTestContext(), mockExecutor, records)
+
+	// Only the missing images are inspected again
+	assert.ElementsMatch(t, []string{
+		"docker manifest inspect registry.example.com/web:2.0",
+		"docker manifest inspect registry.example.com/api:3.1",
+	}, mockExecutor.History)
+
+	assert.Equal(t, records[0], updated[0])
+	assert.Equal(t, resultRecord{Env: "dev", Chart: "web", ChartVersion: "1.0.0", Image: "registry.example.com/web:2.0", Status: statusPassed}, updated[1])
+	assert.Equal(t, resultRecord{Env: "prod", Chart: "api", ChartVersion: "3.1.0", Image: "registry.example.com/api:3.1", Status: statusPassed}, updated[2])
+	assert.Equal(t, records[3], updated[3])
+	assert.Equal(t, records[4], updated[4])
+	assert.Equal(t, records[5], updated[5], "Expected a registry policy failure to stay failed")
+
+	var out bytes.Buffer
+	assert.NoError(t, writeResultRecords(&out, updated))
+	rereadFile := createTempManifestFile(t, t.TempDir(), "updated.json", out.String())
+	reread, err := readResultRecords(rereadFile)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, reread)
+}
+
+func TestRecheckImagesStillMissing(t *testing.T) {
+	reportFile := createTempManifestFile(t, t.TempDir(), "prior.json", priorReport)
+	records, err := readResultRecords(reportFile)
+	assert.NoError(t, err)
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("manifest unknown")
+	mockExecutor.Error = errors.New("exit status 1")
+	updated := recheckMissingImages(createTestContext(), mockExecutor, records)
+
+	assert.Equal(t, statusFailed, updated[1].Status)
+	assert.Equal(t, "docker image does not exist: "+updated[1].Image, updated[1].Error)
+}
+
+func TestReadResultRecordsErrors(t *testing.T) {
+	_, err := readResultRecords(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+
+	invalidFile := createTempManifestFile(t, t.TempDir(), "invalid.json", "not json")
+	_, err = readResultRecords(invalidFile)
+	assert.Error(t, err)
+
+	// Other JSON mustn't pass for a report without missing images
+	otherFile := createTempManifestFile(t, t.TempDir(), "other.json", `{"charts": 3}`)
+	_, err = readResultRecords(otherFile)
+	assert.Error(t, err)
+
+	noStatusFile := createTempManifestFile(t, t.TempDir(), "nostatus.json", `{"env":"dev","chart":"web","chartVersion":"1.0.0"}`)
+	_, err = readResultRecords(noStatusFile)
+	assert.ErrorContains(t, err, "has no status")
+
+	unknownStatusFile := createTempManifestFile(t, t.TempDir(), "unknown.json", `{"env":"dev","chart":"web","chartVersion":"1.0.0","status":"ok"}`)
+	_, err = readResultRecords(unknownStatusFile)
+	assert.ErrorContains(t, err, "unknown status")
+
+	noResultsFile := createTempManifestFile(t, t.TempDir(), "noresults.json", `{"passed":false,"results":[{"passed":false}]}`)
+	_, err = readResultRecords(noResultsFile)
+	assert.Error(t, err)
+}
+
+func TestReadResultRecordsOfJSONReport(t *testing.T) {
+	var report bytes.Buffer
+	assert.NoError(t, writeJSONReport(&report, []AppCheckResult{
+		{Chart: createTestChart(), Image: "nginx:1.25"},
+		{Chart: createTestChart(), Image: "nginx:0.0", Missing: true, Error: errors.New("docker image does not exist: nginx:0.0")},
+		{Chart: createTestChart(), Image: "private.example.com/app:1.0", AccessDenied: true, Unverifiable: true, Error: errors.New("access denied to private.example.com/app:1.0")},
+	}, false))
+	reportFile := createTempManifestFile(t, t.TempDir(), "report.json", report.String())
+
+	records, err := readResultRecords(reportFile)
+
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	assert.False(t, isMissingImage(records[0]))
+	assert.True(t, isMissingImage(records[1]))
+	assert.Equal(t, statusAccessDenied, records[2].Status)
+}
+
+func TestResultRecordMarksMissingImages(t *testing.T) {
+	missing := newResultRecord(AppCheckResult{Chart: createTestChart(), Image: "nginx:1.25", Missing: true, Error: errors.New("docker image does not exist: nginx:1.25")})
+	assert.True(t, isMissingImage(missing))
+
+	denied := newResultRecord(AppCheckResult{Chart: createTestChart(), Image: "nginx:1.25", Error: errors.New("signature verification failed for nginx:1.25")})
+	assert.False(t, isMissingImage(denied), "Expected only images the registry doesn't have to be rechecked")
+}
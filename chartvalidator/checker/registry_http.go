@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestMediaTypes are the manifest formats asked for, so that multi-platform
+// images return their index as docker manifest inspect does
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// tokenExpiryMargin is how long before it expires a token is renewed, so it
+// doesn't run out between being handed out and being used
+const tokenExpiryMargin = 10 * time.Second
+
+// defaultTokenLifetime is the lifetime of tokens that don't say, per the
+// registry token specification
+const defaultTokenLifetime = 60 * time.Second
+
+// registryLookup looks images up in their registry without a command, after
+// being prepared for the whole batch of images
+type registryLookup interface {
+	prepare(images []string)
+	lookup(ctx context.Context, image string) ([]byte, error)
+}
+
+// newRegistryLookup returns the registry lookup of a run, reading credentials
+// from the docker config in dockerConfig when set. Dry runs only log the
+// requests they would make.
+func newRegistryLookup(executor CommandExecutor, dockerConfig string) registryLookup {
+	if dryRun, ok := executor.(*DryRunCommandExecutor); ok {
+		return dryRunRegistryLookup{executor: dryRun}
+	}
+	client := newRegistryClient(executor)
+	client.configDir = dockerConfig
+	return client
+}
+
+// dryRunRegistryLookup logs the manifest request of every image instead of
+// making it. Like dry-run commands, every lookup succeeds.
+type dryRunRegistryLookup struct {
+	executor *DryRunCommandExecutor
+}
+
+func (d dryRunRegistryLookup) prepare(images []string) {}
+
+func (d dryRunRegistryLookup) lookup(ctx context.Context, image string) ([]byte, error) {
+	host, repository, reference, err := registryManifestRef(image)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	d.executor.record(fmt.Sprintf("GET https://%s/v2/%s/manifests/%s", host, repository, reference))
+	return nil, nil
+}
+
+// registryClient looks images up over the registry HTTP API instead of with
+// docker. It keeps one session per registry host, so a batch of images from
+// the same host shares a single token covering all of their repositories.
+// Credentials are read from the docker config, like docker does.
+type registryClient struct {
+	client *http.Client
+	// executor runs the docker credential helpers named in the docker config
+	executor CommandExecutor
+	// configDir holds the docker config.json, DOCKER_CONFIG or ~/.docker when empty
+	configDir string
+
+	config     *dockerConfigFile
+	configErr  error
+	configOnce sync.Once
+
+	sessions map[string]*registrySession
+	lock     sync.Mutex
+}
+
+func newRegistryClient(executor CommandExecutor) *registryClient {
+	return &registryClient{client: &http.Client{}, executor: executor}
+}
+
+// registrySession is the authorization state for one registry host
+type registrySession struct {
+	host string
+	// repositories are the scopes a token is requested for
+	repositories []string
+	// authorization is the Authorization header sent, empty when the host needs none
+	authorization string
+	// expires is when authorization has to be renewed, zero when it doesn't expire
+	expires time.Time
+	// authorized is set once authorization is known, and cleared when a
+	// repository is added or the registry rejects it
+	authorized bool
+	lock       sync.Mutex
+}
+
+// session returns the session of a registry host, creating it on first use
+func (c *registryClient) session(host string) *registrySession {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.sessions == nil {
+		c.sessions = map[string]*registrySession{}
+	}
+	session, ok := c.sessions[host]
+	if !ok {
+		session = &registrySession{host: host}
+		c.sessions[host] = session
+	}
+	return session
+}
+
+// prepare groups the images by registry host and registers every repository
+// with the session of its host, so that each host is asked for one token
+func (c *registryClient) prepare(images []string) {
+	for _, image := range images {
+		host, repository, _, err := registryManifestRef(image)
+		if err != nil {
+			continue
+		}
+		session := c.session(host)
+		session.lock.Lock()
+		session.addRepository(repository)
+		session.lock.Unlock()
+	}
+}
+
+// addRepository adds a repository to the session's scopes. A token fetched
+// before doesn't cover it, so the session has to be authorized again.
+func (session *registrySession) addRepository(repository string) {
+	if !slices.Contains(session.repositories, repository) {
+		session.repositories = append(session.repositories, repository)
+		session.authorized = false
+	}
+}
+
+// invalidate drops an authorization the registry rejected, unless another
+// lookup already replaced it
+func (session *registrySession) invalidate(authorization string) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	if session.authorization == authorization {
+		session.authorized = false
+	}
+}
+
+// lookup fetches the manifest of an image. Like a command's output, the
+// returned output says manifest unknown when the image doesn't exist and
+// unauthorized when the registry denied access.
+func (c *registryClient) lookup(ctx context.Context, image string) ([]byte, error) {
+	host, repository, reference, err := registryManifestRef(image)
+	if err != nil {
+		return []byte(err.Error()), err
+	}
+	session := c.session(host)
+
+	for attempt := 1; ; attempt++ {
+		authorization, err := c.authorize(ctx, session, repository)
+		if err != nil {
+			return []byte(err.Error()), err
+		}
+		status, body, err := c.getManifest(ctx, host, repository, reference, authorization)
+		if err != nil {
+			return []byte(err.Error()), err
+		}
+
+		switch status {
+		case http.StatusOK:
+			return body, nil
+		case http.StatusNotFound:
+			return []byte("manifest unknown: " + strings.TrimSpace(string(body))), fmt.Errorf("registry returned %d", status)
+		case http.StatusUnauthorized:
+			// The token may have expired early or been revoked, so a new one is asked for once
+			if attempt == 1 && authorization != "" {
+				session.invalidate(authorization)
+				continue
+			}
+			return []byte("unauthorized: " + strings.TrimSpace(string(body))), fmt.Errorf("registry returned %d", status)
+		case http.StatusForbidden:
+			return []byte("unauthorized: " + strings.TrimSpace(string(body))), fmt.Errorf("registry returned %d", status)
+		default:
+			return body, fmt.Errorf("registry returned %d", status)
+		}
+	}
+}
+
+// getManifest asks the registry for a manifest, returning the status and body of its answer
+func (c *registryClient) getManifest(ctx context.Context, host, repository, reference, authorization string) (int, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	request.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, body, nil
+}
+
+// authorize returns the Authorization header for the session's repositories,
+// asking the host how to authenticate the first time and again once a token
+// expires. Registries that don't require authentication get none.
+func (c *registryClient) authorize(ctx context.Context, session *registrySession, repository string) (string, error) {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+
+	session.addRepository(repository)
+	if session.authorized && (session.expires.IsZero() || time.Now().Before(session.expires)) {
+		return session.authorization, nil
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/v2/", session.host), nil)
+	if err != nil {
+		return "", err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		session.authorization, session.expires, session.authorized = "", time.Time{}, true
+		return "", nil
+	}
+
+	username, secret, err := c.credentials(ctx, session.host)
+	if err != nil {
+		return "", fmt.Errorf("unauthorized: failed to read credentials for %s: %w", session.host, err)
+	}
+
+	challenge := response.Header.Get("WWW-Authenticate")
+	scheme, _, _ := strings.Cut(challenge, " ")
+	switch {
+	case strings.EqualFold(scheme, "Basic"):
+		if username == "" {
+			return "", fmt.Errorf("unauthorized: %s requires credentials", session.host)
+		}
+		session.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+secret))
+		session.expires = time.Time{}
+	case strings.EqualFold(scheme, "Bearer"):
+		realm, service, err := parseBearerChallenge(challenge)
+		if err != nil {
+			return "", fmt.Errorf("unauthorized: %s: %w", session.host, err)
+		}
+		token, lifetime, err := fetchRegistryToken(ctx, c.client, realm, service, session.repositories, username, secret)
+		if err != nil {
+			return "", err
+		}
+		session.authorization = "Bearer " + token
+		session.expires = time.Now().Add(lifetime - tokenExpiryMargin)
+	default:
+		return "", fmt.Errorf("unauthorized: %s: unsupported authentication challenge %q", session.host, challenge)
+	}
+	session.authorized = true
+	return session.authorization, nil
+}
+
+// parseBearerChallenge reads the token endpoint from a WWW-Authenticate header
+// such as Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseBearerChallenge(header string) (realm, service string, err error) {
+	scheme, params, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", "", fmt.Errorf("unsupported authentication challenge %q", header)
+	}
+	for _, param := range strings.Split(params, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		value = strings.Trim(value, `"`)
+		switch key {
+		case "realm":
+			realm = value
+		case "service":
+			service = value
+		}
+	}
+	if realm == "" {
+		return "", "", fmt.Errorf("authentication challenge %q has no realm", header)
+	}
+	return realm, service, nil
+}
+
+// identityTokenUser is the username docker stores identity tokens under,
+// which are exchanged for registry tokens as OAuth2 refresh tokens
+const identityTokenUser = "<token>"
+
+// fetchRegistryToken asks a token endpoint for a token allowing pulls from every
+// one of the repositories, returning it with its lifetime. Without a username
+// an anonymous token is asked for.
+func fetchRegistryToken(ctx context.Context, client *http.Client, realm, service string, repositories []string, username, secret string) (string, time.Duration, error) {
+	endpoint, err := url.Parse(realm)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid token realm %q: %w", realm, err)
+	}
+	var scopes []string
+	for _, repository := range repositories {
+		scopes = append(scopes, fmt.Sprintf("repository:%s:pull", repository))
+	}
+
+	var request *http.Request
+	if username == identityTokenUser {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {secret},
+			"client_id":     {"chart-checker"},
+			"scope":         {strings.Join(scopes, " ")},
+		}
+		if service != "" {
+			form.Set("service", service)
+		}
+		request, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := endpoint.Query()
+		if service != "" {
+			query.Set("service", service)
+		}
+		for _, scope := range scopes {
+			query.Add("scope", scope)
+		}
+		endpoint.RawQuery = query.Encode()
+		request, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		if username != "" {
+			request.SetBasicAuth(username, secret)
+		}
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("unauthorized: token endpoint %s returned %s", realm, response.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	lifetime := defaultTokenLifetime
+	if body.ExpiresIn > 0 {
+		lifetime = time.Duration(body.ExpiresIn) * time.Second
+	}
+	return token, lifetime, nil
+}
+
+// dockerConfigFile is the part of docker's config.json holding registry credentials
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerHubServer is the server docker stores Docker Hub credentials under
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// loadDockerConfig reads the docker config once. A missing config means no credentials.
+func (c *registryClient) loadDockerConfig() (*dockerConfigFile, error) {
+	c.configOnce.Do(func() {
+		dir := c.configDir
+		if dir == "" {
+			dir = os.Getenv("DOCKER_CONFIG")
+		}
+		if dir == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				c.config = &dockerConfigFile{}
+				return
+			}
+			dir = filepath.Join(home, ".docker")
+		}
+		c.config = &dockerConfigFile{}
+		data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+		if os.IsNotExist(err) {
+			return
+		}
+		if err != nil {
+			c.configErr = err
+			return
+		}
+		if err := json.Unmarshal(data, c.config); err != nil {
+			c.configErr = fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, "config.json"), err)
+		}
+	})
+	return c.config, c.configErr
+}
+
+// credentials returns the username and secret the docker config holds for a
+// registry host, asking its credential helper if it names one. Both are empty
+// when there are none, for anonymous access.
+func (c *registryClient) credentials(ctx context.Context, host string) (string, string, error) {
+	config, err := c.loadDockerConfig()
+	if err != nil {
+		return "", "", err
+	}
+	server := host
+	if dockerConfigHost(host) == "docker.io" {
+		server = dockerHubServer
+	}
+
+	for key, helper := range config.CredHelpers {
+		if dockerConfigHost(key) == dockerConfigHost(host) {
+			return c.helperCredentials(ctx, helper, server)
+		}
+	}
+	for key, auth := range config.Auths {
+		if dockerConfigHost(key) != dockerConfigHost(host) {
+			continue
+		}
+		if auth.IdentityToken != "" {
+			return identityTokenUser, auth.IdentityToken, nil
+		}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid auth for %s in docker config: %w", key, err)
+			}
+			username, secret, _ := strings.Cut(string(decoded), ":")
+			return username, secret, nil
+		}
+	}
+	if config.CredsStore != "" {
+		return c.helperCredentials(ctx, config.CredsStore, server)
+	}
+	return "", "", nil
+}
+
+// helperCredentials asks a docker credential helper for the credentials of a
+// server. A helper that has none means anonymous access.
+func (c *registryClient) helperCredentials(ctx context.Context, helper, server string) (string, string, error) {
+	cmd := c.executor.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.SetStdin(strings.NewReader(server))
+	stdout, stderr, err := cmd.SplitOutput()
+	if err != nil {
+		if strings.Contains(string(stdout)+string(stderr), "credentials not found") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("docker-credential-%s: %w: %s", helper, err, strings.TrimSpace(string(stderr)))
+	}
+	var credentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout, &credentials); err != nil {
+		return "", "", fmt.Errorf("failed to parse docker-credential-%s output: %w", helper, err)
+	}
+	return credentials.Username, credentials.Secret, nil
+}
+
+// dockerConfigHost reduces a docker config server key, which may be a URL,
+// to its host, with every Docker Hub alias as docker.io
+func dockerConfigHost(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host, _, _ := strings.Cut(server, "/")
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return host
+}
+
+// registryManifestRef splits an image into the host serving its registry API,
+// its repository and the tag or digest to ask for
+func registryManifestRef(image string) (host, repository, reference string, err error) {
+	ref, err := parseImageRef(normalizeImageReference(image))
+	if err != nil {
+		return "", "", "", err
+	}
+	host = ref.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	reference = ref.Tag
+	if ref.Digest != "" {
+		reference = ref.Digest
+	}
+	return host, ref.Repository, reference, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRegistry serves the registry API behind a token endpoint, recording how
+// often a token was asked for, with which scopes and by whom. Only the token
+// issued last is accepted.
+type fakeRegistry struct {
+	server *httptest.Server
+	lock   sync.Mutex
+	tokens int
+	scopes []string
+	users  []string
+	valid  string
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	registry := &fakeRegistry{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		registry.lock.Lock()
+		valid := registry.valid
+		registry.lock.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(r.URL.Path, "/missing/") {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"schemaVersion":2}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		registry.lock.Lock()
+		defer registry.lock.Unlock()
+		registry.tokens++
+		registry.scopes = append(registry.scopes, r.URL.Query()["scope"]...)
+		username, _, _ := r.BasicAuth()
+		registry.users = append(registry.users, username)
+		registry.valid = fmt.Sprintf("token-%d", registry.tokens)
+		fmt.Fprintf(w, `{"token":%q,"expires_in":300}`, registry.valid)
+	})
+	registry.server = httptest.NewTLSServer(mux)
+	t.Cleanup(registry.server.Close)
+	return registry
+}
+
+func (registry *fakeRegistry) host() string {
+	return strings.TrimPrefix(registry.server.URL, "https://")
+}
+
+// revoke invalidates the token issued last, as a registry does when it expires
+func (registry *fakeRegistry) revoke() {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	registry.valid = "revoked"
+}
+
+// client returns a registry client for the fake registry using the docker config in configDir
+func (registry *fakeRegistry) client(executor CommandExecutor, configDir string) *registryClient {
+	client := newRegistryClient(executor)
+	client.client = registry.server.Client()
+	client.configDir = configDir
+	return client
+}
+
+func TestRegistryClientBatchSharesToken(t *testing.T) {
+	registry := newFakeRegistry(t)
+	host := registry.host()
+
+	engine := createDockerValidationEngine(createMockExecutor())
+	engine.registryClient = registry.client(createMockExecutor(), t.TempDir())
+	engine.Start(3)
+
+	images := []string{host + "/team/web:1.0", host + "/team/api:2.0", host + "/team/missing:1.0"}
+	go func() {
+		for _, image := range images {
+			engine.inputChan <- ImageExtractionResult{Image: image}
+		}
+		close(engine.inputChan)
+	}()
+	results := collectResults(engine, len(images))
+
+	assert.True(t, results[images[0]].Exists)
+	assert.True(t, results[images[1]].Exists)
+	assert.False(t, results[images[2]].Exists)
+	assert.NoError(t, results[images[0]].Error)
+
+	assert.Equal(t, 1, registry.tokens, "Expected one token for every image of the host")
+	assert.ElementsMatch(t, []string{"repository:team/web:pull", "repository:team/api:pull", "repository:team/missing:pull"}, registry.scopes)
+}
+
+func TestRegistryClientLookup(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := registry.client(createMockExecutor(), t.TempDir())
+
+	output, err := client.lookup(createTestContext(), registry.host()+"/team/web:1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"schemaVersion":2}`, string(output))
+
+	output, err = client.lookup(createTestContext(), registry.host()+"/team/missing:1.0")
+	assert.Error(t, err)
+	assert.True(t, isImageNotFound(string(output)))
+
+	// A repository the token doesn't cover yet needs a new token
+	assert.Equal(t, 2, registry.tokens)
+}
+
+func TestRegistryClientRenewsTokens(t *testing.T) {
+	registry := newFakeRegistry(t)
+	client := registry.client(createMockExecutor(), t.TempDir())
+	image := registry.host() + "/team/web:1.0"
+
+	_, err := client.lookup(createTestContext(), image)
+	assert.NoError(t, err)
+
+	// A token the registry no longer accepts is replaced
+	registry.revoke()
+	_, err = client.lookup(createTestContext(), image)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, registry.tokens)
+
+	// So is a token that expired
+	client.session(registry.host()).expires = time.Now().Add(-time.Second)
+	_, err = client.lookup(createTestContext(), image)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, registry.tokens)
+
+	// A token still valid is reused
+	_, err = client.lookup(createTestContext(), image)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, registry.tokens)
+}
+
+func TestRegistryClientDockerConfigCredentials(t *testing.T) {
+	registry := newFakeRegistry(t)
+	configDir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("ci:secret"))
+	config := fmt.Sprintf(`{"auths":{"https://%s":{"auth":%q}}}`, registry.host(), auth)
+	assert.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0644))
+
+	client := registry.client(createMockExecutor(), configDir)
+	_, err := client.lookup(createTestContext(), registry.host()+"/team/web:1.0")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci"}, registry.users)
+}
+
+func TestRegistryClientCredentialHelper(t *testing.T) {
+	registry := newFakeRegistry(t)
+	configDir := t.TempDir()
+	config := fmt.Sprintf(`{"credHelpers":{%q:"test"}}`, registry.host())
+	assert.NoError(t, os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0644))
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`{"ServerURL":"","Username":"robot","Secret":"s3cr3t"}`)
+	client := registry.client(mockExecutor, configDir)
+	_, err := client.lookup(createTestContext(), registry.host()+"/team/web:1.0")
+
+	assert.NoError(t, err)
+	assertCommandExecution(t, mockExecutor, "docker-credential-test get")
+	assert.Equal(t, []string{registry.host()}, mockExecutor.Stdins)
+	assert.Equal(t, []string{"robot"}, registry.users)
+}
+
+func TestParseBearerChallenge(t *testing.T) {
+	realm, service, err := parseBearerChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://auth.docker.io/token", realm)
+	assert.Equal(t, "registry.docker.io", service)
+
+	_, _, err = parseBearerChallenge(`Basic realm="registry"`)
+	assert.Error(t, err)
+
+	_, _, err = parseBearerChallenge(`Bearer service="registry"`)
+	assert.Error(t, err)
+}
+
+func TestRegistryManifestRef(t *testing.T) {
+	tests := []struct {
+		image      string
+		host       string
+		repository string
+		reference  string
+	}{
+		{"nginx:1.25", "registry-1.docker.io", "library/nginx", "1.25"},
+		{"nginx", "registry-1.docker.io", "library/nginx", "latest"},
+		{"ghcr.io/org/app@sha256:abc123", "ghcr.io", "org/app", "sha256:abc123"},
+		{"localhost:5000/app:1.0@sha256:abc123", "localhost:5000", "app", "sha256:abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			host, repository, reference, err := registryManifestRef(tt.image)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.repository, repository)
+			assert.Equal(t, tt.reference, reference)
+		})
+	}
+}
+
+func TestNewRegistryLookup(t *testing.T) {
+	configDir := t.TempDir()
+	client, ok := newRegistryLookup(&RealCommandExecutor{}, configDir).(*registryClient)
+	assert.True(t, ok)
+	assert.Equal(t, configDir, client.configDir)
+
+	// Dry runs log the request instead of making it
+	dryRun := &DryRunCommandExecutor{}
+	lookup := newRegistryLookup(dryRun, configDir)
+	output, err := lookup.lookup(createTestContext(), "nginx:1.25")
+	assert.NoError(t, err)
+	assert.Empty(t, output)
+	assert.Equal(t, []string{"GET https://registry-1.docker.io/v2/library/nginx/manifests/1.25"}, dryRun.History)
+}
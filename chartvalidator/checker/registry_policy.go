@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// registryPolicy lists the registries images may be pulled from. Entries are
+// a registry host (ghcr.io) or a registry path prefix (docker.io/bitnami) and
+// are matched against the normalized image reference.
+//
+//	default:
+//	  - registry.example.com
+//	environments:
+//	  dev:
+//	    - docker.io
+//	    - registry.example.com
+type registryPolicy struct {
+	Default      []string            `yaml:"default"`
+	Environments map[string][]string `yaml:"environments"`
+}
+
+// loadRegistryPolicy reads the -allowed-registries file
+func loadRegistryPolicy(path string) (*registryPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read allowed registries file: %w", err)
+	}
+
+	var policy registryPolicy
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to parse allowed registries file %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// registryPolicyFromFlags builds the policy of the repeatable
+// -allowed-registries flag. A .yaml or .yml value is loaded as a policy file,
+// any other value is a registry allowed in every environment that restricts
+// registries. No values give a nil policy, allowing everything.
+func registryPolicyFromFlags(values []string) (*registryPolicy, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	policy := &registryPolicy{}
+	var registries []string
+	loadedFile := ""
+	for _, value := range values {
+		if ext := filepath.Ext(value); ext != ".yaml" && ext != ".yml" {
+			registries = append(registries, value)
+			continue
+		}
+		if loadedFile != "" {
+			return nil, fmt.Errorf("only one allowed registries file can be given, got %s and %s", loadedFile, value)
+		}
+		loaded, err := loadRegistryPolicy(value)
+		if err != nil {
+			return nil, err
+		}
+		policy, loadedFile = loaded, value
+	}
+
+	policy.Default = append(policy.Default, registries...)
+	for env, allowed := range policy.Environments {
+		if len(allowed) > 0 {
+			policy.Environments[env] = append(allowed, registries...)
+		}
+	}
+	return policy, nil
+}
+
+// imageRegistry returns the registry host of an image, docker.io for images
+// that don't name one or name it index.docker.io, e.g. ghcr.io for
+// ghcr.io/org/app:v1 and host:5000 for host:5000/repo:tag. It is empty for a
+// reference that doesn't parse.
+func imageRegistry(image string) string {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return ""
+	}
+	if ref.Registry == "" || ref.Registry == "index.docker.io" {
+		return "docker.io"
+	}
+	return ref.Registry
+}
+
+// allowedRegistries returns the allowlist for an environment, falling back to
+// the default list. An empty list means every registry is allowed.
+func (policy *registryPolicy) allowedRegistries(env string) []string {
+	if allowed, ok := policy.Environments[env]; ok {
+		return allowed
+	}
+	return policy.Default
+}
+
+// check returns an error when the image is not from a registry allowed in env.
+// A nil policy allows everything.
+func (policy *registryPolicy) check(env, image string) error {
+	if policy == nil {
+		return nil
+	}
+	allowed := policy.allowedRegistries(env)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	// Entries are a registry host or a path prefix within a registry, both
+	// of which prefix the fully qualified reference
+	normalized := normalizeImageReference(image)
+	for _, entry := range allowed {
+		entry = strings.TrimSuffix(entry, "/")
+		if strings.HasPrefix(normalized, entry+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("image %s is not from a registry allowed in env %s (allowed: %s)", image, env, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const registryPolicyFile = `default:
+  - registry.example.com
+environments:
+  dev:
+    - docker.io
+    - registry.example.com
+  sandbox: []
+`
+
+func TestRegistryPolicyCheck(t *testing.T) {
+	policy, err := loadRegistryPolicy(createTempManifestFile(t, t.TempDir(), "registries.yaml", registryPolicyFile))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		env     string
+		image   string
+		allowed bool
+	}{
+		{"dev", "nginx:1.25", true},
+		{"dev", "registry.example.com/web:1.0", true},
+		{"dev", "ghcr.io/org/app:v1", false},
+		{"prod", "nginx:1.25", false},
+		{"prod", "registry.example.com/web:1.0", true},
+		{"prod", "registry.example.com.evil.io/web:1.0", false},
+		{"sandbox", "ghcr.io/org/app:v1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.env+"/"+tt.image, func(t *testing.T) {
+			err := policy.check(tt.env, tt.image)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRegistryPolicyPathPrefix(t *testing.T) {
+	policy := &registryPolicy{Default: []string{"docker.io/bitnami/"}}
+	assert.NoError(t, policy.check("prod", "bitnami/redis:7.2"))
+	assert.Error(t, policy.check("prod", "redis:7.2"))
+}
+
+func TestNilRegistryPolicyAllowsEverything(t *testing.T) {
+	var policy *registryPolicy
+	assert.NoError(t, policy.check("prod", "nginx:1.25"))
+}
+
+func TestLoadRegistryPolicyRejectsUnknownKeys(t *testing.T) {
+	_, err := loadRegistryPolicy(createTempManifestFile(t, t.TempDir(), "registries.yaml", "enviroments:\n  dev: [docker.io]\n"))
+	assert.Error(t, err)
+}
+
+func TestAppCheckerAppliesRegistryPolicyPerEnv(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+`)
+
+	policy, err := loadRegistryPolicy(createTempManifestFile(t, t.TempDir(), "registries.yaml", registryPolicyFile))
+	assert.NoError(t, err)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:      t.TempDir(),
+		RegistryPolicy: policy,
+	})
+	engine.Start(1)
+
+	devChart := createTestChart()
+	devChart.Env = "dev"
+	prodChart := createTestChart()
+	prodChart.Env = "prod"
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{devChart, prodChart})
+	results := collectAppCheckResults(engine)
+
+	byEnv := map[string]AppCheckResult{}
+	for _, result := range results {
+		byEnv[result.Chart.Env] = result
+	}
+	assert.Len(t, byEnv, 2)
+	assert.NoError(t, byEnv["dev"].Error)
+	assert.EqualError(t, byEnv["prod"].Error, "image nginx:1.25 is not from a registry allowed in env prod (allowed: registry.example.com)")
+}
+
+func TestImageRegistry(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected string
+	}{
+		{"nginx", "docker.io"},
+		{"nginx:1.25", "docker.io"},
+		{"bitnami/redis:7.2", "docker.io"},
+		{"index.docker.io/library/nginx:1.25", "docker.io"},
+		{"ghcr.io/myorg/app:v1", "ghcr.io"},
+		{"host:5000/repo:tag", "host:5000"},
+		{"localhost/app:dev", "localhost"},
+		{"mirror.internal/team/app@sha256:abc", "mirror.internal"},
+		{"host:5000/app", "host:5000"},
+		{"host:5000/app@sha256:abc", "host:5000"},
+		{"nginx:", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, imageRegistry(tt.image))
+		})
+	}
+}
+
+func TestRegistryPolicyFromFlags(t *testing.T) {
+	policy, err := registryPolicyFromFlags(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, policy)
+
+	policy, err = registryPolicyFromFlags([]string{"ghcr.io/myorg", "mirror.internal"})
+	assert.NoError(t, err)
+	assert.NoError(t, policy.check("prod", "ghcr.io/myorg/app:v1"))
+	assert.NoError(t, policy.check("prod", "mirror.internal/nginx:1.25"))
+	assert.Error(t, policy.check("prod", "ghcr.io/otherorg/app:v1"))
+	assert.Error(t, policy.check("prod", "nginx:1.25"))
+
+	file := createTempManifestFile(t, t.TempDir(), "registries.yaml", registryPolicyFile)
+	policy, err = registryPolicyFromFlags([]string{file, "ghcr.io/myorg"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"registry.example.com", "ghcr.io/myorg"}, policy.Default)
+	assert.Equal(t, []string{"docker.io", "registry.example.com", "ghcr.io/myorg"}, policy.Environments["dev"])
+	assert.Empty(t, policy.Environments["sandbox"], "Expected an env allowing every registry to keep doing so")
+
+	_, err = registryPolicyFromFlags([]string{file, file})
+	assert.Error(t, err)
+}
+
+func TestAppCheckerRejectsImagesOutsideAllowedRegistries(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: ghcr.io/myorg/web:1.0
+    - name: proxy
+      image: envoyproxy/envoy:v1.30
+`)
+
+	policy, err := registryPolicyFromFlags([]string{"ghcr.io/myorg"})
+	assert.NoError(t, err)
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:      t.TempDir(),
+		RegistryPolicy: policy,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	byImage := map[string]AppCheckResult{}
+	for _, result := range results {
+		byImage[result.Image] = result
+	}
+	assert.Len(t, byImage, 2)
+	assert.NoError(t, byImage["ghcr.io/myorg/web:1.0"].Error)
+	assert.EqualError(t, byImage["envoyproxy/envoy:v1.30"].Error, "image envoyproxy/envoy:v1.30 is not from a registry allowed in env development (allowed: ghcr.io/myorg)")
+	assert.NotContains(t, mockExecutor.History, "docker manifest inspect envoyproxy/envoy:v1.30", "Expected rejected images not to be looked up")
+}
+
+func TestAppCheckerChecksRegistryPolicyBeforeMirroring(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: ghcr.io/myorg/web:1.0
+    - name: proxy
+      image: envoyproxy/envoy:v1.30
+`)
+
+	mirrors, err := parseRegistryMirrors([]string{"ghcr.io=mirror.internal", "docker.io=mirror.internal"})
+	assert.NoError(t, err)
+	policy, err := registryPolicyFromFlags([]string{"ghcr.io/myorg"})
+	assert.NoError(t, err)
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:       t.TempDir(),
+		RegistryMirrors: mirrors,
+		RegistryPolicy:  policy,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	byImage := map[string]AppCheckResult{}
+	for _, result := range results {
+		byImage[result.OriginalImage] = result
+	}
+	assert.Len(t, byImage, 2)
+	assert.NoError(t, byImage["ghcr.io/myorg/web:1.0"].Error, "Expected the policy to allow the registry the mirror stands in for")
+	assert.Equal(t, "mirror.internal/myorg/web:1.0", byImage["ghcr.io/myorg/web:1.0"].Image)
+	assert.EqualError(t, byImage["envoyproxy/envoy:v1.30"].Error, "image envoyproxy/envoy:v1.30 is not from a registry allowed in env development (allowed: ghcr.io/myorg)")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// registryTool builds the command that looks an image up in its registry.
+// A command that succeeds means the image exists.
+type registryTool interface {
+	// command returns the program and its arguments for inspecting image
+	command(image string) (string, []string)
+}
+
+// rawManifestTool is a registry tool whose default output describes only the
+// image resolved for the host platform. rawCommand prints the manifest list
+// instead, which checking for a required platform needs.
+type rawManifestTool interface {
+	rawCommand(image string) (string, []string)
+}
+
+// dockerRegistryTool uses docker manifest inspect, the default
+type dockerRegistryTool struct{}
+
+func (dockerRegistryTool) command(image string) (string, []string) {
+	return "docker", []string{"manifest", "inspect", image}
+}
+
+// skopeoRegistryTool uses skopeo inspect, which needs no Docker daemon
+type skopeoRegistryTool struct{}
+
+func (skopeoRegistryTool) command(image string) (string, []string) {
+	return "skopeo", []string{"inspect", "docker://" + image}
+}
+
+func (skopeoRegistryTool) rawCommand(image string) (string, []string) {
+	return "skopeo", []string{"inspect", "--raw", "docker://" + image}
+}
+
+// craneRegistryTool uses crane manifest, which needs no Docker daemon
+type craneRegistryTool struct{}
+
+func (craneRegistryTool) command(image string) (string, []string) {
+	return "crane", []string{"manifest", image}
+}
+
+// registryTools are the values of -registry-tool
+var registryTools = map[string]registryTool{
+	"docker": dockerRegistryTool{},
+	"skopeo": skopeoRegistryTool{},
+	"crane":  craneRegistryTool{},
+}
+
+// newRegistryTool returns the registry tool called name
+func newRegistryTool(name string) (registryTool, error) {
+	tool, ok := registryTools[name]
+	if !ok {
+		names := make([]string, 0, len(registryTools))
+		for known := range registryTools {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown registry tool %q, use one of %s", name, strings.Join(names, ", "))
+	}
+	return tool, nil
+}
+
+// createDockerManifestInspectCommand creates the docker command for validating an image
+func createDockerManifestInspectCommand(image string) *exec.Cmd {
+	name, args := dockerRegistryTool{}.command(image)
+	return exec.Command(name, args...)
+}
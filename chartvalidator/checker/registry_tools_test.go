@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryToolCommands(t *testing.T) {
+	tests := []struct {
+		tool         string
+		image        string
+		expectedName string
+		expectedArgs []string
+	}{
+		{"docker", "nginx:1.25", "docker", []string{"manifest", "inspect", "nginx:1.25"}},
+		{"skopeo", "nginx:1.25", "skopeo", []string{"inspect", "docker://nginx:1.25"}},
+		{"skopeo", "registry.example.com/my-app@sha256:abc123", "skopeo", []string{"inspect", "docker://registry.example.com/my-app@sha256:abc123"}},
+		{"crane", "nginx:1.25", "crane", []string{"manifest", "nginx:1.25"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tool+" "+tt.image, func(t *testing.T) {
+			tool, err := newRegistryTool(tt.tool)
+			assert.NoError(t, err)
+
+			mockExecutor := createMockExecutor()
+			engine := createDockerValidationEngine(mockExecutor)
+			engine.registryTool = tool
+
+			result := engine.validateSingleDockerImage(createTestChart(), tt.image, 0)
+
+			assert.True(t, result.Exists)
+			assert.Equal(t, tt.expectedName, mockExecutor.LastCommand)
+			assert.Equal(t, tt.expectedArgs, mockExecutor.LastArgs)
+		})
+	}
+}
+
+func TestRegistryToolDefaultsToDocker(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	engine := createDockerValidationEngine(mockExecutor)
+
+	engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+
+	assertCommandExecution(t, mockExecutor, "docker manifest inspect nginx:1.25")
+}
+
+func TestRegistryToolMissingImage(t *testing.T) {
+	// crane reports registry error codes rather than docker's wording
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("Error: fetching manifest nginx:0.0: GET https://index.docker.io/v2/library/nginx/manifests/0.0: MANIFEST_UNKNOWN: unknown tag=0.0")
+	mockExecutor.Error = fmt.Errorf("exit status 1")
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.registryTool = craneRegistryTool{}
+
+	result := engine.validateSingleDockerImage(createTestChart(), "nginx:0.0", 0)
+
+	assert.False(t, result.Exists)
+	assert.False(t, result.Unverifiable)
+	assert.NoError(t, result.Error)
+}
+
+func TestNewRegistryToolUnknown(t *testing.T) {
+	_, err := newRegistryTool("podman")
+	assert.EqualError(t, err, `unknown registry tool "podman", use one of crane, docker, skopeo`)
+}
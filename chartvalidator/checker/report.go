@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Result statuses used in machine-readable output
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+	statusWarning = "warning"
+	// statusAccessDenied is a failure to check an image because the registry refused the credentials
+	statusAccessDenied = "access-denied"
+)
+
+// Report formats accepted by -format
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// ReportOptions controls how run-checks reports its results
+type ReportOptions struct {
+	// NDJSONStdout writes every result as a JSON line to stdout, moving human output to stderr
+	NDJSONStdout bool
+
+	// Format is text, or json to write a single JSON report to stdout once
+	// all checks are done, moving human output to stderr
+	Format string
+
+	// JUnitPath, when set, is where a JUnit XML report of the results is written
+	JUnitPath string
+
+	// CSVPath, when set, is where a CSV report with a row per result is written
+	CSVPath string
+
+	// ReportDir, when set, is where a JSON and a text report per environment are written
+	ReportDir string
+
+	// GroupByImage prints each image once with the charts referencing it,
+	// once all checks are done, instead of a line per chart and image
+	GroupByImage bool
+
+	// Sorted holds back the results until all checks are done and reports
+	// them sorted by env, chart and image, so runs can be diffed
+	Sorted bool
+
+	// FailFast stops the run at the first failed result
+	FailFast bool
+}
+
+// validate rejects unknown formats and combinations that would both write to stdout
+func (options ReportOptions) validate() error {
+	switch options.Format {
+	case "", formatText:
+	case formatJSON:
+		if options.NDJSONStdout {
+			return fmt.Errorf("-format json and -ndjson-stdout both write to stdout, use one of them")
+		}
+	default:
+		return fmt.Errorf("unknown format %q, expected %s or %s", options.Format, formatText, formatJSON)
+	}
+	return nil
+}
+
+// resultRecord is the machine-readable form of an AppCheckResult
+type resultRecord struct {
+	Env           string        `json:"env"`
+	Chart         string        `json:"chart"`
+	ChartVersion  string        `json:"chartVersion"`
+	AppVersion    string        `json:"appVersion,omitempty"`
+	Image         string        `json:"image,omitempty"`
+	OriginalImage string        `json:"originalImage,omitempty"`
+	Sources       []ImageSource `json:"sources,omitempty"`
+	Status        string        `json:"status"`
+	Error         string        `json:"error,omitempty"`
+	// Missing marks a failure because the registry reported that the image doesn't exist
+	Missing bool `json:"missing,omitempty"`
+}
+
+func newResultRecord(result AppCheckResult) resultRecord {
+	record := resultRecord{
+		Env:           result.Chart.Env,
+		Chart:         result.Chart.ChartName,
+		ChartVersion:  result.Chart.ChartVersion,
+		AppVersion:    result.Chart.AppVersion,
+		Image:         result.Image,
+		OriginalImage: result.OriginalImage,
+		Sources:       result.Sources,
+		Status:        resultStatus(result),
+		Missing:       result.Missing,
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	return record
+}
+
+// jsonReport is the document written by -format json
+type jsonReport struct {
+	Passed bool `json:"passed"`
+	// Incomplete marks a run stopped by -fail-fast or an interrupt before every chart was checked
+	Incomplete bool           `json:"incomplete,omitempty"`
+	Results    []resultRecord `json:"results"`
+}
+
+// writeJSONReport writes every result and the overall outcome as one JSON
+// document. An incomplete run never passed.
+func writeJSONReport(w io.Writer, results []AppCheckResult, incomplete bool) error {
+	report := jsonReport{Passed: !incomplete, Incomplete: incomplete, Results: []resultRecord{}}
+	for _, result := range results {
+		if result.failed() {
+			report.Passed = false
+		}
+		report.Results = append(report.Results, newResultRecord(result))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
+
+// failed reports whether a result fails the run, warnings don't
+func (result AppCheckResult) failed() bool {
+	return result.Error != nil && !result.Warning
+}
+
+// resultStatus classifies a result as passed, failed, access denied, warning or skipped
+func resultStatus(result AppCheckResult) string {
+	switch {
+	case result.Warning:
+		return statusWarning
+	case result.AccessDenied:
+		return statusAccessDenied
+	case result.Error != nil:
+		return statusFailed
+	case result.Skipped:
+		return statusSkipped
+	default:
+		return statusPassed
+	}
+}
+
+// printResult writes the human-readable line for a single result
+func printResult(w io.Writer, result AppCheckResult) {
+	image := result.Image
+	if result.OriginalImage != "" {
+		image = fmt.Sprintf("%s (rewritten from %s)", result.Image, result.OriginalImage)
+	}
+	// Problems with an image point at where the manifest uses it
+	failedImage := image
+	if sources := describeImageSources(result.Sources); sources != "" {
+		failedImage = fmt.Sprintf("%s referenced by %s", image, sources)
+	}
+
+	version := result.Chart.ChartVersion
+	if result.Chart.AppVersion != "" {
+		version = fmt.Sprintf("%s (app %s)", result.Chart.ChartVersion, result.Chart.AppVersion)
+	}
+
+	if result.AccessDenied {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ✗ Access denied: %v\n", result.Chart.ChartName, version, result.Chart.Env, failedImage, result.Error)
+	} else if result.Unverifiable {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ✗ Could not verify: %v\n", result.Chart.ChartName, version, result.Chart.Env, failedImage, result.Error)
+	} else if result.Warning {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ⚠ Warning: %v\n", result.Chart.ChartName, version, result.Chart.Env, failedImage, result.Error)
+	} else if result.Error != nil {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ✗ Error: %v\n", result.Chart.ChartName, version, result.Chart.Env, failedImage, result.Error)
+	} else if result.Skipped && result.Image == "" {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s: ✓ Unchanged from baseline, checks skipped\n", result.Chart.ChartName, version, result.Chart.Env)
+	} else if result.Skipped {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ✓ Unchanged from baseline, checks skipped\n", result.Chart.ChartName, version, result.Chart.Env, image)
+	} else if result.Image == "" {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s: ✓ All checks passed, no images\n", result.Chart.ChartName, version, result.Chart.Env)
+	} else {
+		fmt.Fprintf(w, ">>> chart %s %s from env %s with image %s: ✓ All checks passed\n", result.Chart.ChartName, version, result.Chart.Env, image)
+	}
+}
+
+// reportResults drains the results, printing each one to human and, when
+// ndjson is not nil, writing it there as a JSON line. Returns true when every check passed.
+func reportResults(results <-chan AppCheckResult, human io.Writer, ndjson io.Writer) bool {
+	success := true
+	var encoder *json.Encoder
+	if ndjson != nil {
+		encoder = json.NewEncoder(ndjson)
+	}
+
+	for result := range results {
+		if result.failed() {
+			success = false
+		}
+		printResult(human, result)
+		if encoder != nil {
+			if err := encoder.Encode(newResultRecord(result)); err != nil {
+				logEngineError("Reporter", -1, fmt.Sprintf("failed to write NDJSON result: %v", err))
+			}
+		}
+	}
+	return success
+}
+
+// teeResults forwards every result unchanged, appending each one to
+// collected. collected is complete once the returned channel is closed.
+func teeResults(results <-chan AppCheckResult, collected *[]AppCheckResult) <-chan AppCheckResult {
+	out := make(chan AppCheckResult)
+	go func() {
+		for result := range results {
+			*collected = append(*collected, result)
+			out <- result
+		}
+		close(out)
+	}()
+	return out
+}
+
+// stopAtFirstFailure forwards results up to and including the first failed
+// one, then calls cancel and closes the returned channel. The results still
+// coming in while the engines wind down are drained and dropped.
+func stopAtFirstFailure(results <-chan AppCheckResult, cancel context.CancelFunc) <-chan AppCheckResult {
+	out := make(chan AppCheckResult)
+	go func() {
+		defer close(out)
+		for result := range results {
+			out <- result
+			if result.failed() {
+				cancel()
+				go func() {
+					for range results {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// sortedResults collects every result and forwards them sorted by
+// sortResults once results is closed
+func sortedResults(results <-chan AppCheckResult) <-chan AppCheckResult {
+	out := make(chan AppCheckResult)
+	go func() {
+		var collected []AppCheckResult
+		for result := range results {
+			collected = append(collected, result)
+		}
+		sortResults(collected)
+		for _, result := range collected {
+			out <- result
+		}
+		close(out)
+	}()
+	return out
+}
+
+// sortResults orders results by env, chart name and image. Chart version and
+// error break ties, e.g. between the policy violations of one chart.
+func sortResults(results []AppCheckResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch {
+		case a.Chart.Env != b.Chart.Env:
+			return a.Chart.Env < b.Chart.Env
+		case a.Chart.ChartName != b.Chart.ChartName:
+			return a.Chart.ChartName < b.Chart.ChartName
+		case a.Image != b.Image:
+			return a.Image < b.Image
+		case a.Chart.ChartVersion != b.Chart.ChartVersion:
+			return a.Chart.ChartVersion < b.Chart.ChartVersion
+		}
+		return fmt.Sprint(a.Error) < fmt.Sprint(b.Error)
+	})
+}
+
+// reportRenderResults prints the outcome of every chart of the render-only
+// command until both channels are closed
+func reportRenderResults(w io.Writer, results <-chan RenderResult, renderErrors <-chan ErrorResult) {
+	for results != nil || renderErrors != nil {
+		select {
+		case renderResult, ok := <-results:
+			if !ok {
+				fmt.Fprintln(w, "No more render results.")
+				results = nil
+				continue
+			}
+			fmt.Fprintf(w, ">>> chart %s %s from env %s: ✓ Rendered successfully to %s in %s\n", renderResult.Chart.ChartName, renderResult.Chart.ChartVersion, renderResult.Chart.Env, renderResult.ManifestPath, renderResult.Duration)
+		case renderErr, ok := <-renderErrors:
+			if !ok {
+				renderErrors = nil
+				continue
+			}
+			fmt.Fprintf(w, ">>> chart %s %s from env %s: ✗ Error: %v\n", renderErr.Chart.ChartName, renderErr.Chart.ChartVersion, renderErr.Chart.Env, renderErr.Error)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to turn a slice of results into a closed channel
+func resultsChannel(results []AppCheckResult) <-chan AppCheckResult {
+	ch := make(chan AppCheckResult, len(results))
+	for _, result := range results {
+		ch <- result
+	}
+	close(ch)
+	return ch
+}
+
+func TestReportResultsNDJSON(t *testing.T) {
+	chart := createTestChart()
+	results := []AppCheckResult{
+		{Chart: chart, Image: "nginx:1.20"},
+		{Chart: chart, Image: "redis:6.2", Error: fmt.Errorf("docker image does not exist: redis:6.2")},
+		{Chart: chart, Skipped: true},
+	}
+
+	var stdout, stderr bytes.Buffer
+	success := reportResults(resultsChannel(results), &stderr, &stdout)
+	assert.False(t, success)
+
+	// stdout carries exactly one valid JSON object per result
+	var records []resultRecord
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		var record resultRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record), "Expected valid JSON line: %s", scanner.Text())
+		records = append(records, record)
+	}
+	assert.Len(t, records, 3)
+	assert.Equal(t, resultRecord{Env: "development", Chart: "test-chart", ChartVersion: "1.0.0", Image: "nginx:1.20", Status: statusPassed}, records[0])
+	assert.Equal(t, statusFailed, records[1].Status)
+	assert.Equal(t, "docker image does not exist: redis:6.2", records[1].Error)
+	assert.Equal(t, statusSkipped, records[2].Status)
+	assert.NotContains(t, stdout.String(), ">>>")
+
+	// stderr carries the human-readable lines
+	humanLines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	assert.Len(t, humanLines, 3)
+	assert.Contains(t, humanLines[0], "with image nginx:1.20: ✓ All checks passed")
+	assert.Contains(t, humanLines[1], "with image redis:6.2: ✗ Error")
+	assert.Contains(t, humanLines[2], "Unchanged from baseline")
+}
+
+func TestReportResultsWithoutNDJSON(t *testing.T) {
+	var human bytes.Buffer
+	success := reportResults(resultsChannel([]AppCheckResult{{Chart: createTestChart(), Image: "nginx:1.20"}}), &human, nil)
+
+	assert.True(t, success)
+	assert.Contains(t, human.String(), "✓ All checks passed")
+}
+
+func TestTeeResults(t *testing.T) {
+	input := []AppCheckResult{
+		{Chart: createTestChart(), Image: "nginx:1.25"},
+		{Chart: createTestChart(), Error: fmt.Errorf("failed")},
+	}
+
+	var collected []AppCheckResult
+	var forwarded []AppCheckResult
+	for result := range teeResults(resultsChannel(input), &collected) {
+		forwarded = append(forwarded, result)
+	}
+
+	assert.Equal(t, input, forwarded)
+	assert.Equal(t, input, collected)
+}
+
+func TestReportResultsIncludesAppVersion(t *testing.T) {
+	chart := createTestChart()
+	chart.AppVersion = "2.4.1"
+
+	var human, ndjson bytes.Buffer
+	assert.True(t, reportResults(resultsChannel([]AppCheckResult{{Chart: chart, Image: "nginx:1.20"}}), &human, &ndjson))
+
+	assert.Contains(t, human.String(), ">>> chart test-chart 1.0.0 (app 2.4.1) from env development")
+	var record resultRecord
+	assert.NoError(t, json.Unmarshal(ndjson.Bytes(), &record))
+	assert.Equal(t, "2.4.1", record.AppVersion)
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	chart := createTestChart()
+	results := []AppCheckResult{
+		{Chart: chart, Image: "nginx:1.20"},
+		{Chart: chart, Image: "redis:6.2", Error: fmt.Errorf("docker image does not exist: redis:6.2")},
+	}
+
+	var stdout bytes.Buffer
+	assert.NoError(t, writeJSONReport(&stdout, results, false))
+
+	var report jsonReport
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &report), "Expected a single JSON document: %s", stdout.String())
+	assert.False(t, report.Passed)
+	assert.Equal(t, []resultRecord{
+		{Env: "development", Chart: "test-chart", ChartVersion: "1.0.0", Image: "nginx:1.20", Status: statusPassed},
+		{Env: "development", Chart: "test-chart", ChartVersion: "1.0.0", Image: "redis:6.2", Status: statusFailed, Error: "docker image does not exist: redis:6.2"},
+	}, report.Results)
+}
+
+func TestWriteJSONReportWithoutResults(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.NoError(t, writeJSONReport(&stdout, nil, false))
+	assert.JSONEq(t, `{"passed": true, "results": []}`, stdout.String())
+}
+
+func TestWriteJSONReportOfIncompleteRun(t *testing.T) {
+	var stdout bytes.Buffer
+	assert.NoError(t, writeJSONReport(&stdout, []AppCheckResult{{Chart: createTestChart(), Image: "nginx:1.20"}}, true))
+
+	var report jsonReport
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.True(t, report.Incomplete)
+	assert.False(t, report.Passed, "An incomplete run must not pass")
+	assert.Len(t, report.Results, 1)
+}
+
+func TestReportOptionsValidate(t *testing.T) {
+	assert.NoError(t, ReportOptions{}.validate())
+	assert.NoError(t, ReportOptions{Format: formatText, NDJSONStdout: true}.validate())
+	assert.NoError(t, ReportOptions{Format: formatJSON}.validate())
+	assert.EqualError(t, ReportOptions{Format: "yaml"}.validate(), `unknown format "yaml", expected text or json`)
+	assert.Error(t, ReportOptions{Format: formatJSON, NDJSONStdout: true}.validate())
+}
+
+func TestPrintResultDistinguishesUnverifiableImages(t *testing.T) {
+	chart := createTestChart()
+
+	var out bytes.Buffer
+	printResult(&out, AppCheckResult{Chart: chart, Image: "redis:6.2", Error: fmt.Errorf("docker image does not exist: redis:6.2")})
+	printResult(&out, AppCheckResult{Chart: chart, Image: "nginx:1.25", Unverifiable: true, Error: fmt.Errorf("registry error for nginx:1.25: exit status 1: unauthorized")})
+	printResult(&out, AppCheckResult{Chart: chart, Image: "private:1.0", Unverifiable: true, AccessDenied: true, Error: fmt.Errorf("access denied to private:1.0: exit status 1: denied")})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, ">>> chart test-chart 1.0.0 from env development with image redis:6.2: ✗ Error: docker image does not exist: redis:6.2", lines[0])
+	assert.Equal(t, ">>> chart test-chart 1.0.0 from env development with image nginx:1.25: ✗ Could not verify: registry error for nginx:1.25: exit status 1: unauthorized", lines[1])
+	assert.Equal(t, ">>> chart test-chart 1.0.0 from env development with image private:1.0: ✗ Access denied: access denied to private:1.0: exit status 1: denied", lines[2])
+}
+
+func TestReportResultsWarningsDontFail(t *testing.T) {
+	chart := createTestChart()
+	results := []AppCheckResult{
+		{Chart: chart, Image: "nginx:1.20"},
+		{Chart: chart, Image: "redis:latest", Warning: true, Error: fmt.Errorf("mutable tag in image redis:latest: pin a version tag or digest")},
+	}
+
+	var human, ndjson bytes.Buffer
+	assert.True(t, reportResults(resultsChannel(results), &human, &ndjson))
+	assert.Contains(t, human.String(), "with image redis:latest: ⚠ Warning: mutable tag in image redis:latest")
+	assert.Contains(t, ndjson.String(), `"status":"warning"`)
+
+	var report bytes.Buffer
+	assert.NoError(t, writeJSONReport(&report, results, false))
+	assert.Contains(t, report.String(), `"passed": true`)
+}
+
+func TestReportRenderResultsPrintsOnlyRealResults(t *testing.T) {
+	chart := createTestChart()
+	results := make(chan RenderResult, 1)
+	results <- RenderResult{Chart: chart, ManifestPath: "manifests/development_test-chart.yaml", Duration: time.Second}
+	close(results)
+	renderErrors := make(chan ErrorResult, 1)
+	renderErrors <- ErrorResult{Chart: chart, Error: fmt.Errorf("helm template failed")}
+	close(renderErrors)
+
+	var out bytes.Buffer
+	reportRenderResults(&out, results, renderErrors)
+
+	// The channels are drained in no particular order
+	assert.ElementsMatch(t, []string{
+		">>> chart test-chart 1.0.0 from env development: ✓ Rendered successfully to manifests/development_test-chart.yaml in 1s",
+		">>> chart test-chart 1.0.0 from env development: ✗ Error: helm template failed",
+		"No more render results.",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"), "Expected no line for the closed result channel")
+}
+
+func TestSortedResultsPrintsInOrder(t *testing.T) {
+	dev := createTestChart()
+	api := createTestChart()
+	api.ChartName = "api"
+	prod := createTestChart()
+	prod.Env = "production"
+
+	input := []AppCheckResult{
+		{Chart: prod, Image: "nginx:1.25"},
+		{Chart: dev, Image: "redis:7.2"},
+		{Chart: api, Image: "nginx:1.25"},
+		{Chart: dev, Image: "busybox:1.36"},
+		{Chart: dev, Error: fmt.Errorf("policy violation in b")},
+		{Chart: dev, Error: fmt.Errorf("policy violation in a")},
+	}
+
+	var human bytes.Buffer
+	assert.False(t, reportResults(sortedResults(resultsChannel(input)), &human, nil))
+
+	lines := strings.Split(strings.TrimSpace(human.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.Contains(t, lines[0], ">>> chart api 1.0.0 from env development with image nginx:1.25")
+	assert.Contains(t, lines[1], ">>> chart test-chart 1.0.0 from env development with image :")
+	assert.Contains(t, lines[1], "policy violation in a")
+	assert.Contains(t, lines[2], "policy violation in b")
+	assert.Contains(t, lines[3], "with image busybox:1.36")
+	assert.Contains(t, lines[4], "with image redis:7.2")
+	assert.Contains(t, lines[5], "from env production with image nginx:1.25")
+}
+
+func TestStopAtFirstFailure(t *testing.T) {
+	chart := createTestChart()
+	results := make(chan AppCheckResult)
+	producerDone := make(chan struct{})
+	go func() {
+		results <- AppCheckResult{Chart: chart, Image: "nginx:1.25"}
+		results <- AppCheckResult{Chart: chart, Image: "redis:latest", Warning: true, Error: fmt.Errorf("mutable tag")}
+		results <- AppCheckResult{Chart: chart, Image: "web:1.0", Error: fmt.Errorf("docker image does not exist: web:1.0")}
+		for i := 0; i < 10; i++ {
+			results <- AppCheckResult{Chart: chart, Image: fmt.Sprintf("app:%d", i)}
+		}
+		close(results)
+		close(producerDone)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var forwarded []string
+	for result := range stopAtFirstFailure(results, cancel) {
+		forwarded = append(forwarded, result.Image)
+	}
+
+	assert.Equal(t, []string{"nginx:1.25", "redis:latest", "web:1.0"}, forwarded)
+	assert.Error(t, ctx.Err(), "Expected the run to be cancelled at the first failure")
+	select {
+	case <-producerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the results after the first failure to be drained")
+	}
+}
+
+func TestStopAtFirstFailureCancelsTheEngines(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		time.Sleep(10 * time.Millisecond)
+		return nil, []byte("Error: chart not found"), fmt.Errorf("exit status 1")
+	}
+
+	ctx, cancel := context.WithCancel(createTestContext())
+	defer cancel()
+	engine := NewAppCheckerEngine(ctx, mockExecutor, AppCheckerOptions{OutputDir: t.TempDir()})
+	engine.Start(2)
+
+	var charts []ChartRenderParams
+	for i := 0; i < 100; i++ {
+		chart := createTestChart()
+		chart.ChartName = fmt.Sprintf("chart-%d", i)
+		charts = append(charts, chart)
+	}
+	go func() {
+		for _, chart := range charts {
+			if !sendOrDone(ctx, engine.inputChan, AppCheckInstruction{Chart: chart}) {
+				break
+			}
+		}
+		close(engine.inputChan)
+	}()
+
+	var human bytes.Buffer
+	done := make(chan bool)
+	go func() {
+		done <- reportResults(stopAtFirstFailure(engine.resultChan, cancel), &human, nil)
+	}()
+	select {
+	case passed := <-done:
+		assert.False(t, passed)
+		assert.Equal(t, 1, strings.Count(human.String(), ">>>"), "Expected only the first failure to be reported")
+		assert.Error(t, ctx.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected reporting to stop at the first failure")
+	}
+}
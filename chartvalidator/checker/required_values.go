@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholderSentinels are the values charts and values files conventionally
+// use for settings that must be filled in before deploying
+var placeholderSentinels = []string{"REPLACE_ME", "CHANGE_ME", "CHANGEME"}
+
+// requiredValueFinding describes a required value that is unset, or a
+// placeholder left in the rendered manifest
+type requiredValueFinding struct {
+	Location string
+	Problem  string
+}
+
+func (f requiredValueFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Location, f.Problem)
+}
+
+// checkRequiredValues verifies that every required key is set to a real value
+// in the values passed to helm for the chart, and that the rendered manifest
+// holds no placeholder sentinels. Empty images are already reported by the
+// image extraction stage.
+func checkRequiredValues(chart ChartRenderParams, manifestFile string, keys []string) ([]requiredValueFinding, error) {
+	sources, err := chartValueSources(chart)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []requiredValueFinding
+	for _, key := range keys {
+		value, found := lookupValue(sources, key)
+		location := "value " + key
+		sentinel := placeholderIn(str(value))
+		switch {
+		case !found:
+			findings = append(findings, requiredValueFinding{Location: location, Problem: "not set"})
+		case str(value) == "":
+			findings = append(findings, requiredValueFinding{Location: location, Problem: "empty"})
+		case sentinel != "":
+			findings = append(findings, requiredValueFinding{Location: location, Problem: "set to placeholder " + sentinel})
+		}
+	}
+
+	docs, err := readYAMLDocuments(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	for _, doc := range docs {
+		metadata, _ := doc["metadata"].(map[string]any)
+		prefix := fmt.Sprintf("%s %s", str(doc["kind"]), str(metadata["name"]))
+		findings = append(findings, findPlaceholders(prefix, "", doc)...)
+	}
+	return findings, nil
+}
+
+// chartValueSources reads the values given to helm for a chart, in the order helm applies them
+func chartValueSources(chart ChartRenderParams) ([]map[string]interface{}, error) {
+	var sources []map[string]interface{}
+	for _, valuesFile := range []string{chart.BaseValuesFile, chart.ValuesOverride} {
+		if valuesFile == "" {
+			continue
+		}
+		data, err := os.ReadFile(valuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read values file: %w", err)
+		}
+		values, err := parseValues(data, valuesFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, values)
+	}
+	if chart.InlineValues != "" {
+		values, err := parseValues([]byte(chart.InlineValues), "inline values")
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, values)
+	}
+	return sources, nil
+}
+
+func parseValues(data []byte, name string) (map[string]interface{}, error) {
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return values, nil
+}
+
+// lookupValue returns the value at a dotted path from the last source setting it
+func lookupValue(sources []map[string]interface{}, key string) (interface{}, bool) {
+	var value interface{}
+	found := false
+	for _, source := range sources {
+		if values := valuesAtPath(source, key); len(values) > 0 {
+			value, found = values[0], true
+		}
+	}
+	return value, found
+}
+
+// placeholderIn returns the sentinel contained in value, or an empty string
+func placeholderIn(value string) string {
+	for _, sentinel := range placeholderSentinels {
+		if strings.Contains(value, sentinel) {
+			return sentinel
+		}
+	}
+	return ""
+}
+
+// findPlaceholders walks a decoded document and reports every string holding a sentinel
+func findPlaceholders(prefix, path string, value interface{}) []requiredValueFinding {
+	var findings []requiredValueFinding
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			findings = append(findings, findPlaceholders(prefix, child, v[key])...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			findings = append(findings, findPlaceholders(prefix, fmt.Sprintf("%s[%d]", path, i), item)...)
+		}
+	case string:
+		if sentinel := placeholderIn(v); sentinel != "" {
+			findings = append(findings, requiredValueFinding{
+				Location: fmt.Sprintf("%s %s", prefix, path),
+				Problem:  fmt.Sprintf("placeholder %s rendered", sentinel),
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const placeholderManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+          env:
+            - name: API_URL
+              value: https://REPLACE_ME.example.com
+`
+
+func TestCheckRequiredValues(t *testing.T) {
+	tempDir := t.TempDir()
+	manifest := createTempManifestFile(t, tempDir, "manifest.yaml", configMapManifest)
+
+	tests := []struct {
+		name     string
+		base     string
+		override string
+		inline   string
+		keys     []string
+		expected []string
+	}{
+		{
+			name:     "set in base values",
+			base:     "image:\n  tag: 1.2.3\n",
+			keys:     []string{"image.tag"},
+			expected: nil,
+		},
+		{
+			name:     "unset",
+			base:     "image:\n  repository: nginx\n",
+			keys:     []string{"image.tag"},
+			expected: []string{"value image.tag: not set"},
+		},
+		{
+			name:     "emptied by override",
+			base:     "image:\n  tag: 1.2.3\n",
+			override: "image:\n  tag: \"\"\n",
+			keys:     []string{"image.tag"},
+			expected: []string{"value image.tag: empty"},
+		},
+		{
+			name:     "null",
+			base:     "image:\n  tag:\n",
+			keys:     []string{"image.tag"},
+			expected: []string{"value image.tag: empty"},
+		},
+		{
+			name:     "placeholder",
+			base:     "ingress:\n  host: REPLACE_ME\n",
+			keys:     []string{"ingress.host"},
+			expected: []string{"value ingress.host: set to placeholder REPLACE_ME"},
+		},
+		{
+			name:     "set inline",
+			base:     "image:\n  repository: nginx\n",
+			inline:   "image:\n  tag: 1.2.3\n",
+			keys:     []string{"image.tag"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chart := createTestChart()
+			chart.BaseValuesFile = createTempManifestFile(t, t.TempDir(), "values.yaml", tt.base)
+			chart.ValuesOverride = ""
+			if tt.override != "" {
+				chart.ValuesOverride = createTempManifestFile(t, t.TempDir(), "override.yaml", tt.override)
+			}
+			chart.InlineValues = tt.inline
+
+			findings, err := checkRequiredValues(chart, manifest, tt.keys)
+			assert.NoError(t, err)
+
+			var actual []string
+			for _, finding := range findings {
+				actual = append(actual, finding.String())
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCheckRequiredValuesFindsRenderedPlaceholders(t *testing.T) {
+	chart := createTestChart()
+	chart.BaseValuesFile = createTempManifestFile(t, t.TempDir(), "values.yaml", "replicaCount: 1\n")
+	chart.ValuesOverride = ""
+	manifest := createTempManifestFile(t, t.TempDir(), "manifest.yaml", placeholderManifest)
+
+	findings, err := checkRequiredValues(chart, manifest, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []requiredValueFinding{{
+		Location: "Deployment web spec.template.spec.containers[0].env[0].value",
+		Problem:  "placeholder REPLACE_ME rendered",
+	}}, findings)
+}
+
+func TestAppCheckerReportsUnsetRequiredValues(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(configMapManifest)
+
+	chart := createTestChart()
+	chart.BaseValuesFile = createTempManifestFile(t, t.TempDir(), "values.yaml", "image:\n  repository: nginx\n")
+	chart.ValuesOverride = ""
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:      t.TempDir(),
+		RequiredValues: []string{"image.tag"},
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{chart})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.EqualError(t, results[0].Error, "required values check failed for value image.tag: not set")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// secretKeyPattern matches keys and env var names that usually hold credentials
+var secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key|private[_-]?key|credential)`)
+
+const (
+	// minSecretLength is the shortest literal under a credential-like key that is reported
+	minSecretLength = 8
+	// minEntropyLength and minEntropy bound the high-entropy check for values under other keys
+	minEntropyLength = 24
+	minEntropy       = 4.5
+)
+
+// secretFinding describes a value that looks like a hardcoded credential
+type secretFinding struct {
+	Kind   string
+	Name   string
+	Key    string
+	Reason string
+}
+
+func (f secretFinding) String() string {
+	return fmt.Sprintf("%s %s key %s: %s", f.Kind, f.Name, f.Key, f.Reason)
+}
+
+// detectHardcodedSecrets scans the rendered Secrets and container env values of
+// a manifest file for literal credentials. Heuristics are kept conservative:
+// only credential-like keys with a real value, or long high-entropy values, are reported.
+func detectHardcodedSecrets(manifestFile string) ([]secretFinding, error) {
+	docs, err := readYAMLDocuments(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+
+	var findings []secretFinding
+	for _, doc := range docs {
+		kind := str(doc["kind"])
+		metadata, _ := doc["metadata"].(map[string]any)
+		name := str(metadata["name"])
+
+		if kind == "Secret" {
+			if stringData, ok := doc["stringData"].(map[string]any); ok {
+				for key, value := range stringData {
+					if reason := classifySecretValue(key, str(value)); reason != "" {
+						findings = append(findings, secretFinding{Kind: kind, Name: name, Key: "stringData." + key, Reason: reason})
+					}
+				}
+			}
+			if data, ok := doc["data"].(map[string]any); ok {
+				for key, value := range data {
+					decoded, err := base64.StdEncoding.DecodeString(str(value))
+					if err != nil {
+						continue
+					}
+					if reason := classifySecretValue(key, string(decoded)); reason != "" {
+						findings = append(findings, secretFinding{Kind: kind, Name: name, Key: "data." + key, Reason: reason})
+					}
+				}
+			}
+			continue
+		}
+
+		for _, container := range podContainers(doc) {
+			env, _ := container["env"].([]any)
+			for _, e := range env {
+				envVar, ok := e.(map[string]any)
+				if !ok {
+					continue
+				}
+				// Only literal values matter, valueFrom references are the right way to do it
+				value, ok := envVar["value"].(string)
+				if !ok {
+					continue
+				}
+				envName := str(envVar["name"])
+				if !secretKeyPattern.MatchString(envName) {
+					continue
+				}
+				if reason := classifySecretValue(envName, value); reason != "" {
+					findings = append(findings, secretFinding{Kind: kind, Name: name, Key: fmt.Sprintf("container %s env %s", str(container["name"]), envName), Reason: reason})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// classifySecretValue returns why a value looks like a hardcoded secret, or an empty string
+func classifySecretValue(key, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || looksLikeReference(value) {
+		return ""
+	}
+	if secretKeyPattern.MatchString(key) && len(value) >= minSecretLength {
+		return "literal value under credential-like key"
+	}
+	if len(value) >= minEntropyLength && !strings.ContainsAny(value, " \n") && shannonEntropy(value) >= minEntropy {
+		return "high-entropy literal value"
+	}
+	return ""
+}
+
+// looksLikeReference reports values that point elsewhere rather than containing a secret
+func looksLikeReference(value string) bool {
+	return strings.HasPrefix(value, "$") || strings.HasPrefix(value, "/") || strings.Contains(value, "{{")
+}
+
+// shannonEntropy returns the entropy of a string in bits per character
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, r := range value {
+		counts[r]++
+	}
+	length := float64(len([]rune(value)))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectHardcodedSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name         string
+		manifest     string
+		expectedKeys []string
+	}{
+		{
+			name: "secret with literal password",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2hunter2
+  username: admin
+`,
+			expectedKeys: []string{"stringData.password"},
+		},
+		{
+			name: "secret with base64 encoded token",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+data:
+  token: c3VwZXItc2VjcmV0LXRva2Vu
+`,
+			expectedKeys: []string{"data.token"},
+		},
+		{
+			name: "secret with empty and templated values",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: ""
+  apiKey: "${API_KEY}"
+`,
+			expectedKeys: nil,
+		},
+		{
+			name: "deployment with literal env credential",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: nginx:1.20
+        env:
+        - name: DB_PASSWORD
+          value: correcthorsebattery
+        - name: LOG_LEVEL
+          value: debug
+        - name: API_TOKEN
+          valueFrom:
+            secretKeyRef:
+              name: api
+              key: token
+`,
+			expectedKeys: []string{"container app env DB_PASSWORD"},
+		},
+		{
+			name: "configmap is ignored",
+			manifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  password: notreallyasecret
+`,
+			expectedKeys: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := createTempManifestFile(t, tempDir, tt.name+".yaml", tt.manifest)
+
+			findings, err := detectHardcodedSecrets(manifestPath)
+			assert.NoError(t, err)
+
+			var keys []string
+			for _, finding := range findings {
+				keys = append(keys, finding.Key)
+			}
+			assert.Equal(t, tt.expectedKeys, keys)
+		})
+	}
+}
+
+func TestAppCheckerReportsHardcodedSecrets(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2hunter2
+`)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:     t.TempDir(),
+		DetectSecrets: true,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	assert.Len(t, results, 1)
+	assert.Error(t, results[0].Error)
+	assert.Contains(t, results[0].Error.Error(), "possible hardcoded secret in Secret db key stringData.password")
+}
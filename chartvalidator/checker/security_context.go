@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+)
+
+// securityContextFinding describes a container that does not meet the security context requirements
+type securityContextFinding struct {
+	Kind      string
+	Name      string
+	Container string
+	Reason    string
+}
+
+func (f securityContextFinding) String() string {
+	return fmt.Sprintf("%s %s container %s: %s", f.Kind, f.Name, f.Container, f.Reason)
+}
+
+// checkSecurityContexts reports containers of the workloads in a manifest file
+// that are privileged, run as root, or do not set runAsNonRoot: true and
+// allowPrivilegeEscalation: false. Container settings override the pod's.
+func checkSecurityContexts(manifestFile string) ([]securityContextFinding, error) {
+	docs, err := readYAMLDocuments(manifestFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+
+	var findings []securityContextFinding
+	for _, doc := range docs {
+		spec := podSpec(doc)
+		if spec == nil {
+			continue
+		}
+		metadata, _ := doc["metadata"].(map[string]any)
+		podContext, _ := spec["securityContext"].(map[string]any)
+
+		for _, container := range podContainers(doc) {
+			containerContext, _ := container["securityContext"].(map[string]any)
+			for _, reason := range securityContextViolations(podContext, containerContext) {
+				findings = append(findings, securityContextFinding{
+					Kind:      str(doc["kind"]),
+					Name:      str(metadata["name"]),
+					Container: str(container["name"]),
+					Reason:    reason,
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// securityContextViolations returns why the effective security context of a container is rejected
+func securityContextViolations(podContext, containerContext map[string]any) []string {
+	effective := func(key string) (any, bool) {
+		if value, ok := containerContext[key]; ok {
+			return value, true
+		}
+		if value, ok := podContext[key]; ok {
+			return value, true
+		}
+		return nil, false
+	}
+
+	var reasons []string
+	if privileged, _ := containerContext["privileged"].(bool); privileged {
+		reasons = append(reasons, "privileged")
+	}
+	if runAsUser, ok := effective("runAsUser"); ok && fmt.Sprint(runAsUser) == "0" {
+		reasons = append(reasons, "runs as root (runAsUser: 0)")
+	}
+	if runAsNonRoot, _ := effective("runAsNonRoot"); runAsNonRoot != true {
+		reasons = append(reasons, "runAsNonRoot is not set to true")
+	}
+	if escalation, ok := containerContext["allowPrivilegeEscalation"]; !ok || escalation != false {
+		reasons = append(reasons, "allowPrivilegeEscalation is not set to false")
+	}
+	return reasons
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSecurityContexts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := []struct {
+		name            string
+		manifest        string
+		expectedReasons []string
+	}{
+		{
+			name: "compliant deployment with pod level runAsNonRoot",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+        runAsUser: 1000
+      containers:
+        - name: app
+          image: nginx:1.25
+          securityContext:
+            allowPrivilegeEscalation: false
+`,
+			expectedReasons: nil,
+		},
+		{
+			name: "privileged container",
+			manifest: `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - name: shell
+      image: busybox:1.36
+      securityContext:
+        privileged: true
+        runAsNonRoot: true
+        allowPrivilegeEscalation: false
+`,
+			expectedReasons: []string{"privileged"},
+		},
+		{
+			name: "container overriding pod to run as root",
+			manifest: `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          securityContext:
+            runAsNonRoot: true
+          containers:
+            - name: backup
+              image: backup:1.0
+              securityContext:
+                runAsUser: 0
+                runAsNonRoot: false
+                allowPrivilegeEscalation: false
+`,
+			expectedReasons: []string{"runs as root (runAsUser: 0)", "runAsNonRoot is not set to true"},
+		},
+		{
+			name: "missing security context",
+			manifest: `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox:1.36
+          securityContext:
+            runAsNonRoot: true
+            allowPrivilegeEscalation: false
+      containers:
+        - name: db
+          image: postgres:16
+`,
+			expectedReasons: []string{"runAsNonRoot is not set to true", "allowPrivilegeEscalation is not set to false"},
+		},
+		{
+			name: "non workload resources are ignored",
+			manifest: `apiVersion: v1
+kind: Service
+metadata:
+  name: app
+spec:
+  ports:
+    - port: 80
+`,
+			expectedReasons: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFile := createTempManifestFile(t, tempDir, "manifest.yaml", tt.manifest)
+
+			findings, err := checkSecurityContexts(manifestFile)
+			assert.NoError(t, err)
+
+			var reasons []string
+			for _, finding := range findings {
+				reasons = append(reasons, finding.Reason)
+			}
+			assert.Equal(t, tt.expectedReasons, reasons)
+		})
+	}
+}
+
+func TestAppCheckerReportsInsecureSecurityContext(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+    - name: shell
+      image: busybox:1.36
+      securityContext:
+        privileged: true
+        runAsNonRoot: true
+        allowPrivilegeEscalation: false
+`)
+
+	engine := NewAppCheckerEngine(createTestContext(), mockExecutor, AppCheckerOptions{
+		OutputDir:              t.TempDir(),
+		RequireSecurityContext: true,
+	})
+	engine.Start(1)
+
+	sendChartsToAppChecker(engine, []ChartRenderParams{createTestChart()})
+	results := collectAppCheckResults(engine)
+
+	var failures []string
+	for _, result := range results {
+		if result.Error != nil {
+			failures = append(failures, result.Error.Error())
+		}
+	}
+	assert.Equal(t, []string{"insecure security context in Pod debug container shell: privileged"}, failures)
+}
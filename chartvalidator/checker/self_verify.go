@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deepScanImages returns every string held by an image key anywhere in a
+// document, whatever its kind. It knows nothing about where each kind keeps
+// its images, which makes it a cross-check for the extractor.
+func deepScanImages(value interface{}) []string {
+	var images []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if image, ok := child.(string); ok && key == "image" && image != "" {
+				images = append(images, image)
+				continue
+			}
+			images = append(images, deepScanImages(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			images = append(images, deepScanImages(child)...)
+		}
+	}
+	return images
+}
+
+// deepScanImagesFromFile deep scans every document of a rendered manifest
+func deepScanImagesFromFile(file string) ([]string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var images []string
+	for _, document := range splitYAMLDocuments(string(content)) {
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(document), &doc); err != nil {
+			// The extraction pass already reports documents that don't parse
+			continue
+		}
+		images = append(images, deepScanImages(doc)...)
+	}
+	return removeDuplicates(images), nil
+}
+
+// imageDiscrepancies describes every image found by only one of the extraction and deep scan passes
+func imageDiscrepancies(extracted, deepScanned []string) []string {
+	var discrepancies []string
+	for _, image := range removeDuplicates(deepScanned) {
+		if !slices.Contains(extracted, image) {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s found by deep scan but not extracted", image))
+		}
+	}
+	for _, image := range removeDuplicates(extracted) {
+		if !slices.Contains(deepScanned, image) {
+			discrepancies = append(discrepancies, fmt.Sprintf("%s extracted but not found by deep scan", image))
+		}
+	}
+	return discrepancies
+}
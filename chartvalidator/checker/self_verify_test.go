@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// widgetManifest has an image in a custom resource the extractor doesn't know
+const widgetManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.20
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+spec:
+  image: widget:1.0
+`
+
+func TestDeepScanImages(t *testing.T) {
+	doc := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"image": "app:1.0",
+			"sidecars": []interface{}{
+				map[string]interface{}{"image": "proxy:2.0"},
+				map[string]interface{}{"image": map[string]interface{}{"repository": "nested", "tag": "1.0"}},
+			},
+		},
+	}
+
+	assert.ElementsMatch(t, []string{"app:1.0", "proxy:2.0"}, deepScanImages(doc))
+}
+
+func TestImageDiscrepancies(t *testing.T) {
+	assert.Empty(t, imageDiscrepancies([]string{"nginx:1.20"}, []string{"nginx:1.20"}))
+	assert.Equal(t, []string{
+		"widget:1.0 found by deep scan but not extracted",
+		"custom:1.0 extracted but not found by deep scan",
+	}, imageDiscrepancies([]string{"nginx:1.20", "custom:1.0"}, []string{"nginx:1.20", "widget:1.0"}))
+}
+
+func TestSelfVerifyReportsImagesOnlyFoundByDeepScan(t *testing.T) {
+	logs := captureLogs(t, "info")
+
+	engine := createImageExtractionEngine()
+	engine.errorChan = make(chan ErrorResult, 10)
+	engine.selfVerify = true
+	engine.Start(1)
+
+	manifestPath := createTempManifestFile(t, t.TempDir(), "widget.yaml", widgetManifest)
+	results := processEngineWithManifest(t, engine, manifestPath)
+
+	assert.Len(t, results, 1, "Expected self-verify not to change what is extracted")
+	assert.Equal(t, "nginx:1.20", results[0].Image)
+	assert.Contains(t, logs.String(), "self-verify: widget:1.0 found by deep scan but not extracted in "+manifestPath)
+	assert.NotContains(t, logs.String(), "nginx:1.20 found by deep scan")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// signatureVerifier checks image signatures with cosign, either against a
+// public key or, keyless, against the identity that signed the image
+type signatureVerifier struct {
+	// Key is a cosign public key, e.g. a file path or a KMS URI
+	Key string
+	// Identity and Issuer identify the signer of keyless signatures
+	Identity string
+	Issuer   string
+}
+
+// newSignatureVerifier checks that either a key or a complete keyless identity is configured
+func newSignatureVerifier(key, identity, issuer string) (*signatureVerifier, error) {
+	if key != "" && (identity != "" || issuer != "") {
+		return nil, fmt.Errorf("use either a cosign key or a keyless identity and issuer, not both")
+	}
+	if key == "" && (identity == "" || issuer == "") {
+		return nil, fmt.Errorf("signature verification needs a cosign key, or both a keyless identity and issuer")
+	}
+	return &signatureVerifier{Key: key, Identity: identity, Issuer: issuer}, nil
+}
+
+func (verifier *signatureVerifier) args(image string) []string {
+	args := []string{"verify"}
+	if verifier.Key != "" {
+		args = append(args, "--key", verifier.Key)
+	} else {
+		args = append(args, "--certificate-identity", verifier.Identity, "--certificate-oidc-issuer", verifier.Issuer)
+	}
+	return append(args, image)
+}
+
+// verify runs cosign verify for the image, returning an error when the image
+// is unsigned or its signature doesn't match the configured key or identity
+func (verifier *signatureVerifier) verify(ctx context.Context, executor CommandExecutor, image string) error {
+	cmd := executor.CommandContext(ctx, "cosign", verifier.args(image)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("signature verification failed for %s: %w: %s", image, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSignatureVerifier(t *testing.T) {
+	verifier, err := newSignatureVerifier("cosign.pub", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"verify", "--key", "cosign.pub", "nginx:1.25"}, verifier.args("nginx:1.25"))
+
+	verifier, err = newSignatureVerifier("", "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main", "https://token.actions.githubusercontent.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"verify",
+		"--certificate-identity", "https://github.com/org/repo/.github/workflows/release.yml@refs/heads/main",
+		"--certificate-oidc-issuer", "https://token.actions.githubusercontent.com",
+		"nginx:1.25",
+	}, verifier.args("nginx:1.25"))
+
+	_, err = newSignatureVerifier("", "", "")
+	assert.Error(t, err)
+	_, err = newSignatureVerifier("", "someone@example.com", "")
+	assert.Error(t, err, "Keyless verification needs an issuer as well")
+	_, err = newSignatureVerifier("cosign.pub", "someone@example.com", "https://accounts.example.com")
+	assert.Error(t, err)
+}
+
+func TestDockerValidationVerifiesSignatures(t *testing.T) {
+	tests := []struct {
+		name          string
+		cosignOutput  string
+		cosignError   error
+		expectedError string
+	}{
+		{
+			name:         "signed image",
+			cosignOutput: "Verification for nginx:1.25 --\nThe following checks were performed on each of these signatures:\n",
+		},
+		{
+			name:          "unsigned image",
+			cosignOutput:  "Error: no signatures found\n",
+			cosignError:   fmt.Errorf("exit status 1"),
+			expectedError: "signature verification failed for nginx:1.25: exit status 1: Error: no signatures found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockExecutor := createMockExecutor()
+			mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+				if mockExecutor.LastCommand == "docker" {
+					return []byte(`{"schemaVersion": 2}`), nil
+				}
+				return []byte(tt.cosignOutput), tt.cosignError
+			}
+			engine := createDockerValidationEngine(mockExecutor)
+			engine.signatures = &signatureVerifier{Key: "cosign.pub"}
+
+			result := engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+
+			assert.True(t, result.Exists)
+			if tt.expectedError == "" {
+				assert.NoError(t, result.Error)
+			} else {
+				assert.EqualError(t, result.Error, tt.expectedError)
+			}
+			assert.Equal(t, []string{"docker manifest inspect nginx:1.25", "cosign verify --key cosign.pub nginx:1.25"}, mockExecutor.History)
+		})
+	}
+}
+
+func TestDockerValidationSkipsSignaturesOfMissingImages(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("no such manifest: docker.io/library/nginx:1.25")
+	mockExecutor.Error = fmt.Errorf("exit status 1")
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.signatures = &signatureVerifier{Key: "cosign.pub"}
+
+	result := engine.validateSingleDockerImage(createTestChart(), "nginx:1.25", 0)
+
+	assert.False(t, result.Exists)
+	assert.Equal(t, []string{"docker manifest inspect nginx:1.25"}, mockExecutor.History)
+}
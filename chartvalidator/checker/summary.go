@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// StatusCounts counts results by status
+type StatusCounts struct {
+	Passed   int `json:"passed"`
+	Failed   int `json:"failed"`
+	Skipped  int `json:"skipped"`
+	Warnings int `json:"warnings"`
+}
+
+// Total returns the number of results counted
+func (counts StatusCounts) Total() int {
+	return counts.Passed + counts.Failed + counts.Skipped + counts.Warnings
+}
+
+// String lists the counts, warnings only when there are any
+func (counts StatusCounts) String() string {
+	text := fmt.Sprintf("%d passed, %d failed, %d skipped", counts.Passed, counts.Failed, counts.Skipped)
+	if counts.Warnings > 0 {
+		text += fmt.Sprintf(", %d warning(s)", counts.Warnings)
+	}
+	return text
+}
+
+func (counts *StatusCounts) add(status string) {
+	switch status {
+	case statusPassed:
+		counts.Passed++
+	case statusFailed, statusAccessDenied:
+		counts.Failed++
+	case statusSkipped:
+		counts.Skipped++
+	case statusWarning:
+		counts.Warnings++
+	}
+}
+
+// FailureCounts counts failed results by what failed
+type FailureCounts struct {
+	Render       int `json:"render"`
+	Validation   int `json:"validation"`
+	MissingImage int `json:"missingImage"`
+	Other        int `json:"other"`
+}
+
+// String lists the counts of every category
+func (counts FailureCounts) String() string {
+	return fmt.Sprintf("%d render error(s), %d validation error(s), %d missing image(s), %d other",
+		counts.Render, counts.Validation, counts.MissingImage, counts.Other)
+}
+
+// add counts a result if it failed. Other covers the remaining checks, e.g.
+// policies, unverifiable images or manifests whose images can't be extracted.
+func (counts *FailureCounts) add(result AppCheckResult) {
+	if status := resultStatus(result); status != statusFailed && status != statusAccessDenied {
+		return
+	}
+	switch {
+	case result.Missing:
+		counts.MissingImage++
+	case result.Stage == stageRender:
+		counts.Render++
+	case result.Stage == stageValidation:
+		counts.Validation++
+	default:
+		counts.Other++
+	}
+}
+
+// Summary condenses a run's results into counts overall and per environment,
+// and the set of unique images that were checked
+type Summary struct {
+	StatusCounts
+	Environments map[string]StatusCounts `json:"environments"`
+	Images       []string                `json:"images"`
+	// Charts is the number of charts processed and ImageChecks the number of image results
+	Charts      int           `json:"charts"`
+	ImageChecks int           `json:"imageChecks"`
+	Failures    FailureCounts `json:"failures"`
+	// Elapsed is how long the run took, set once it is done
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Aggregate summarizes a slice of results
+func Aggregate(results []AppCheckResult) Summary {
+	summary := Summary{Environments: map[string]StatusCounts{}, Images: []string{}}
+	images := map[string]bool{}
+	charts := map[ChartRenderParams]bool{}
+
+	for _, result := range results {
+		status := resultStatus(result)
+		summary.add(status)
+		summary.Failures.add(result)
+		charts[chartKey(result.Chart)] = true
+		if result.Image != "" {
+			summary.ImageChecks++
+		}
+
+		env := summary.Environments[result.Chart.Env]
+		env.add(status)
+		summary.Environments[result.Chart.Env] = env
+
+		if result.Image != "" && !images[result.Image] {
+			images[result.Image] = true
+			summary.Images = append(summary.Images, result.Image)
+		}
+	}
+	sort.Strings(summary.Images)
+	summary.Charts = len(charts)
+	return summary
+}
+
+// printSummary writes the overall and per-environment counts
+func printSummary(w io.Writer, summary Summary) {
+	fmt.Fprintf(w, "Summary: %s across %d environment(s), %d unique image(s)\n",
+		summary.StatusCounts, len(summary.Environments), len(summary.Images))
+
+	envs := make([]string, 0, len(summary.Environments))
+	for env := range summary.Environments {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+	for _, env := range envs {
+		counts := summary.Environments[env]
+		fmt.Fprintf(w, "  %s: %s\n", env, counts)
+	}
+
+	processed := fmt.Sprintf("Processed %d chart(s) and %d image check(s)", summary.Charts, summary.ImageChecks)
+	if summary.Elapsed > 0 {
+		processed += fmt.Sprintf(" in %s", summary.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, processed)
+	if summary.Failed > 0 {
+		fmt.Fprintf(w, "Failures: %s\n", summary.Failures)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	dev := createTestChart()
+	prod := createTestChart()
+	prod.Env = "production"
+
+	summary := Aggregate([]AppCheckResult{
+		{Chart: dev, Image: "nginx:1.25"},
+		{Chart: dev, Image: "busybox:1.36"},
+		{Chart: dev, Image: "registry.example.com/web:2.0", Missing: true, Error: errors.New("docker image does not exist: registry.example.com/web:2.0")},
+		{Chart: prod, Image: "nginx:1.25"},
+		{Chart: prod, Stage: stageValidation, Error: errors.New("kubeconform validation failed")},
+		{Chart: prod, Skipped: true},
+	})
+
+	assert.Equal(t, StatusCounts{Passed: 3, Failed: 2, Skipped: 1}, summary.StatusCounts)
+	assert.Equal(t, 6, summary.Total())
+	assert.Equal(t, map[string]StatusCounts{
+		"development": {Passed: 2, Failed: 1},
+		"production":  {Passed: 1, Failed: 1, Skipped: 1},
+	}, summary.Environments)
+	assert.Equal(t, []string{"busybox:1.36", "nginx:1.25", "registry.example.com/web:2.0"}, summary.Images)
+	assert.Equal(t, 2, summary.Charts)
+	assert.Equal(t, 4, summary.ImageChecks)
+	assert.Equal(t, FailureCounts{Validation: 1, MissingImage: 1}, summary.Failures)
+
+	summary.Elapsed = 1500 * time.Millisecond
+	var out bytes.Buffer
+	printSummary(&out, summary)
+	assert.Equal(t, `Summary: 3 passed, 2 failed, 1 skipped across 2 environment(s), 3 unique image(s)
+  development: 2 passed, 1 failed, 0 skipped
+  production: 1 passed, 1 failed, 1 skipped
+Processed 2 chart(s) and 4 image check(s) in 1.5s
+Failures: 0 render error(s), 1 validation error(s), 1 missing image(s), 0 other
+`, out.String())
+}
+
+func TestAggregateEmpty(t *testing.T) {
+	summary := Aggregate(nil)
+	assert.Equal(t, 0, summary.Total())
+	assert.Empty(t, summary.Environments)
+	assert.Empty(t, summary.Images)
+}
+
+func TestAggregateWarnings(t *testing.T) {
+	chart := createTestChart()
+	summary := Aggregate([]AppCheckResult{
+		{Chart: chart, Image: "nginx:1.25"},
+		{Chart: chart, Image: "redis:latest", Warning: true, Error: errors.New("mutable tag in image redis:latest: pin a version tag or digest")},
+	})
+
+	assert.Equal(t, StatusCounts{Passed: 1, Warnings: 1}, summary.StatusCounts)
+	assert.Equal(t, 2, summary.Total())
+
+	var out bytes.Buffer
+	printSummary(&out, summary)
+	assert.Equal(t, `Summary: 1 passed, 0 failed, 0 skipped, 1 warning(s) across 1 environment(s), 2 unique image(s)
+  development: 1 passed, 0 failed, 0 skipped, 1 warning(s)
+Processed 1 chart(s) and 2 image check(s)
+`, out.String())
+}
+
+func TestAggregateFailureCategories(t *testing.T) {
+	chart := createTestChart()
+	other := createTestChart()
+	other.ChartName = "other-chart"
+
+	summary := Aggregate([]AppCheckResult{
+		{Chart: chart, Stage: stageRender, Error: errors.New("helm command failed")},
+		{Chart: other, Stage: stageRender, Error: errors.New("helm command failed")},
+		{Chart: other, Stage: stageValidation, Error: errors.New("kubeconform command failed")},
+		{Chart: chart, Stage: stageExtraction, Error: errors.New("failed to extract images")},
+		{Chart: chart, Image: "web:1.0", Missing: true, Error: errors.New("docker image does not exist: web:1.0")},
+		{Chart: chart, Image: "web:2.0", Missing: true, Warning: true, Error: errors.New("docker image does not exist: web:2.0")},
+		{Chart: chart, Image: "private:1.0", AccessDenied: true, Error: errors.New("unauthorized")},
+		{Chart: chart, Image: "nginx:1.25"},
+	})
+
+	assert.Equal(t, 2, summary.Charts)
+	assert.Equal(t, 4, summary.ImageChecks)
+	assert.Equal(t, 6, summary.Failed)
+	assert.Equal(t, FailureCounts{Render: 2, Validation: 1, MissingImage: 1, Other: 2}, summary.Failures)
+}
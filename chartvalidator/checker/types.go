@@ -5,22 +5,47 @@ import (
 	"sync"
 )
 
+// Pipeline stages a chart-level error can come from
+const (
+	stageRender     = "render"
+	stageValidation = "validation"
+	stageExtraction = "extraction"
+)
+
 type ErrorResult struct {
 	Chart ChartRenderParams
+	// Stage is the pipeline stage that failed
+	Stage string
 	Error error
 }
 
 type DockerImageValidationResult struct {
 	Chart  ChartRenderParams
 	Image  string
+	// OriginalImage is the extracted reference when Image was rewritten before validation
+	OriginalImage string
 	Exists bool
+	// Unverifiable is set when the registry couldn't be asked, e.g. because of an
+	// auth or network error, so whether the image exists is unknown
+	Unverifiable bool
+	// AccessDenied is set when the registry refused the credentials, so the
+	// checker lacks access rather than the image being missing
+	AccessDenied bool
 	Error  error
+	// Sources are where the chart's manifest references the image
+	Sources []ImageSource
 }
 
 type ImageExtractionResult struct {
 	Chart       ChartRenderParams
 	ManifestFile string
 	Image       string
+	// OriginalImage is the extracted reference when Image was rewritten before validation
+	OriginalImage string
+	// Error is set when the extracted reference is malformed and must not be validated
+	Error error
+	// Sources are every resource and container of the manifest referencing the image
+	Sources []ImageSource
 }
 
 // ChartRenderParams represents a Helm chart configuration extracted from ApplicationSet files
@@ -31,6 +56,14 @@ type ChartRenderParams struct {
 	ChartVersion   string `json:"chartVersion"`
 	BaseValuesFile string `json:"baseValuesFile"`
 	ValuesOverride string `json:"valuesOverride"`
+	// Namespace, when set, is the namespace the chart is rendered into
+	Namespace      string `json:"namespace,omitempty"`
+	// ReleaseName, when set, is the release name to render with instead of the chart name
+	ReleaseName    string `json:"releaseName,omitempty"`
+	// InlineValues holds values declared directly in the source manifest (e.g. a HelmRelease)
+	InlineValues   string `json:"inlineValues,omitempty"`
+	// AppVersion is the chart's appVersion, filled in at render time when requested
+	AppVersion     string `json:"appVersion,omitempty"`
 }
 
 // task represents a validation task with a chart and command
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -17,45 +20,48 @@ const (
 	colorCyan   = "\033[36m"
 )
 
-// logEngine prints formatted log messages with color coding based on level
-func logEngine(level, engineName string, workerId int, message string) {
-	var color string
-	switch strings.ToUpper(level) {
-	case "ERROR":
-		color = colorRed
-	case "WARNING":
-		color = colorYellow
-	case "DEBUG":
-		color = colorCyan
-	default:
-		color = colorReset
+// logOutput receives human-readable progress output: what a command is doing
+// and its result lines. It is stdout unless setProgressOutput moves it to
+// stderr. Engine log messages go through logger, to stderr, instead.
+var logOutput io.Writer = os.Stdout
+
+// setProgressOutput decides where progress output goes: stderr when stdout
+// carries machine-readable output, stdout otherwise
+func setProgressOutput(machineReadableStdout bool) {
+	if machineReadableStdout {
+		logOutput = os.Stderr
+	} else {
+		logOutput = os.Stdout
 	}
+}
 
-	// Split message into lines if it contains newlines
-	lines := strings.Split(message, "\n")
-	
-	// Print first line with full prefix and color
-	fmt.Printf("%s[%s]\t[%s Worker %d]\t%s%s\n", color, level, engineName, workerId, lines[0], colorReset)
-	
-	// Print additional lines with empty columns for alignment
-	for i := 1; i < len(lines); i++ {
-		fmt.Printf("\t\t%s\n", lines[i])
+// sendOrDone sends value on ch unless ctx is cancelled first, in which case the
+// stage that would receive it may already have stopped. It reports whether the
+// value was sent.
+func sendOrDone[T any](ctx context.Context, ch chan<- T, value T) bool {
+	select {
+	case ch <- value:
+		return true
+	case <-ctx.Done():
+		return false
 	}
 }
 
+// logEngine logs a message of an engine worker at level
+func logEngine(level slog.Level, engineName string, workerId int, message string) {
+	logger.Log(context.Background(), level, message, "engine", engineName, "worker", workerId)
+}
+
 func logEngineDebug(engineName string, workerId int, message string) {
-	if !verboseLogging {
-		return
-	}
-	logEngine("DEBUG", engineName, workerId, message)
+	logEngine(slog.LevelDebug, engineName, workerId, message)
 }
 
 func logEngineWarning(engineName string, workerId int, message string) {
-	logEngine("WARNING", engineName, workerId, message)
+	logEngine(slog.LevelWarn, engineName, workerId, message)
 }
 
 func logEngineError(engineName string, workerId int, message string) {
-	logEngine("ERROR", engineName, workerId, message)
+	logEngine(slog.LevelError, engineName, workerId, message)
 }
 
 // getJobCount returns the number of parallel jobs to run
@@ -79,11 +85,21 @@ func parseInt(s string) (int, error) {
 	return n, err
 }
 
-// recreateOutputDir removes and recreates the output directory
+// recreateOutputDir empties the output directory, creating it if needed. The
+// run's lockfile is kept.
 func recreateOutputDir(outputDir string) error {
-	if err := os.RemoveAll(outputDir); err != nil {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to remove output directory: %w", err)
 	}
+	for _, entry := range entries {
+		if entry.Name() == lockFileName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(outputDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove output directory: %w", err)
+		}
+	}
 	
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -92,8 +108,14 @@ func recreateOutputDir(outputDir string) error {
 	return nil
 }
 
+// followSymlinks makes file discovery descend into symlinked directories
+var followSymlinks bool = false
+
 // walkFiles returns all files under root that pass the filter
 func walkFiles(root string, filter func(string, fs.DirEntry) bool) ([]string, error) {
+	if followSymlinks {
+		return walkFilesFollowingSymlinks(root, filter)
+	}
 	var files []string
 	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -107,6 +129,55 @@ func walkFiles(root string, filter func(string, fs.DirEntry) bool) ([]string, er
 	return files, err
 }
 
+// walkFilesFollowingSymlinks is walkFiles resolving symlinked files and directories.
+// Each real directory is visited once, which guards against symlink loops, and
+// broken links are skipped with a warning.
+func walkFilesFollowingSymlinks(root string, filter func(string, fs.DirEntry) bool) ([]string, error) {
+	var files []string
+	visited := map[string]bool{}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		if visited[realDir] {
+			logEngineDebug("Discovery", -1, fmt.Sprintf("skipping already visited directory %s (symlink loop?)", dir))
+			return nil
+		}
+		visited[realDir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			p := filepath.Join(dir, entry.Name())
+			if entry.Type()&fs.ModeSymlink != 0 {
+				info, err := os.Stat(p)
+				if err != nil {
+					logEngineWarning("Discovery", -1, fmt.Sprintf("skipping broken symlink %s: %v", p, err))
+					continue
+				}
+				entry = fs.FileInfoToDirEntry(info)
+			}
+			if entry.IsDir() {
+				if err := walk(p); err != nil {
+					return err
+				}
+				continue
+			}
+			if filter(p, entry) {
+				files = append(files, p)
+			}
+		}
+		return nil
+	}
+
+	return files, walk(root)
+}
+
 // removeDuplicates removes duplicate strings from a slice while preserving order
 func removeDuplicates(slice []string) []string {
 	seen := make(map[string]bool)
@@ -158,4 +158,70 @@ func createManifestValidationMockExecutor() *MockCommandExecutor {
 		Output: []byte("mocked kubeconform output"),
 		Error:  nil,
 	}
-}
\ No newline at end of file
+}
+// Helper function to build a manifest tree with a symlinked directory, a symlink loop and a broken link
+func createSymlinkedManifestTree(t *testing.T) string {
+	root := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "shared")
+	createTempManifestFile(t, root, "env/own.yaml", "kind: ConfigMap\n")
+	createTempManifestFile(t, shared, "linked.yaml", "kind: ConfigMap\n")
+
+	symlinks := map[string]string{
+		filepath.Join(root, "env", "shared"):      shared,
+		filepath.Join(root, "env", "loop"):        filepath.Join(root, "env"),
+		filepath.Join(root, "env", "broken.yaml"): filepath.Join(root, "missing.yaml"),
+	}
+	for link, target := range symlinks {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	return root
+}
+
+func TestFindYAMLFilesFollowingSymlinks(t *testing.T) {
+	root := createSymlinkedManifestTree(t)
+
+	followSymlinks = true
+	defer func() { followSymlinks = false }()
+
+	files, err := findYAMLFiles(root)
+	assert.NoError(t, err)
+
+	var relative []string
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		assert.NoError(t, err)
+		relative = append(relative, rel)
+	}
+	assert.ElementsMatch(t, []string{"env/own.yaml", "env/shared/linked.yaml"}, relative)
+}
+
+func TestFindYAMLFilesWithoutFollowingSymlinks(t *testing.T) {
+	root := createSymlinkedManifestTree(t)
+
+	files, err := findYAMLFiles(root)
+	assert.NoError(t, err)
+
+	for _, file := range files {
+		assert.NotContains(t, file, "linked.yaml", "Symlinked directories should not be descended by default")
+	}
+}
+
+func TestFileExistsBrokenSymlink(t *testing.T) {
+	root := createSymlinkedManifestTree(t)
+	executor := &RealCommandExecutor{}
+
+	assert.False(t, executor.FileExists(filepath.Join(root, "env", "broken.yaml")))
+	assert.True(t, executor.FileExists(filepath.Join(root, "env", "shared", "linked.yaml")))
+}
+
+func TestSetProgressOutput(t *testing.T) {
+	defer func() { logOutput = os.Stdout }()
+
+	setProgressOutput(true)
+	assert.Equal(t, os.Stderr, logOutput, "Expected progress to leave stdout to machine-readable output")
+
+	setProgressOutput(false)
+	assert.Equal(t, os.Stdout, logOutput)
+}
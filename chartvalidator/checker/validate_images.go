@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runValidateImagesCommand(args []string) {
+	fs := flag.NewFlagSet("validate-images", flag.ExitOnError)
+
+	var (
+		dir     = fs.String("dir", "", "Directory of JSON image lists, e.g. written by extract-images, searched recursively for .json files.")
+		dryRun  = fs.Bool("dry-run", false, "Log the registry commands instead of running them.")
+		verbose = fs.Bool("v", false, "Enable verbose logging.")
+	)
+
+	fs.Usage = func() {
+		fmt.Println("Usage: run-manifest-checks validate-images -dir <image lists> [flags]")
+		fmt.Println("")
+		fmt.Println("Validates that every image in a directory of JSON image lists exists in its registry.")
+		fmt.Println("")
+		fmt.Println("Docker needs to be authenticated to the registries used by the images for validation to work.")
+		fmt.Println("")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	setVerboseLogging(*verbose)
+
+	options := AppCheckerOptions{DryRun: *dryRun}
+	ok, err := validateImagesOfDir(context.Background(), options.commandExecutor(), *dir, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating images: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// validateImagesOfDir validates the deduplicated images of every JSON image
+// list under dir and prints whether each exists. It reports whether all of them do.
+func validateImagesOfDir(ctx context.Context, executor CommandExecutor, dir string, w io.Writer) (bool, error) {
+	jsonFiles, err := findJSONFiles(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to find image lists in %s: %w", dir, err)
+	}
+	if len(jsonFiles) == 0 {
+		return false, fmt.Errorf("no image lists found in %s", dir)
+	}
+
+	images, err := extractAllImagesFromJSONFiles(jsonFiles)
+	if err != nil {
+		return false, err
+	}
+	images = deduplicateImages(images)
+
+	return printImageValidation(w, images, validateImages(ctx, executor, images)), nil
+}
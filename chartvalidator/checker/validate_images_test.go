@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createImageLists writes JSON image lists the way extract-images does
+func createImageLists(t *testing.T) string {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "web.json"), []byte(`["nginx:1.25", "busybox:1.36"]`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "api.json"), []byte(`["nginx:1.25", "registry.example.com/api:2.0"]`), 0644))
+	return dir
+}
+
+func TestValidateImagesOfDir(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	var out bytes.Buffer
+	ok, err := validateImagesOfDir(createTestContext(), mockExecutor, createImageLists(t), &out)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "busybox:1.36: ✓ exists\nnginx:1.25: ✓ exists\nregistry.example.com/api:2.0: ✓ exists\n", out.String())
+	assert.ElementsMatch(t, []string{
+		"docker manifest inspect nginx:1.25",
+		"docker manifest inspect busybox:1.36",
+		"docker manifest inspect registry.example.com/api:2.0",
+	}, mockExecutor.History, "Expected each image to be validated once")
+}
+
+func TestValidateImagesOfDirReportsMissingImages(t *testing.T) {
+	mockExecutor := createMockExecutor()
+	mockExecutor.Output = []byte("manifest unknown")
+	mockExecutor.Error = errors.New("exit status 1")
+	var out bytes.Buffer
+	ok, err := validateImagesOfDir(createTestContext(), mockExecutor, createImageLists(t), &out)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Contains(t, out.String(), "busybox:1.36: ✗ does not exist\n")
+}
+
+func TestValidateImagesOfDirWithoutImageLists(t *testing.T) {
+	_, err := validateImagesOfDir(createTestContext(), createMockExecutor(), t.TempDir(), &bytes.Buffer{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no image lists found")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+func runValidateManifestsCommand(args []string) {
+	fs := flag.NewFlagSet("validate-manifests", flag.ExitOnError)
+
+	var (
+		dir       = fs.String("dir", "", "Directory of rendered manifests to validate, searched recursively for .yaml and .yml files.")
+		kcBatch   = fs.Bool("kubeconform-batch", false, "Validate all manifests with a single kubeconform invocation instead of one per file.")
+		kubeVer   = fs.String("kube-version", "", "Kubernetes version to validate manifests against (e.g. 1.29.0), kubeconform's default when empty.")
+		skipKinds = fs.String("skip-kinds", "", "Comma-separated resource kinds kubeconform should not validate, e.g. CRDs without a published schema.")
+		symlinks  = fs.Bool("follow-symlinks", false, "Follow symlinked directories when discovering manifests.")
+		dryRun    = fs.Bool("dry-run", false, "Log the kubeconform commands instead of running them.")
+		verbose   = fs.Bool("v", false, "Enable verbose logging.")
+	)
+
+	var schemaLocs stringListFlag
+	fs.Var(&schemaLocs, "schema-location", "Kubeconform schema location, repeatable. When given, replaces the built-in default, datreeio CRDs-catalog and ci/schemas locations.")
+
+	fs.Usage = func() {
+		fmt.Println("Usage: run-manifest-checks validate-manifests -dir <manifests> [flags]")
+		fmt.Println("")
+		fmt.Println("Validates a directory of manifests rendered elsewhere with kubeconform, without rendering any charts.")
+		fmt.Println("")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "Error: -dir is required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	setVerboseLogging(*verbose)
+	followSymlinks = *symlinks
+
+	options := AppCheckerOptions{
+		DryRun:           *dryRun,
+		KubeconformBatch: *kcBatch,
+		SchemaLocations:  []string(schemaLocs),
+		KubeVersion:      *kubeVer,
+		SkipKinds:        parseCommaList(*skipKinds),
+	}
+
+	ok, err := validateManifestDir(context.Background(), options.commandExecutor(), *dir, options, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error validating manifests: %v\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// validateManifestDir runs every manifest under dir through a ManifestValidationEngine
+// and prints whether each one is valid. It reports whether all of them are.
+func validateManifestDir(ctx context.Context, executor CommandExecutor, dir string, options AppCheckerOptions, w io.Writer) (bool, error) {
+	files, err := findYAMLFiles(dir)
+	if err != nil {
+		return false, fmt.Errorf("failed to find manifests in %s: %w", dir, err)
+	}
+	if len(files) == 0 {
+		return false, fmt.Errorf("no manifests found in %s", dir)
+	}
+
+	engine := ManifestValidationEngine{
+		inputChan:       make(chan RenderResult),
+		resultChan:      make(chan ManifestValidationResult),
+		errorChan:       make(chan ErrorResult),
+		context:         ctx,
+		executor:        executor,
+		name:            "ManifestValidator",
+		batch:           options.KubeconformBatch,
+		schemaLocations: options.SchemaLocations,
+		kubeVersion:     options.KubeVersion,
+		skipKinds:       options.SkipKinds,
+	}
+	engine.Start(getJobCount())
+
+	go func() {
+		for _, file := range files {
+			// The file stands in for the chart, so failures can be attributed to it
+			input := RenderResult{Chart: ChartRenderParams{ChartName: file}, ManifestPath: file}
+			if !sendOrDone(ctx, engine.inputChan, input) {
+				break
+			}
+		}
+		close(engine.inputChan)
+	}()
+
+	// Failures are sent before the workers finish, so they have all arrived
+	// by the time the result channel is closed
+	failures := map[string]error{}
+	results := engine.resultChan
+	for results != nil {
+		select {
+		case _, ok := <-results:
+			if !ok {
+				results = nil
+			}
+		case failure := <-engine.errorChan:
+			failures[failure.Chart.ChartName] = failure.Error
+		}
+	}
+
+	sort.Strings(files)
+	allValid := true
+	for _, file := range files {
+		if err, failed := failures[file]; failed {
+			fmt.Fprintf(w, "%s: ✗ Error: %v\n", file, err)
+			allValid = false
+			continue
+		}
+		fmt.Fprintf(w, "%s: ✓ valid\n", file)
+	}
+	return allValid, nil
+}
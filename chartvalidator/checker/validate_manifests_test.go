@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateManifestDir(t *testing.T) {
+	dir := t.TempDir()
+	first := createTempManifestFile(t, dir, "web.yaml", configMapManifest)
+	second := createTempManifestFile(t, filepath.Join(dir, "nested"), "api.yml", configMapManifest)
+	createTempManifestFile(t, dir, "README.md", "not a manifest")
+
+	mockExecutor := createManifestValidationMockExecutor()
+	var out bytes.Buffer
+	ok, err := validateManifestDir(createTestContext(), mockExecutor, dir, AppCheckerOptions{KubeVersion: "1.29.0"}, &out)
+
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, second+": ✓ valid\n"+first+": ✓ valid\n", out.String(), "Expected files in sorted order")
+	assert.Len(t, mockExecutor.History, 2)
+	assert.Contains(t, mockExecutor.History[0], "kubeconform -strict -summary")
+	assert.Contains(t, mockExecutor.History[0], "-kubernetes-version 1.29.0")
+}
+
+func TestValidateManifestDirReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	manifest := createTempManifestFile(t, dir, "web.yaml", configMapManifest)
+
+	mockExecutor := createManifestValidationMockExecutor()
+	mockExecutor.BehaviorOnSplitOutput = func() ([]byte, []byte, error) {
+		return []byte("ConfigMap web is invalid: additionalProperties 'colour' not allowed"), nil, errors.New("exit status 1")
+	}
+	var out bytes.Buffer
+	ok, err := validateManifestDir(createTestContext(), mockExecutor, dir, AppCheckerOptions{}, &out)
+
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Contains(t, out.String(), manifest+": ✗ Error: ")
+	assert.Contains(t, out.String(), "additionalProperties 'colour' not allowed")
+}
+
+func TestValidateManifestDirWithoutManifests(t *testing.T) {
+	_, err := validateManifestDir(createTestContext(), createManifestValidationMockExecutor(), t.TempDir(), AppCheckerOptions{}, &bytes.Buffer{})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no manifests found")
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is how long a persisted image validation is trusted
+const defaultCacheTTL = 24 * time.Hour
+
+const (
+	// cacheLockTimeout is how long a run waits for another to release a shared cache file
+	cacheLockTimeout = 30 * time.Second
+	// staleCacheLockAge is when a cache lockfile is taken to be left behind by a crashed run.
+	// The lock is only held while the file is merged, which takes milliseconds.
+	staleCacheLockAge = time.Minute
+)
+
+// persistedValidation is one image in the cache file
+type persistedValidation struct {
+	Exists    bool      `json:"exists"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// Checks are the checks besides existence the image passed, see validationChecks
+	Checks string `json:"checks,omitempty"`
+}
+
+// validationCacheFile is the layout of the -cache-file
+type validationCacheFile struct {
+	Images map[string]persistedValidation `json:"images"`
+}
+
+// fileValidationCache keeps validation results across runs in a JSON file.
+// Every result of the current run is kept in memory, but only definitive
+// answers, an image that exists or doesn't, are persisted: errors may be
+// transient.
+//
+// A shared cache coordinates concurrent runs using the same file, e.g. the
+// jobs of a CI matrix on a network volume. Each result is merged into the file
+// as soon as it is known, and a miss rereads the file first, so an image
+// inspected by one job isn't inspected again by the others. A shared cache
+// guards itself because a miss changes it under the engine's read lock.
+type fileValidationCache struct {
+	path      string
+	ttl       time.Duration
+	now       func() time.Time
+	run       *memoryValidationCache
+	persisted map[string]persistedValidation
+
+	// checks are the validationChecks of the run. Images found to exist are
+	// only served from the file when they passed the same checks.
+	checks string
+
+	shared bool
+	lock   sync.Mutex
+}
+
+// loadFileValidationCache reads the cache file at path, a missing file being an empty cache
+func loadFileValidationCache(path string, ttl time.Duration) (*fileValidationCache, error) {
+	cache := &fileValidationCache{
+		path:      path,
+		ttl:       ttl,
+		now:       time.Now,
+		run:       newMemoryValidationCache(),
+		persisted: map[string]persistedValidation{},
+	}
+
+	images, err := readValidationCacheFile(path)
+	if images != nil {
+		cache.persisted = images
+	}
+	return cache, err
+}
+
+// loadSharedValidationCache is loadFileValidationCache for a cache file shared with concurrent runs
+func loadSharedValidationCache(path string, ttl time.Duration) (*fileValidationCache, error) {
+	cache, err := loadFileValidationCache(path, ttl)
+	cache.shared = true
+	return cache, err
+}
+
+// readValidationCacheFile returns the images in the cache file, nil when it doesn't exist
+func readValidationCacheFile(path string) (map[string]persistedValidation, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation cache: %w", err)
+	}
+	var file validationCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse validation cache %s: %w", path, err)
+	}
+	return file.Images, nil
+}
+
+func (c *fileValidationCache) Get(image string) (DockerImageValidationResult, bool) {
+	if c.shared {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	}
+	if result, found := c.run.Get(image); found {
+		return result, true
+	}
+	if result, found := c.fresh(image); found || !c.shared {
+		return result, found
+	}
+
+	// Another run may have checked the image since the file was loaded. A file
+	// that can't be read is just a miss, the image is checked again.
+	if images, err := readValidationCacheFile(c.path); err == nil {
+		c.merge(images)
+	}
+	return c.fresh(image)
+}
+
+// fresh looks an image up in the persisted results that haven't expired
+func (c *fileValidationCache) fresh(image string) (DockerImageValidationResult, bool) {
+	entry, found := c.persisted[image]
+	if !found || c.now().Sub(entry.CheckedAt) > c.ttl {
+		return DockerImageValidationResult{}, false
+	}
+	if entry.Exists && entry.Checks != c.checks {
+		// Checked without the signature or platform checks of this run
+		return DockerImageValidationResult{}, false
+	}
+	return DockerImageValidationResult{Image: image, Exists: entry.Exists}, true
+}
+
+func (c *fileValidationCache) Set(image string, result DockerImageValidationResult) {
+	if c.shared {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+	}
+	c.run.Set(image, result)
+	if result.Error == nil && !result.Unverifiable {
+		c.persisted[image] = persistedValidation{Exists: result.Exists, CheckedAt: c.now(), Checks: c.checks}
+		if c.shared {
+			// Failing to share a result early only costs other runs an
+			// inspect, the final Save reports a file that can't be written
+			_ = c.saveShared()
+		}
+	}
+}
+
+// validationChecks describes the checks an existing image has to pass besides
+// existence, empty when there are none. Persisted images record it so that a
+// run verifying signatures or platforms doesn't trust images checked without.
+func validationChecks(signatures *signatureVerifier, platform *imagePlatform) string {
+	var checks []string
+	if signatures != nil {
+		checks = append(checks, fmt.Sprintf("signature key=%s identity=%s issuer=%s", signatures.Key, signatures.Identity, signatures.Issuer))
+	}
+	if platform != nil {
+		checks = append(checks, "platform "+platform.String())
+	}
+	return strings.Join(checks, "; ")
+}
+
+// merge adds the entries of images, keeping the most recent check of an image
+func (c *fileValidationCache) merge(images map[string]persistedValidation) {
+	for image, entry := range images {
+		if current, found := c.persisted[image]; !found || entry.CheckedAt.After(current.CheckedAt) {
+			c.persisted[image] = entry
+		}
+	}
+}
+
+// Save writes the persisted results back to the cache file, dropping expired entries
+func (c *fileValidationCache) Save() error {
+	if c.shared {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		return c.saveShared()
+	}
+	return c.write()
+}
+
+// saveShared merges the cache file into the persisted results and writes them
+// back while holding the cache lockfile, so that results other runs saved in
+// the meantime are kept
+func (c *fileValidationCache) saveShared() error {
+	release, err := acquireCacheLock(c.path)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	images, err := readValidationCacheFile(c.path)
+	if err != nil {
+		return err
+	}
+	c.merge(images)
+	return c.write()
+}
+
+// write replaces the cache file with the unexpired persisted results. The file
+// is renamed into place so that concurrent readers never see it half written.
+func (c *fileValidationCache) write() error {
+	file := validationCacheFile{Images: map[string]persistedValidation{}}
+	for image, entry := range c.persisted {
+		if c.now().Sub(entry.CheckedAt) <= c.ttl {
+			file.Images[image] = entry
+		}
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal validation cache: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write validation cache %s: %w", c.path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write validation cache %s: %w", c.path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write validation cache %s: %w", c.path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write validation cache %s: %w", c.path, err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write validation cache %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// acquireCacheLock creates the lockfile next to a shared cache file, waiting
+// for a concurrent run to release it. Lockfiles are created exclusively rather
+// than with flock, which network filesystems don't reliably support. The
+// returned function removes the lockfile again.
+func acquireCacheLock(cachePath string) (func() error, error) {
+	lockPath := cachePath + ".lock"
+	deadline := time.Now().Add(cacheLockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "pid %d since %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+			f.Close()
+			return func() error {
+				return os.Remove(lockPath)
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create cache lockfile: %w", err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleCacheLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for cache lockfile %s (remove it if no other run is active)", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
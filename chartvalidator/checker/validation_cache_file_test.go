@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCacheFile seeds a cache file with images checked at checkedAt
+func writeCacheFile(t *testing.T, path string, checkedAt time.Time, images map[string]bool) {
+	file := validationCacheFile{Images: map[string]persistedValidation{}}
+	for image, exists := range images {
+		file.Images[image] = persistedValidation{Exists: exists, CheckedAt: checkedAt}
+	}
+	data, err := json.Marshal(file)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+}
+
+func TestFileValidationCacheServesFreshEntriesWithoutDocker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	writeCacheFile(t, path, time.Now().Add(-time.Hour), map[string]bool{"nginx:1.20": true, "missing:1.0": false})
+
+	cache, err := loadFileValidationCache(path, defaultCacheTTL)
+	assert.NoError(t, err)
+
+	mockExecutor := createMockExecutor()
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.cache = cache
+	engine.Start(1)
+
+	sendImagesToEngine(engine, []string{"nginx:1.20"})
+	result := <-engine.outputChan
+	assert.True(t, result.Exists)
+
+	sendImagesToEngine(engine, []string{"missing:1.0"})
+	result = <-engine.outputChan
+	assert.False(t, result.Exists)
+	assert.NoError(t, result.Error)
+
+	assert.Empty(t, mockExecutor.History, "Expected no command for images in the cache file")
+}
+
+func TestFileValidationCacheExpiresEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	writeCacheFile(t, path, time.Now().Add(-2*time.Hour), map[string]bool{"nginx:1.20": true})
+
+	cache, err := loadFileValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+
+	_, found := cache.Get("nginx:1.20")
+	assert.False(t, found, "Expected an entry older than the TTL to be a miss")
+}
+
+func TestFileValidationCacheSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	writeCacheFile(t, path, time.Now().Add(-2*time.Hour), map[string]bool{"stale:1.0": true})
+
+	cache, err := loadFileValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	cache.Set("nginx:1.20", DockerImageValidationResult{Image: "nginx:1.20", Exists: true})
+	cache.Set("missing:1.0", DockerImageValidationResult{Image: "missing:1.0", Exists: false})
+	cache.Set("private:1.0", DockerImageValidationResult{Image: "private:1.0", Unverifiable: true, Error: fmt.Errorf("registry error")})
+
+	// Registry errors are still served from memory for the rest of the run
+	result, found := cache.Get("private:1.0")
+	assert.True(t, found)
+	assert.True(t, result.Unverifiable)
+
+	assert.NoError(t, cache.Save())
+
+	reloaded, err := loadFileValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.persisted, 2, "Expected only definitive, unexpired results to be saved")
+	result, found = reloaded.Get("nginx:1.20")
+	assert.True(t, found)
+	assert.True(t, result.Exists)
+	result, found = reloaded.Get("missing:1.0")
+	assert.True(t, found)
+	assert.False(t, result.Exists)
+	_, found = reloaded.Get("private:1.0")
+	assert.False(t, found)
+}
+
+func TestLoadFileValidationCache(t *testing.T) {
+	t.Run("missing file is an empty cache", func(t *testing.T) {
+		cache, err := loadFileValidationCache(filepath.Join(t.TempDir(), "cache.json"), time.Hour)
+		assert.NoError(t, err)
+		assert.Empty(t, cache.persisted)
+	})
+
+	t.Run("corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.json")
+		assert.NoError(t, os.WriteFile(path, []byte("{not json"), 0644))
+		cache, err := loadFileValidationCache(path, time.Hour)
+		assert.ErrorContains(t, err, "failed to parse validation cache")
+		assert.NotNil(t, cache, "Expected a usable empty cache alongside the error")
+	})
+}
+
+func TestSharedValidationCacheConcurrentRuns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+
+	// Each cache stands in for a separate process: they only share the file
+	first, err := loadSharedValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	second, err := loadSharedValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for run, cache := range []*fileValidationCache{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Half of the images are checked by both runs
+			for i := run * 25; i < run*25+50; i++ {
+				image := fmt.Sprintf("app-%d:1.0", i)
+				cache.Set(image, DockerImageValidationResult{Image: image, Exists: i%3 != 0})
+			}
+			assert.NoError(t, cache.Save())
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var file validationCacheFile
+	assert.NoError(t, json.Unmarshal(data, &file), "Expected the shared cache file to be valid JSON")
+	assert.Len(t, file.Images, 75, "Expected the results of both runs to be kept")
+	for i := 0; i < 75; i++ {
+		entry, found := file.Images[fmt.Sprintf("app-%d:1.0", i)]
+		assert.True(t, found)
+		assert.Equal(t, i%3 != 0, entry.Exists)
+	}
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, "cache.json.*"))
+	assert.NoError(t, err)
+	assert.Empty(t, leftovers, "Expected no lockfile or temporary file to be left behind")
+}
+
+func TestSharedValidationCacheServesResultsOfOtherRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	first, err := loadSharedValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	second, err := loadSharedValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+
+	first.Set("nginx:1.20", DockerImageValidationResult{Image: "nginx:1.20", Exists: true})
+
+	mockExecutor := createMockExecutor()
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.cache = second
+	engine.Start(1)
+
+	sendImagesToEngine(engine, []string{"nginx:1.20"})
+	result := <-engine.outputChan
+	assert.True(t, result.Exists)
+	assert.Empty(t, mockExecutor.History, "Expected the image checked by the other run not to be inspected again")
+}
+
+func TestSharedValidationCacheRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	lockPath := path + ".lock"
+	assert.NoError(t, os.WriteFile(lockPath, []byte("pid 1\n"), 0644))
+	stale := time.Now().Add(-2 * staleCacheLockAge)
+	assert.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	cache, err := loadSharedValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	cache.Set("nginx:1.20", DockerImageValidationResult{Image: "nginx:1.20", Exists: true})
+
+	assert.NoError(t, cache.Save())
+	assert.NoFileExists(t, lockPath)
+	reloaded, err := loadFileValidationCache(path, time.Hour)
+	assert.NoError(t, err)
+	assert.Contains(t, reloaded.persisted, "nginx:1.20")
+}
+
+func TestFileValidationCacheRechecksImagesWithSignatureVerification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	writeCacheFile(t, path, time.Now().Add(-time.Hour), map[string]bool{"nginx:1.25": true, "missing:1.0": false})
+	verifier := &signatureVerifier{Key: "cosign.pub"}
+
+	mockExecutor := createMockExecutor()
+	mockExecutor.BehaviorOnCombinedOutput = func() ([]byte, error) {
+		if mockExecutor.LastCommand == "docker" {
+			return []byte(`{"schemaVersion": 2}`), nil
+		}
+		return []byte("Error: no signatures found\n"), fmt.Errorf("exit status 1")
+	}
+	engine := createDockerValidationEngine(mockExecutor)
+	engine.cache = newDockerValidationCache(AppCheckerOptions{CacheFile: path, CacheTTL: defaultCacheTTL, SignatureVerifier: verifier})
+	engine.signatures = verifier
+	engine.Start(1)
+
+	sendImagesToEngine(engine, []string{"nginx:1.25"})
+	result := <-engine.outputChan
+	assert.EqualError(t, result.Error, "signature verification failed for nginx:1.25: exit status 1: Error: no signatures found")
+	assert.Equal(t, []string{"docker manifest inspect nginx:1.25", "cosign verify --key cosign.pub nginx:1.25"}, mockExecutor.History)
+
+	sendImagesToEngine(engine, []string{"missing:1.0"})
+	result = <-engine.outputChan
+	assert.False(t, result.Exists, "Expected missing images to be served from the cache whatever the checks")
+	assert.Len(t, mockExecutor.History, 2)
+}
+
+func TestFileValidationCacheServesImagesThatPassedTheSameChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	platform := &imagePlatform{OS: "linux", Architecture: "arm64"}
+
+	cache := newDockerValidationCache(AppCheckerOptions{CacheFile: path, CacheTTL: defaultCacheTTL, RequirePlatform: platform}).(*fileValidationCache)
+	cache.Set("nginx:1.25", DockerImageValidationResult{Image: "nginx:1.25", Exists: true})
+	assert.NoError(t, cache.Save())
+
+	same := newDockerValidationCache(AppCheckerOptions{CacheFile: path, CacheTTL: defaultCacheTTL, RequirePlatform: platform})
+	_, found := same.Get("nginx:1.25")
+	assert.True(t, found)
+
+	other := newDockerValidationCache(AppCheckerOptions{CacheFile: path, CacheTTL: defaultCacheTTL, RequirePlatform: &imagePlatform{OS: "linux", Architecture: "amd64"}})
+	_, found = other.Get("nginx:1.25")
+	assert.False(t, found, "Expected an image checked for another platform to be a miss")
+}